@@ -1,17 +1,22 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/sohan-reza/capstone-core/internal/config"
 	"github.com/sohan-reza/capstone-core/internal/controller"
-	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/database"
+	"github.com/sohan-reza/capstone-core/internal/middleware"
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/progress"
 	"github.com/sohan-reza/capstone-core/internal/repository"
 	"github.com/sohan-reza/capstone-core/internal/service"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+	"github.com/sohan-reza/capstone-core/internal/validation"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -24,33 +29,202 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	awsService, err := service.NewAWSService(cfg.AWS.BucketName, cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey)
+	keys, err := service.NewKeyBuilder(cfg.Storage.KeyLayout)
+	if err != nil {
+		log.Fatalf("Invalid storage key layout: %v", err)
+	}
+
+	awsService, err := service.NewAWSService(cfg.AWS.BucketName, cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, keys)
 	if err != nil {
 		log.Fatalf("Failed to initialize AWS service: %v", err)
 	}
 
 	// Initialize database and repository
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		cfg.Database.Host, cfg.Database.User, cfg.Database.Password, cfg.Database.Name, cfg.Database.Port)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := database.Open(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-
-	// Auto migrate (for development)
-	if err := db.AutoMigrate(&model.File{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	if err := database.Prepare(context.Background(), db); err != nil {
+		log.Fatalf("Database schema check failed: %v", err)
 	}
 
 	fileRepo := repository.NewFileRepository(db)
+	submissionRepo := repository.NewSubmissionRepository(db)
+	jobRepo := repository.NewUploadJobRepository(db)
+
+	scanner := service.NewNoopScanner()
+	if cfg.Scanner.Enabled {
+		scanner, err = service.NewClamdScanner(cfg.Scanner.ClamdAddress, cfg.Scanner.Timeout)
+		if err != nil {
+			log.Fatalf("Failed to initialize antivirus scanner: %v", err)
+		}
+	}
+
+	quarantine, err := service.NewLocalQuarantine(cfg.Scanner.QuarantineDir)
+	if err != nil {
+		log.Fatalf("Failed to initialize quarantine: %v", err)
+	}
+
+	pdfPolicies, err := service.LoadPDFPolicies(cfg.PDFPolicy.File)
+	if err != nil {
+		log.Fatalf("Failed to load PDF policies: %v", err)
+	}
+
+	intakeRepo := repository.NewIntakeRepository(db)
+	teamRepo := repository.NewTeamRepository(db)
+
+	var registry validation.Registry
+	switch cfg.Validation.Registry {
+	case "database":
+		registry = service.NewIntakeRegistry(intakeRepo, teamRepo)
+	case "file":
+		registry, err = validation.LoadRegistry(cfg.Validation.RegistryFile)
+		if err != nil {
+			log.Fatalf("Failed to load intake registry: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown intake registry %q, expected database or file", cfg.Validation.Registry)
+	}
+
+	plagiarismThresholds, err := service.LoadPlagiarismThresholds(cfg.Plagiarism.ThresholdsFile, cfg.Plagiarism.Threshold)
+	if err != nil {
+		log.Fatalf("Failed to load plagiarism thresholds: %v", err)
+	}
+	similarityThresholds, err := service.LoadPlagiarismThresholds(cfg.Similarity.ThresholdsFile, cfg.Similarity.Threshold)
+	if err != nil {
+		log.Fatalf("Failed to load similarity thresholds: %v", err)
+	}
+	codeSimilarityThresholds, err := service.LoadPlagiarismThresholds(cfg.CodeSimilarity.ThresholdsFile, cfg.CodeSimilarity.Threshold)
+	if err != nil {
+		log.Fatalf("Failed to load code similarity thresholds: %v", err)
+	}
+
+	plagiarism, err := service.LoadPlagiarismCheckers(
+		cfg.Plagiarism.ProvidersFile,
+		strings.Split(cfg.Plagiarism.Providers, ","),
+		cfg.Plagiarism.APIEndpoint,
+		service.PlagiarismOptions{
+			Timeout: cfg.Plagiarism.Timeout,
+			Retry: service.RetryPolicy{
+				Retries:   cfg.Plagiarism.Retries,
+				BaseDelay: cfg.Plagiarism.RetryDelay,
+				MaxDelay:  cfg.Plagiarism.RetryMaxDelay,
+			},
+			BreakerFailures: cfg.Plagiarism.BreakerFailures,
+			BreakerCooldown: cfg.Plagiarism.BreakerCooldown,
+		},
+	)
+	if err != nil {
+		log.Fatalf("Failed to configure plagiarism providers: %v", err)
+	}
+
+	reviewRepo := repository.NewPlagiarismReviewRepository(db)
+	signatureRepo := repository.NewSignatureRepository(db)
+	codeSignatureRepo := repository.NewCodeSignatureRepository(db)
+	similarityEngine := service.NewSimilarityEngine(signatureRepo, cfg.Similarity.TopMatches)
+	go func() {
+		// Index files stored before the engine existed so new uploads are
+		// compared against them too
+		indexed, err := similarityEngine.Backfill(context.Background(), 100)
+		if err != nil {
+			log.Printf("Warning: similarity backfill stopped early: %v", err)
+		}
+		if indexed > 0 {
+			log.Printf("Indexed %d earlier files for similarity checks", indexed)
+		}
+	}()
+
+	topicEngine := service.NewTopicEngine(repository.NewTopicRepository(db),
+		strings.Split(cfg.Topic.CorpusDocTypes, ","), cfg.Topic.TopMatches)
+	go func() {
+		indexed, err := topicEngine.Backfill(context.Background(), 100)
+		if err != nil {
+			log.Printf("Warning: topic backfill stopped early: %v", err)
+		}
+		if indexed > 0 {
+			log.Printf("Indexed %d earlier files for topic checks", indexed)
+		}
+	}()
+
+	searchRepo := repository.NewSearchRepository(db)
+	go func() {
+		// Files stored before search existed, or whose indexing failed
+		indexed, err := searchRepo.IndexMissing(100)
+		if err != nil {
+			log.Printf("Warning: search backfill stopped early: %v", err)
+		}
+		if indexed > 0 {
+			log.Printf("Indexed %d earlier files for search", indexed)
+		}
+	}()
+
+	codeSimilarityEngine := service.NewCodeSimilarityEngine(codeSignatureRepo, cfg.CodeSimilarity.TopMatches)
+
+	// Stages are registered by name and picked per file type in the config
+	stages := pipeline.NewRegistry()
+	stages.Register(pipeline.NewTypeDetector(strings.Split(cfg.Upload.AllowedFileTypes, ",")))
+	stages.Register(pipeline.NewSizePolicy(cfg.Upload.MaxUploadSizeMB << 20))
+	stages.Register(pipeline.NewScanStage(scanner, quarantine, cfg.Scanner.InfectedAction, cfg.Scanner.OversizeAction))
+	stages.Register(pipeline.NewPDFMetadataStage(service.NewPDFExtractor()))
+	stages.Register(pipeline.NewPDFPolicyStage(pdfPolicies))
+	stages.Register(pipeline.NewSimilarityStage(similarityEngine, similarityThresholds, quarantine, reviewRepo))
+	stages.Register(pipeline.NewTopicStage(topicEngine, strings.Split(cfg.Topic.DocTypes, ","),
+		cfg.Topic.WarnThreshold, cfg.Topic.BlockThreshold))
+	stages.Register(pipeline.NewCodeSimilarityStage(codeSimilarityEngine, codeSimilarityThresholds, quarantine, reviewRepo))
+	stages.Register(pipeline.NewPlagiarismStage(plagiarism, plagiarismThresholds, quarantine, reviewRepo, cfg.Plagiarism.UnavailableAction))
+
+	processor, err := pipeline.New(stages, map[utils.FileType][]string{
+		utils.PDF:     pipeline.ParseStages(cfg.Pipeline.PDFStages),
+		utils.Archive: pipeline.ParseStages(cfg.Pipeline.ArchiveStages),
+	})
+	if err != nil {
+		log.Fatalf("Failed to build processing pipeline: %v", err)
+	}
+
+	events := progress.NewBroker(cfg.Progress.HistorySize, cfg.Progress.Retention)
+	events.StartCleanup(context.Background(), time.Minute)
+	uploadProgress := middleware.NewUploadProgress(events)
+
+	idempotency := middleware.NewIdempotency(
+		repository.NewIdempotencyRepository(db),
+		cfg.Idempotency.TTL,
+		cfg.Idempotency.LockTimeout,
+		cfg.Idempotency.Wait,
+		cfg.Upload.Dir,
+		cfg.Upload.MaxUploadSizeMB<<20,
+	)
+	idempotency.StartCleanup(context.Background(), cfg.Idempotency.CleanupInterval)
+
+	if cfg.Integrity.VerifyInterval > 0 {
+		verifier := service.NewIntegrityVerifier(awsService, fileRepo, cfg.Integrity.BatchSize, cfg.Integrity.ReverifyAfter)
+		verifier.Start(context.Background(), cfg.Integrity.VerifyInterval)
+	}
+
+	if cfg.Plagiarism.RecheckInterval > 0 {
+		rechecker := service.NewPlagiarismRechecker(awsService, fileRepo, plagiarism,
+			plagiarismThresholds, cfg.Plagiarism.RecheckBatchSize, cfg.Upload.Dir)
+		rechecker.Start(context.Background(), cfg.Plagiarism.RecheckInterval)
+	}
+
+	if cfg.Plagiarism.ExpiryInterval > 0 {
+		expirer := service.NewReviewExpirer(reviewRepo, quarantine, 100)
+		expirer.Start(context.Background(), cfg.Plagiarism.ExpiryInterval)
+	}
+
+	matrixRepo := repository.NewSimilarityMatrixRepository(db)
+	matrices := service.NewSimilarityMatrixBuilder(matrixRepo, signatureRepo, codeSignatureRepo,
+		cfg.Upload.WorkerID, cfg.Upload.JobLease)
+	if err := matrices.Resume(context.Background()); err != nil {
+		log.Printf("Warning: failed to resume similarity matrices: %v", err)
+	}
 
 	r := chi.NewRouter()
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key", "X-Client-ID", "X-Upload-ID", "Last-Event-ID", "Content-MD5", "X-Checksum-SHA256"},
+		ExposedHeaders:   []string{"Link", "Idempotent-Replayed"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -63,12 +237,51 @@ func main() {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	uploadController := controller.NewUploadController(cfg, awsService, fileRepo)
+	uploadController := controller.NewUploadController(cfg, awsService, fileRepo, submissionRepo, jobRepo, repository.NewPlagiarismReportRepository(db), reviewRepo, matrixRepo, teamRepo, searchRepo, quarantine, matrices, processor, events, registry)
+	uploadController.StartWorkers(context.Background(), cfg.Upload.Workers)
+	intakeController := controller.NewIntakeController(cfg, intakeRepo, teamRepo, repository.NewMemberRepository(db), repository.NewProjectRepository(db), searchRepo)
+
 	r.Route("/api/v1", func(v1 chi.Router) {
-		v1.Post("/upload", uploadController.HandleFileUpload)
-		v1.Delete("/files", uploadController.HandleDeleteFile)
+		// Transfer progress is counted before the idempotency middleware spools the body
+		v1.With(uploadProgress.Handler, idempotency.Handler).Post("/upload", uploadController.HandleFileUpload)
+		v1.With(idempotency.Handler).Delete("/files", uploadController.HandleDeleteFile)
 		v1.Get("/bucket/backup", uploadController.HandleDownloadBucket)
 		v1.Get("/download", uploadController.GetFilesByTeamID)
+		v1.Get("/uploads/{id}", uploadController.GetUploadJob)
+		v1.Get("/uploads/{id}/events", uploadController.StreamUploadEvents)
+		v1.Get("/files", uploadController.ListFiles)
+		v1.Get("/search", uploadController.Search)
+		v1.Get("/files/{id}", uploadController.GetFileMetadata)
+		v1.Get("/files/{id}/plagiarism", uploadController.GetFilePlagiarism)
+		v1.With(idempotency.Handler).Post("/files/{id}/plagiarism/override", uploadController.OverrideFilePlagiarism)
+		v1.Get("/intakes/{intake}/plagiarism/flagged", uploadController.ListFlaggedPlagiarism)
+		v1.Get("/intakes/{intake}/plagiarism/reviews", uploadController.ListPlagiarismReviews)
+		v1.Get("/intakes/{intake}/plagiarism/overrides", uploadController.ListPlagiarismOverrides)
+		v1.With(idempotency.Handler).Post("/intakes/{intake}/similarity-matrices", uploadController.CreateSimilarityMatrix)
+		v1.Get("/intakes/{intake}/similarity-matrices", uploadController.ListSimilarityMatrices)
+		v1.Get("/similarity-matrices/{id}", uploadController.GetSimilarityMatrix)
+		v1.Get("/plagiarism/reviews/{id}", uploadController.GetPlagiarismReview)
+		v1.With(idempotency.Handler).Post("/plagiarism/reviews/{id}/override", uploadController.OverridePlagiarismReview)
+		v1.With(uploadProgress.Handler, idempotency.Handler).Post("/submissions", uploadController.HandleBatchSubmission)
+		v1.Get("/submissions/{id}", uploadController.GetSubmission)
+
+		v1.Post("/intakes", intakeController.CreateIntake)
+		v1.Get("/intakes", intakeController.ListIntakes)
+		v1.Get("/intakes/{intake}", intakeController.GetIntake)
+		v1.Put("/intakes/{intake}", intakeController.UpdateIntake)
+		v1.Delete("/intakes/{intake}", intakeController.DeleteIntake)
+		v1.Post("/intakes/{intake}/teams", intakeController.CreateTeam)
+		v1.Get("/intakes/{intake}/teams", intakeController.ListTeams)
+		v1.Get("/intakes/{intake}/teams/{team}", intakeController.GetTeam)
+		v1.Put("/intakes/{intake}/teams/{team}", intakeController.UpdateTeam)
+		v1.Delete("/intakes/{intake}/teams/{team}", intakeController.DeleteTeam)
+		v1.Post("/intakes/{intake}/teams/{team}/members", intakeController.AddMember)
+		v1.Get("/intakes/{intake}/teams/{team}/members", intakeController.ListMembers)
+		v1.Put("/intakes/{intake}/teams/{team}/members/{id}", intakeController.UpdateMember)
+		v1.Delete("/intakes/{intake}/teams/{team}/members/{id}", intakeController.RemoveMember)
+		v1.Get("/intakes/{intake}/teams/{team}/project", intakeController.GetProject)
+		v1.Put("/intakes/{intake}/teams/{team}/project", intakeController.SaveProject)
+		v1.Delete("/intakes/{intake}/teams/{team}/project", intakeController.DeleteProject)
 	})
 
 	log.Printf("Server starting on port %s", cfg.Server.Port)
@@ -0,0 +1,131 @@
+// Command migrate manages the database schema with the SQL migrations in
+// internal/migrate/migrations:
+//
+//	migrate up             apply every pending migration
+//	migrate down [n]       revert the last n migrations (default 1)
+//	migrate status         list migrations and when they were applied
+//	migrate create <name>  add an empty up and down migration to -dir
+//
+// The API refuses to start until pending migrations are applied.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/sohan-reza/capstone-core/internal/config"
+	"github.com/sohan-reza/capstone-core/internal/database"
+	"github.com/sohan-reza/capstone-core/internal/migrate"
+)
+
+func main() {
+	dir := flag.String("dir", "internal/migrate/migrations", "directory create writes new migrations to")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: migrate [-dir path] up | down [n] | status | create <name>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("Usage: migrate create <name>")
+		}
+		up, down, err := migrate.Create(*dir, args[1])
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		log.Printf("Created %s and %s", up, down)
+		return
+	}
+
+	cfg, err := config.LoadConfig(".")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Database.Driver == database.SQLite {
+		log.Fatal("Migrations are for Postgres; SQLite schemas are created when the API starts")
+	}
+	db, err := database.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	// A migration interrupted with Ctrl-C is rolled back
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("Applied %s", m)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Printf("Schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of migrations to revert: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted %s", m)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Printf("No migrations to revert")
+		}
+
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read schema status: %v", err)
+		}
+		for _, s := range states {
+			switch {
+			case s.Unknown:
+				fmt.Printf("%-40s applied %s (unknown to this build)\n", s, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			case s.AppliedAt != nil:
+				fmt.Printf("%-40s applied %s\n", s, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Printf("%-40s pending\n", s)
+			}
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,82 @@
+// Command rekey moves stored objects to the key layout in
+// STORAGE_KEY_LAYOUT, or the one given with -layout, using server-side
+// copies. Progress is kept in the rekey_moves table so an interrupted run
+// can simply be started again.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/sohan-reza/capstone-core/internal/config"
+	"github.com/sohan-reza/capstone-core/internal/database"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+func main() {
+	cfg, err := config.LoadConfig(".")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	layout := flag.String("layout", cfg.Storage.KeyLayout, "key layout to move objects to")
+	batchSize := flag.Int("batch", 100, "files planned and moved per batch")
+	keepSource := flag.Bool("keep-source", false, "leave objects at their old keys")
+	dryRun := flag.Bool("dry-run", false, "only list the moves that would be made")
+	flag.Parse()
+
+	keys, err := service.NewKeyBuilder(*layout)
+	if err != nil {
+		log.Fatalf("Invalid storage key layout: %v", err)
+	}
+
+	awsService, err := service.NewAWSService(cfg.AWS.BucketName, cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, keys)
+	if err != nil {
+		log.Fatalf("Failed to initialize AWS service: %v", err)
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := database.Prepare(context.Background(), db); err != nil {
+		log.Fatalf("Database schema check failed: %v", err)
+	}
+
+	moves := repository.NewRekeyRepository(db)
+	rekeyer := service.NewRekeyer(awsService, moves, keys, *batchSize)
+	rekeyer.KeepSource = *keepSource
+
+	// Stop between moves on Ctrl-C; the next run resumes from there
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Re-keying objects to %s", keys.Layout())
+
+	if *dryRun {
+		report, err := rekeyer.Preview(ctx)
+		if err != nil {
+			log.Fatalf("Failed to plan moves: %v", err)
+		}
+		log.Printf("Would move %d files, %d already match the layout", report.Planned, report.Unchanged)
+		return
+	}
+
+	report, err := rekeyer.Run(ctx)
+	log.Printf("Planned %d moves (%d files already matched), moved %d, skipped %d",
+		report.Planned, report.Unchanged, report.Moved, report.Skipped)
+	if err != nil {
+		log.Fatalf("Re-keying stopped, run again to resume: %v", err)
+	}
+
+	counts, err := moves.Counts(keys.Layout())
+	if err != nil {
+		log.Fatalf("Failed to count moves: %v", err)
+	}
+	log.Printf("Moves for this layout: %d done, %d skipped", counts[model.RekeyDone], counts[model.RekeySkipped])
+}
@@ -0,0 +1,204 @@
+package codesim
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedArchive is returned for archive formats that cannot be
+	// read, such as rar and 7z.
+	ErrUnsupportedArchive = errors.New("unsupported archive format")
+	// ErrInvalidArchive is returned for archives that are corrupt.
+	ErrInvalidArchive = errors.New("invalid archive")
+)
+
+// Limits bounds the work done for one archive. Entries beyond MaxEntries or
+// MaxTotal are not read, and files larger than MaxFileSize are skipped.
+type Limits struct {
+	MaxEntries  int
+	MaxFileSize int64
+	MaxTotal    int64
+}
+
+var DefaultLimits = Limits{
+	MaxEntries:  5000,
+	MaxFileSize: 512 << 10,
+	MaxTotal:    50 << 20,
+}
+
+// Source is a fingerprinted source file from an archive.
+type Source struct {
+	Path         string
+	Language     Language
+	Lines        int
+	Fingerprints []Fingerprint
+}
+
+// NewSource tokenizes and fingerprints the contents of a source file.
+func NewSource(name string, lang Language, data []byte) Source {
+	return Source{
+		Path:         name,
+		Language:     lang,
+		Lines:        bytes.Count(data, []byte("\n")) + 1,
+		Fingerprints: Winnow(Tokenize(lang, data)),
+	}
+}
+
+// skippedDirs hold dependencies, build output and tooling state rather than
+// code a team wrote.
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+	"__pycache__": true, "venv": true, ".venv": true, "target": true, "__MACOSX": true,
+}
+
+// sourceLanguage returns the language of an archive entry worth comparing.
+func sourceLanguage(name string) (Language, bool) {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if skippedDirs[dir] {
+			return "", false
+		}
+	}
+	if strings.HasSuffix(strings.ToLower(name), ".min.js") {
+		return "", false
+	}
+	return DetectLanguage(name)
+}
+
+// Extract reads the source files of a zip, tar or gzipped tar archive and
+// fingerprints each one. Files without fingerprints are left out.
+func Extract(archivePath string, limits Limits) ([]Source, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	x := &extractor{limits: limits}
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		err = x.zip(f, info.Size())
+		return x.sources, err
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return nil, fmt.Errorf("%w: gzip: %v", ErrInvalidArchive, err)
+		}
+		defer gz.Close()
+		err = x.tar(gz)
+		return x.sources, err
+	case len(header) > 262 && string(header[257:262]) == "ustar":
+		err = x.tar(f)
+		return x.sources, err
+	}
+	return nil, ErrUnsupportedArchive
+}
+
+type extractor struct {
+	limits  Limits
+	entries int
+	total   int64
+	sources []Source
+}
+
+// full reports whether the archive limits were reached.
+func (x *extractor) full() bool {
+	return x.entries >= x.limits.MaxEntries || x.total >= x.limits.MaxTotal
+}
+
+func (x *extractor) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: zip: %v", ErrInvalidArchive, err)
+	}
+	for _, entry := range zr.File {
+		if x.full() {
+			return nil
+		}
+		x.entries++
+		if entry.FileInfo().IsDir() || entry.UncompressedSize64 > uint64(x.limits.MaxFileSize) {
+			continue
+		}
+		// Archives made on Windows may separate directories with backslashes
+		name := strings.ReplaceAll(entry.Name, "\\", "/")
+		lang, ok := sourceLanguage(name)
+		if !ok {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, entry.Name, err)
+		}
+		err = x.add(name, lang, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for !x.full() {
+		entry, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: tar: %v", ErrInvalidArchive, err)
+		}
+		x.entries++
+		if entry.Typeflag != tar.TypeReg || entry.Size > x.limits.MaxFileSize {
+			continue
+		}
+		lang, ok := sourceLanguage(entry.Name)
+		if !ok {
+			continue
+		}
+		if err := x.add(entry.Name, lang, tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add reads one source file, which may not be larger than its header
+// claimed, and fingerprints it.
+func (x *extractor) add(name string, lang Language, r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, x.limits.MaxFileSize+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	if int64(len(data)) > x.limits.MaxFileSize {
+		return nil
+	}
+	x.total += int64(len(data))
+
+	source := NewSource(path.Clean(strings.TrimPrefix(name, "./")), lang, data)
+	if len(source.Fingerprints) > 0 {
+		x.sources = append(x.sources, source)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+// Package codesim finds source code shared between archives the way MOSS
+// does. Source files are tokenized with identifiers and literals replaced by
+// placeholders, so renaming variables does not hide a copy; the token stream
+// is hashed in overlapping k-grams and winnowing keeps a small, position
+// independent subset of the hashes as the file's fingerprints.
+package codesim
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Language string
+
+const (
+	Go         Language = "go"
+	Python     Language = "python"
+	Java       Language = "java"
+	JavaScript Language = "javascript"
+	C          Language = "c"
+	CPP        Language = "cpp"
+)
+
+var extensions = map[string]Language{
+	".go":   Go,
+	".py":   Python,
+	".java": Java,
+	".js":   JavaScript,
+	".jsx":  JavaScript,
+	".mjs":  JavaScript,
+	".ts":   JavaScript,
+	".tsx":  JavaScript,
+	".c":    C,
+	".h":    C,
+	".cc":   CPP,
+	".cpp":  CPP,
+	".cxx":  CPP,
+	".hpp":  CPP,
+	".hh":   CPP,
+}
+
+// DetectLanguage returns the language of a source file by its extension.
+func DetectLanguage(path string) (Language, bool) {
+	lang, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	return lang, ok
+}
+
+// Token is a normalized token and the line it starts on.
+type Token struct {
+	Text string
+	Line int
+}
+
+// Placeholders for normalized tokens.
+const (
+	identToken  = "V"
+	numberToken = "N"
+	stringToken = "S"
+)
+
+var keywords = map[Language][]string{
+	Go: {"break", "case", "chan", "const", "continue", "default", "defer", "else",
+		"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map",
+		"package", "range", "return", "select", "struct", "switch", "type", "var",
+		"nil", "true", "false", "make", "new", "len", "cap", "append"},
+	Python: {"and", "as", "assert", "async", "await", "break", "class", "continue",
+		"def", "del", "elif", "else", "except", "finally", "for", "from", "global",
+		"if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise",
+		"return", "try", "while", "with", "yield", "None", "True", "False", "self"},
+	Java: {"abstract", "boolean", "break", "byte", "case", "catch", "char", "class",
+		"continue", "default", "do", "double", "else", "enum", "extends", "final",
+		"finally", "float", "for", "if", "implements", "import", "instanceof", "int",
+		"interface", "long", "new", "package", "private", "protected", "public",
+		"return", "short", "static", "super", "switch", "synchronized", "this",
+		"throw", "throws", "try", "void", "volatile", "while", "null", "true", "false"},
+	JavaScript: {"async", "await", "break", "case", "catch", "class", "const",
+		"continue", "default", "delete", "do", "else", "export", "extends", "finally",
+		"for", "from", "function", "if", "import", "in", "instanceof", "let", "new",
+		"of", "return", "super", "switch", "this", "throw", "try", "typeof", "var",
+		"void", "while", "yield", "null", "undefined", "true", "false"},
+	C: {"auto", "break", "case", "char", "const", "continue", "default", "do",
+		"double", "else", "enum", "extern", "float", "for", "goto", "if", "int",
+		"long", "register", "return", "short", "signed", "sizeof", "static",
+		"struct", "switch", "typedef", "union", "unsigned", "void", "volatile",
+		"while", "NULL"},
+}
+
+var keywordSets = func() map[Language]map[string]bool {
+	// C++ is C plus its own keywords
+	keywords[CPP] = append(append([]string{}, keywords[C]...),
+		"bool", "catch", "class", "delete", "false", "namespace", "new", "nullptr",
+		"operator", "private", "protected", "public", "template", "this", "throw",
+		"true", "try", "typename", "using", "virtual")
+
+	sets := make(map[Language]map[string]bool, len(keywords))
+	for lang, words := range keywords {
+		set := make(map[string]bool, len(words))
+		for _, w := range words {
+			set[w] = true
+		}
+		sets[lang] = set
+	}
+	return sets
+}()
+
+// Tokenize splits source code into normalized tokens. Comments, whitespace,
+// C preprocessor lines and import or package declarations are dropped, as
+// they are shared by unrelated projects. Keywords and operators are kept,
+// identifiers become V, numbers N and string literals S.
+func Tokenize(lang Language, src []byte) []Token {
+	l := &lexer{src: string(src), line: 1, lang: lang, keywords: keywordSets[lang]}
+	return dropDeclarations(lang, l.run())
+}
+
+type lexer struct {
+	src      string
+	pos      int
+	line     int
+	lang     Language
+	keywords map[string]bool
+	tokens   []Token
+	// lineStart is set while only whitespace has been seen on the line
+	lineStart bool
+}
+
+func (l *lexer) run() []Token {
+	l.lineStart = true
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+			l.lineStart = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+			continue
+		}
+
+		startOfLine := l.lineStart
+		l.lineStart = false
+
+		switch {
+		case l.hasPrefix("//") && l.lang != Python:
+			l.skipLine()
+		case c == '#' && (l.lang == Python || (startOfLine && (l.lang == C || l.lang == CPP))):
+			l.skipLine()
+		case l.hasPrefix("/*") && l.lang != Python:
+			l.skipUntil("*/")
+		case l.lang == Python && (l.hasPrefix(`"""`) || l.hasPrefix("'''")):
+			quote := l.src[l.pos : l.pos+3]
+			l.emit(stringToken)
+			l.pos += 3
+			l.skipUntil(quote)
+		case c == '"' || c == '\'' || (c == '`' && (l.lang == Go || l.lang == JavaScript)):
+			l.emit(stringToken)
+			l.skipString(c)
+		case c >= '0' && c <= '9':
+			l.emit(numberToken)
+			l.skipWhile(func(r rune) bool { return r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+		case c == '_' || c == '$' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)):
+			start := l.pos
+			l.skipWhile(func(r rune) bool { return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+			if l.pos == start {
+				// A symbol outside ASCII; take it as punctuation
+				_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+				l.emit(l.src[l.pos : l.pos+size])
+				l.pos += size
+				continue
+			}
+			word := l.src[start:l.pos]
+			if l.keywords[word] {
+				l.tokens = append(l.tokens, Token{Text: word, Line: l.line})
+			} else {
+				l.tokens = append(l.tokens, Token{Text: identToken, Line: l.line})
+			}
+		default:
+			l.emit(l.operator())
+		}
+	}
+	return l.tokens
+}
+
+// operators lists multi-character operators, longest first.
+var operators = []string{
+	">>>=", "<<=", ">>=", "===", "!==", "...", "**=", "//=", "&^=", "->*",
+	"==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=",
+	"&=", "|=", "^=", "<<", ">>", "->", "::", ":=", "=>", "**", "//", "<-", "&^",
+}
+
+func (l *lexer) operator() string {
+	for _, op := range operators {
+		if l.hasPrefix(op) {
+			l.pos += len(op)
+			return op
+		}
+	}
+	op := l.src[l.pos : l.pos+1]
+	l.pos++
+	return op
+}
+
+func (l *lexer) emit(text string) {
+	l.tokens = append(l.tokens, Token{Text: text, Line: l.line})
+}
+
+func (l *lexer) hasPrefix(s string) bool {
+	return strings.HasPrefix(l.src[l.pos:], s)
+}
+
+func (l *lexer) skipLine() {
+	for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+		l.pos++
+	}
+}
+
+func (l *lexer) skipUntil(end string) {
+	l.pos += len(end)
+	for l.pos < len(l.src) {
+		if l.hasPrefix(end) {
+			l.pos += len(end)
+			return
+		}
+		if l.src[l.pos] == '\n' {
+			l.line++
+		}
+		l.pos++
+	}
+}
+
+// skipString skips a literal opened by quote. Only backquoted strings may
+// span lines; other literals end at the line end if unterminated.
+func (l *lexer) skipString(quote byte) {
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && quote != '`':
+			l.pos += 2
+			continue
+		case c == quote:
+			l.pos++
+			return
+		case c == '\n':
+			if quote != '`' {
+				return
+			}
+			l.line++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) skipWhile(accept func(rune) bool) {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !accept(r) {
+			return
+		}
+		l.pos += size
+	}
+}
+
+// dropDeclarations removes package, import and include statements.
+func dropDeclarations(lang Language, tokens []Token) []Token {
+	out := tokens[:0]
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if !startsDeclaration(lang, tokens, i) {
+			out = append(out, t)
+			continue
+		}
+
+		switch {
+		case lang == Go && i+1 < len(tokens) && tokens[i+1].Text == "(":
+			// import ( ... )
+			for i < len(tokens) && tokens[i].Text != ")" {
+				i++
+			}
+		case lang == Go || lang == Python:
+			// The statement ends with its line
+			for i+1 < len(tokens) && tokens[i+1].Line == t.Line {
+				i++
+			}
+		default:
+			for i < len(tokens) && tokens[i].Text != ";" {
+				if i+1 < len(tokens) && tokens[i+1].Line != tokens[i].Line && lang == JavaScript {
+					// JavaScript statements may end without a semicolon
+					break
+				}
+				i++
+			}
+		}
+	}
+	return out
+}
+
+func startsDeclaration(lang Language, tokens []Token, i int) bool {
+	atLineStart := i == 0 || tokens[i-1].Line != tokens[i].Line
+	switch tokens[i].Text {
+	case "package":
+		return lang == Go || lang == Java
+	case "import":
+		return lang != C && lang != CPP && atLineStart
+	case "from":
+		return lang == Python && atLineStart
+	case "using":
+		return lang == CPP && atLineStart && i+1 < len(tokens) && tokens[i+1].Text == "namespace"
+	}
+	return false
+}
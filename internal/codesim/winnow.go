@@ -0,0 +1,98 @@
+package codesim
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+const (
+	// K is the number of tokens hashed together. Shorter runs of shared
+	// tokens are never reported.
+	K = 12
+	// Window is the winnowing window. Any shared run of at least
+	// K+Window-1 tokens is guaranteed to share a fingerprint.
+	Window = 8
+)
+
+// Fingerprint is a selected k-gram hash and the lines its tokens span.
+type Fingerprint struct {
+	Hash      int64
+	StartLine int
+	EndLine   int
+}
+
+// Winnow hashes every K consecutive tokens and keeps the smallest hash of
+// each Window consecutive hashes, the rightmost on ties, recording each
+// selected position once.
+func Winnow(tokens []Token) []Fingerprint {
+	if len(tokens) < K {
+		return nil
+	}
+
+	hashes := make([]int64, len(tokens)-K+1)
+	for i := range hashes {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+K] {
+			h.Write([]byte(t.Text))
+			h.Write([]byte{0})
+		}
+		hashes[i] = int64(h.Sum64())
+	}
+
+	var fingerprints []Fingerprint
+	selected := -1
+	window := min(Window, len(hashes))
+	for start := 0; start+window <= len(hashes); start++ {
+		best := start
+		for i := start + 1; i < start+window; i++ {
+			if hashes[i] <= hashes[best] {
+				best = i
+			}
+		}
+		if best != selected {
+			selected = best
+			fingerprints = append(fingerprints, Fingerprint{
+				Hash:      hashes[best],
+				StartLine: tokens[best].Line,
+				EndLine:   tokens[best+K-1].Line,
+			})
+		}
+	}
+	return fingerprints
+}
+
+// LineRange pairs lines of one file with the lines of another file they
+// match.
+type LineRange struct {
+	Start        int `json:"start"`
+	End          int `json:"end"`
+	MatchedStart int `json:"matched_start"`
+	MatchedEnd   int `json:"matched_end"`
+}
+
+// MergeRanges sorts ranges and joins those that overlap or touch on both
+// sides.
+func MergeRanges(ranges []LineRange) []LineRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Start != ranges[j].Start {
+			return ranges[i].Start < ranges[j].Start
+		}
+		return ranges[i].MatchedStart < ranges[j].MatchedStart
+	})
+
+	merged := []LineRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End+1 && r.MatchedStart <= last.MatchedEnd+1 && r.MatchedEnd >= last.MatchedStart-1 {
+			last.End = max(last.End, r.End)
+			last.MatchedStart = min(last.MatchedStart, r.MatchedStart)
+			last.MatchedEnd = max(last.MatchedEnd, r.MatchedEnd)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
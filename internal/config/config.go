@@ -16,6 +16,9 @@ type Config struct {
 	} `mapstructure:"SERVER"`
 
 	Database struct {
+		// Driver is postgres or sqlite; Path is the SQLite database file
+		Driver   string `mapstructure:"DB_DRIVER"`
+		Path     string `mapstructure:"DB_PATH"`
 		Host     string `mapstructure:"DB_HOST"`
 		Port     string `mapstructure:"DB_PORT"`
 		User     string `mapstructure:"DB_USER"`
@@ -31,16 +34,107 @@ type Config struct {
 		SecretAccessKey string `mapstructure:"AWS_SECRET_ACCESS_KEY"`
 	} `mapstructure:"AWS"`
 
+	Storage struct {
+		KeyLayout string `mapstructure:"STORAGE_KEY_LAYOUT"`
+	} `mapstructure:"STORAGE"`
+
 	Upload struct {
 		Dir              string `mapstructure:"UPLOAD_DIR"`
 		MaxUploadSizeMB  int64  `mapstructure:"MAX_UPLOAD_SIZE_MB"`
 		AllowedFileTypes string `mapstructure:"ALLOWED_FILE_TYPES"`
+		Workers          int    `mapstructure:"UPLOAD_WORKERS"`
+		QueueSize        int    `mapstructure:"UPLOAD_QUEUE_SIZE"`
+		// WorkerID names this instance as the owner of the jobs it runs
+		WorkerID string        `mapstructure:"UPLOAD_WORKER_ID"`
+		JobLease time.Duration `mapstructure:"UPLOAD_JOB_LEASE"`
 	} `mapstructure:"UPLOAD"`
 
 	Plagiarism struct {
-		APIEndpoint string `mapstructure:"PLAGIARISM_API_ENDPOINT"`
-		Threshold   int    `mapstructure:"PLAGIARISM_THRESHOLD"`
+		APIEndpoint       string        `mapstructure:"PLAGIARISM_API_ENDPOINT"`
+		Threshold         float64       `mapstructure:"PLAGIARISM_THRESHOLD"`
+		ThresholdsFile    string        `mapstructure:"PLAGIARISM_THRESHOLDS_FILE"`
+		Supervisors       string        `mapstructure:"PLAGIARISM_SUPERVISORS"`
+		ProvidersFile     string        `mapstructure:"PLAGIARISM_PROVIDERS_FILE"`
+		Providers         string        `mapstructure:"PLAGIARISM_PROVIDERS"`
+		Timeout           time.Duration `mapstructure:"PLAGIARISM_TIMEOUT"`
+		UnavailableAction string        `mapstructure:"PLAGIARISM_UNAVAILABLE_ACTION"`
+		Retries           int           `mapstructure:"PLAGIARISM_RETRIES"`
+		RetryDelay        time.Duration `mapstructure:"PLAGIARISM_RETRY_DELAY"`
+		RetryMaxDelay     time.Duration `mapstructure:"PLAGIARISM_RETRY_MAX_DELAY"`
+		BreakerFailures   int           `mapstructure:"PLAGIARISM_BREAKER_FAILURES"`
+		BreakerCooldown   time.Duration `mapstructure:"PLAGIARISM_BREAKER_COOLDOWN"`
+		RecheckInterval   time.Duration `mapstructure:"PLAGIARISM_RECHECK_INTERVAL"`
+		RecheckBatchSize  int           `mapstructure:"PLAGIARISM_RECHECK_BATCH_SIZE"`
+		BatchReviewTTL    time.Duration `mapstructure:"PLAGIARISM_BATCH_REVIEW_TTL"`
+		ExpiryInterval    time.Duration `mapstructure:"PLAGIARISM_REVIEW_EXPIRY_INTERVAL"`
 	} `mapstructure:"PLAGIARISM"`
+
+	Validation struct {
+		Registry     string `mapstructure:"VALIDATION_REGISTRY"`
+		RegistryFile string `mapstructure:"VALIDATION_REGISTRY_FILE"`
+		Sessions     string `mapstructure:"VALIDATION_SESSIONS"`
+	} `mapstructure:"VALIDATION"`
+
+	Pipeline struct {
+		PDFStages     string `mapstructure:"PIPELINE_PDF_STAGES"`
+		ArchiveStages string `mapstructure:"PIPELINE_ARCHIVE_STAGES"`
+	} `mapstructure:"PIPELINE"`
+
+	Similarity struct {
+		Threshold      float64 `mapstructure:"SIMILARITY_THRESHOLD"`
+		ThresholdsFile string  `mapstructure:"SIMILARITY_THRESHOLDS_FILE"`
+		TopMatches     int     `mapstructure:"SIMILARITY_TOP_MATCHES"`
+	} `mapstructure:"SIMILARITY"`
+
+	Topic struct {
+		DocTypes       string  `mapstructure:"TOPIC_DOC_TYPES"`
+		CorpusDocTypes string  `mapstructure:"TOPIC_CORPUS_DOC_TYPES"`
+		WarnThreshold  float64 `mapstructure:"TOPIC_WARN_THRESHOLD"`
+		BlockThreshold float64 `mapstructure:"TOPIC_BLOCK_THRESHOLD"`
+		TopMatches     int     `mapstructure:"TOPIC_TOP_MATCHES"`
+	} `mapstructure:"TOPIC"`
+
+	CodeSimilarity struct {
+		Threshold      float64 `mapstructure:"CODE_SIMILARITY_THRESHOLD"`
+		ThresholdsFile string  `mapstructure:"CODE_SIMILARITY_THRESHOLDS_FILE"`
+		TopMatches     int     `mapstructure:"CODE_SIMILARITY_TOP_MATCHES"`
+	} `mapstructure:"CODE_SIMILARITY"`
+
+	SimilarityMatrix struct {
+		DocTypes string  `mapstructure:"SIMILARITY_MATRIX_DOC_TYPES"`
+		Cutoff   float64 `mapstructure:"SIMILARITY_MATRIX_CUTOFF"`
+	} `mapstructure:"SIMILARITY_MATRIX"`
+
+	PDFPolicy struct {
+		File string `mapstructure:"PDF_POLICY_FILE"`
+	} `mapstructure:"PDF_POLICY"`
+
+	Scanner struct {
+		Enabled        bool          `mapstructure:"SCANNER_ENABLED"`
+		ClamdAddress   string        `mapstructure:"CLAMD_ADDRESS"`
+		Timeout        time.Duration `mapstructure:"SCANNER_TIMEOUT"`
+		InfectedAction string        `mapstructure:"SCANNER_INFECTED_ACTION"`
+		OversizeAction string        `mapstructure:"SCANNER_OVERSIZE_ACTION"`
+		QuarantineDir  string        `mapstructure:"QUARANTINE_DIR"`
+	} `mapstructure:"SCANNER"`
+
+	Progress struct {
+		HistorySize int           `mapstructure:"PROGRESS_HISTORY_SIZE"`
+		Retention   time.Duration `mapstructure:"PROGRESS_RETENTION"`
+	} `mapstructure:"PROGRESS"`
+
+	Integrity struct {
+		VerifyInterval time.Duration `mapstructure:"INTEGRITY_VERIFY_INTERVAL"`
+		BatchSize      int           `mapstructure:"INTEGRITY_BATCH_SIZE"`
+		ReverifyAfter  time.Duration `mapstructure:"INTEGRITY_REVERIFY_AFTER"`
+	} `mapstructure:"INTEGRITY"`
+
+	Idempotency struct {
+		TTL             time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+		LockTimeout     time.Duration `mapstructure:"IDEMPOTENCY_LOCK_TIMEOUT"`
+		Wait            time.Duration `mapstructure:"IDEMPOTENCY_WAIT"`
+		CleanupInterval time.Duration `mapstructure:"IDEMPOTENCY_CLEANUP_INTERVAL"`
+	} `mapstructure:"IDEMPOTENCY"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -55,12 +149,128 @@ func LoadConfig(path string) (*Config, error) {
 	viper.SetDefault("SERVER.SERVER_TIMEOUT_WRITE", "15s")
 	viper.SetDefault("UPLOAD.UPLOAD_DIR", "./uploads")
 	viper.SetDefault("UPLOAD.MAX_UPLOAD_SIZE_MB", 100)
-	viper.SetDefault("UPLOAD.ALLOWED_FILE_TYPES", ".pdf,.zip,.tar,.rar")
+	viper.SetDefault("UPLOAD.ALLOWED_FILE_TYPES", ".pdf,.zip,.tar,.gz,.rar,.7z")
+	viper.SetDefault("UPLOAD.UPLOAD_WORKERS", 4)
+	viper.SetDefault("UPLOAD.UPLOAD_QUEUE_SIZE", 100)
+	// Upload jobs and similarity matrices are leased by the instance running
+	// them and renewed while it is up; others take them over once the lease
+	// has expired. The worker ID defaults to the host name, so instances
+	// sharing a host must set their own
+	viper.SetDefault("UPLOAD.UPLOAD_WORKER_ID", "")
+	viper.SetDefault("UPLOAD.UPLOAD_JOB_LEASE", "1m")
+
+	// Placeholders: {year} {academic_year} {intake} {team} {session}
+	// {doc_type} {version} {hash} {uuid} {file}; {year} is the calendar
+	// year. Existing objects are moved with cmd/rekey
+	viper.SetDefault("STORAGE.STORAGE_KEY_LAYOUT", "projects/{year}/{intake}/{team}/{file}")
 
 	viper.SetDefault("PLAGIARISM.PLAGIARISM_API_ENDPOINT", "localhost:8081")
 	viper.SetDefault("PLAGIARISM.PLAGIARISM_THRESHOLD", 15)
+	// The thresholds file sets thresholds per intake and document type;
+	// PLAGIARISM_THRESHOLD applies where it sets none. Files that fail are
+	// held in quarantine until one of the supervisors overrides the check,
+	// any supervisor ID is accepted when the list is empty
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_THRESHOLDS_FILE", "")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_SUPERVISORS", "")
+	// Providers are tried in the listed order; without a providers file the
+	// only one is "default" at PLAGIARISM_API_ENDPOINT. The unavailable
+	// action "skip" stores files unchecked and flags them as pending until
+	// the re-check finds a provider again; "reject" fails the upload
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_PROVIDERS_FILE", "")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_PROVIDERS", "default")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_TIMEOUT", "30s")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_UNAVAILABLE_ACTION", "skip")
+
+	// Each provider is retried after connection errors and 5xx responses,
+	// and skipped for the cooldown once it has failed that many checks in a
+	// row
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_RETRIES", 2)
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_RETRY_DELAY", "500ms")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_RETRY_MAX_DELAY", "5s")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_BREAKER_FAILURES", 5)
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_BREAKER_COOLDOWN", "1m")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_RECHECK_INTERVAL", "10m")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_RECHECK_BATCH_SIZE", 50)
+	// A held file of a batch submission is cleared by the override for the
+	// next submission of the batch. Reviews neither overridden nor used
+	// within the TTL are closed and their quarantined copy discarded
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_BATCH_REVIEW_TTL", "336h")
+	viper.SetDefault("PLAGIARISM.PLAGIARISM_REVIEW_EXPIRY_INTERVAL", "1h")
+
+	// Uploads are checked against the intakes and teams in the "database",
+	// or in a registry "file" instead; with "file" and no registry file any
+	// well-formed intake and team is accepted
+	viper.SetDefault("VALIDATION.VALIDATION_REGISTRY", "database")
+	viper.SetDefault("VALIDATION.VALIDATION_REGISTRY_FILE", "")
+	viper.SetDefault("VALIDATION.VALIDATION_SESSIONS", "spring,summer,fall")
 
-	// Database defaults
+	// Processing stages run after type detection, in order
+	viper.SetDefault("PIPELINE.PIPELINE_PDF_STAGES", "size_policy,scan,pdf_metadata,pdf_policy,similarity,topic_uniqueness,plagiarism")
+	viper.SetDefault("PIPELINE.PIPELINE_ARCHIVE_STAGES", "size_policy,scan,code_similarity")
+
+	// Uploads are compared with earlier submissions of other teams and years;
+	// a threshold of 0 reports the matches without rejecting. The thresholds
+	// file has the format of PLAGIARISM_THRESHOLDS_FILE, with
+	// SIMILARITY_THRESHOLD where it sets none; rejected files are held for
+	// review like failed plagiarism checks
+	viper.SetDefault("SIMILARITY.SIMILARITY_THRESHOLD", 0)
+	viper.SetDefault("SIMILARITY.SIMILARITY_THRESHOLDS_FILE", "")
+	viper.SetDefault("SIMILARITY.SIMILARITY_TOP_MATCHES", 5)
+
+	// Proposals are compared by topic with earlier proposals and final
+	// reports; a threshold of 0 disables the warning or the block
+	viper.SetDefault("TOPIC.TOPIC_DOC_TYPES", "proposal")
+	viper.SetDefault("TOPIC.TOPIC_CORPUS_DOC_TYPES", "proposal,final_report")
+	viper.SetDefault("TOPIC.TOPIC_WARN_THRESHOLD", 50)
+	viper.SetDefault("TOPIC.TOPIC_BLOCK_THRESHOLD", 0)
+	viper.SetDefault("TOPIC.TOPIC_TOP_MATCHES", 5)
+
+	// Source code in archives is compared with other teams' archives from
+	// the same intake and earlier years, with the same threshold semantics
+	// and thresholds file format
+	viper.SetDefault("CODE_SIMILARITY.CODE_SIMILARITY_THRESHOLD", 0)
+	viper.SetDefault("CODE_SIMILARITY.CODE_SIMILARITY_THRESHOLDS_FILE", "")
+	viper.SetDefault("CODE_SIMILARITY.CODE_SIMILARITY_TOP_MATCHES", 5)
+
+	// Intake-wide matrices compare the latest file of each team for these
+	// document types; teams at or above the cutoff are clustered together
+	viper.SetDefault("SIMILARITY_MATRIX.SIMILARITY_MATRIX_DOC_TYPES", "final_report")
+	viper.SetDefault("SIMILARITY_MATRIX.SIMILARITY_MATRIX_CUTOFF", 40)
+
+	viper.SetDefault("PDF_POLICY.PDF_POLICY_FILE", "")
+
+	// Antivirus defaults. clamd's StreamMaxLength (25M by default) must be
+	// at least MAX_UPLOAD_SIZE_MB, or larger uploads cannot be scanned; the
+	// oversize action "reject" fails those uploads and "skip" stores them
+	// unscanned
+	viper.SetDefault("SCANNER.SCANNER_ENABLED", false)
+	viper.SetDefault("SCANNER.CLAMD_ADDRESS", "tcp://localhost:3310")
+	viper.SetDefault("SCANNER.SCANNER_TIMEOUT", "60s")
+	viper.SetDefault("SCANNER.SCANNER_INFECTED_ACTION", "reject")
+	viper.SetDefault("SCANNER.SCANNER_OVERSIZE_ACTION", "reject")
+	viper.SetDefault("SCANNER.QUARANTINE_DIR", "./quarantine")
+
+	// Upload progress events are kept in memory for reconnecting clients
+	viper.SetDefault("PROGRESS.PROGRESS_HISTORY_SIZE", 256)
+	viper.SetDefault("PROGRESS.PROGRESS_RETENTION", "15m")
+
+	// Stored objects are re-read and checked against their digests; an
+	// interval of 0 disables the verification job
+	viper.SetDefault("INTEGRITY.INTEGRITY_VERIFY_INTERVAL", "24h")
+	viper.SetDefault("INTEGRITY.INTEGRITY_BATCH_SIZE", 100)
+	viper.SetDefault("INTEGRITY.INTEGRITY_REVERIFY_AFTER", "720h")
+
+	// Idempotency defaults. Responses are kept for the TTL; a request that
+	// stops renewing its lock for the lock timeout is taken over by a retry
+	viper.SetDefault("IDEMPOTENCY.IDEMPOTENCY_TTL", "24h")
+	viper.SetDefault("IDEMPOTENCY.IDEMPOTENCY_LOCK_TIMEOUT", "1m")
+	viper.SetDefault("IDEMPOTENCY.IDEMPOTENCY_WAIT", "10s")
+	viper.SetDefault("IDEMPOTENCY.IDEMPOTENCY_CLEANUP_INTERVAL", "1h")
+
+	// Database defaults. SQLite runs the API without a database server,
+	// for local development; ":memory:" keeps nothing between runs
+	viper.SetDefault("DATABASE.DB_DRIVER", "postgres")
+	viper.SetDefault("DATABASE.DB_PATH", "./capstone.db")
 	viper.SetDefault("DATABASE.DB_HOST", "localhost")
 	viper.SetDefault("DATABASE.DB_PORT", "5432")
 	viper.SetDefault("DATABASE.DB_USER", "postgres")
@@ -90,5 +300,13 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if config.Upload.WorkerID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		config.Upload.WorkerID = hostname
+	}
+
 	return &config, nil
 }
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+)
+
+const (
+	contentMD5Header     = "Content-MD5"
+	checksumSHA256Header = "X-Checksum-SHA256"
+	contentMD5Field      = "content_md5"
+	checksumSHA256Field  = "checksum_sha256"
+	checksumStage        = "checksum"
+)
+
+// expectedDigest collects the checksums the client sent for a file part.
+// Headers on the part itself take precedence; for single file uploads the
+// request headers and form fields are accepted as well.
+func expectedDigest(r *http.Request, header *multipart.FileHeader, single bool) (utils.FileDigest, error) {
+	md5Value := header.Header.Get(contentMD5Header)
+	sha256Value := header.Header.Get(checksumSHA256Header)
+	if single {
+		md5Value = firstNonEmpty(md5Value, r.FormValue(contentMD5Field), r.Header.Get(contentMD5Header))
+		sha256Value = firstNonEmpty(sha256Value, r.FormValue(checksumSHA256Field), r.Header.Get(checksumSHA256Header))
+	}
+
+	var expected utils.FileDigest
+	var err error
+	if md5Value != "" {
+		if expected.MD5, err = utils.ParseChecksum(md5Value, md5.Size); err != nil {
+			return expected, fmt.Errorf("invalid %s: %w", contentMD5Header, err)
+		}
+	}
+	if sha256Value != "" {
+		if expected.SHA256, err = utils.ParseChecksum(sha256Value, sha256.Size); err != nil {
+			return expected, fmt.Errorf("invalid %s: %w", checksumSHA256Header, err)
+		}
+	}
+	return expected, nil
+}
+
+// verifyDigest rejects a staged file whose content does not match the
+// checksums the client sent.
+func verifyDigest(f *pipeline.File, expected utils.FileDigest) error {
+	algorithm := f.Digest.Mismatch(expected)
+	if algorithm == "" {
+		return nil
+	}
+
+	want, got := expected.SHA256Hex(), f.Digest.SHA256Hex()
+	if algorithm == "md5" {
+		want, got = expected.MD5Hex(), f.Digest.MD5Hex()
+	}
+
+	err := pipeline.Reject(http.StatusBadRequest, "Checksum mismatch", map[string]interface{}{
+		"message":   "The received file does not match the checksum sent with it, please upload it again",
+		"type":      "checksum",
+		"algorithm": algorithm,
+		"expected":  want,
+		"actual":    got,
+	})
+	err.Stage = checksumStage
+	return err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
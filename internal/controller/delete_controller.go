@@ -14,11 +14,11 @@ func (c *UploadController) HandleDeleteFile(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Extract file key from URL path or query parameter
-	fileKey := r.URL.Query().Get("key")
-	if fileKey == "" {
-		http.Error(w, "Missing file key parameter", http.StatusBadRequest)
+	values := c.schemas.deleteFile.Values(r)
+	if !validate(w, c.schemas.deleteFile, values) {
 		return
 	}
+	fileKey := values["key"]
 
 	// Delete from S3
 	if err := c.awsService.DeleteFile(fileKey); err != nil {
@@ -2,22 +2,48 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+
+	"github.com/sohan-reza/capstone-core/internal/repository"
 )
 
+// GetFilesByTeamID lists a team's download links. It is kept for existing
+// clients; GET /files replaces it.
 func (c *UploadController) GetFilesByTeamID(w http.ResponseWriter, r *http.Request) {
-	teamID := r.URL.Query().Get("team_id")
-	if teamID == "" {
-		http.Error(w, "team_id parameter is required", http.StatusBadRequest)
+	values := c.schemas.teamFiles.Values(r)
+	if !validate(w, c.schemas.teamFiles, values) {
 		return
 	}
 
-	files, err := c.fileRepo.GetFilesByTeamID(teamID)
-	if err != nil {
-		http.Error(w, "failed to fetch files", http.StatusInternalServerError)
-		return
+	type teamFile struct {
+		DownloadURL string `json:"download_url"`
+		FileType    string `json:"file_type"`
+	}
+	files := []teamFile{}
+	query := repository.FileQuery{
+		TeamID: values["team_id"],
+		Sort:   repository.FileSort{Column: "id"},
+		Limit:  maxListLimit,
+	}
+	for {
+		page, err := c.fileRepo.List(query)
+		if err != nil {
+			http.Error(w, "failed to fetch files", http.StatusInternalServerError)
+			return
+		}
+		for _, f := range page.Files {
+			files = append(files, teamFile{DownloadURL: f.DownloadURL, FileType: f.FileType})
+		}
+		if page.Next == nil {
+			break
+		}
+		query.After = page.Next
 	}
 
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", fmt.Sprintf(`</api/v1/files?team_id=%s>; rel="successor-version"`, url.QueryEscape(values["team_id"])))
 	if len(files) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
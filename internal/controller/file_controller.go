@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+// GetFileMetadata returns a stored file together with anything extracted
+// from it. The document text is only included with ?include=text.
+func (c *UploadController) GetFileMetadata(w http.ResponseWriter, r *http.Request) {
+	values := validation.Values{"id": chi.URLParam(r, "id")}
+	if !validate(w, c.schemas.recordID, values) {
+		return
+	}
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+
+	file, err := c.fileRepo.FindByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "File not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch file", err)
+		return
+	}
+
+	if file.Document != nil && r.URL.Query().Get("include") != "text" {
+		file.Document.Text = ""
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"file": file,
+	})
+}
+
+// FileDTO is a stored file as listings return it.
+type FileDTO struct {
+	ID                uint       `json:"id"`
+	OriginalName      string     `json:"original_name"`
+	StorageKey        string     `json:"storage_key"`
+	DownloadURL       string     `json:"download_url"`
+	Size              int64      `json:"size"`
+	FileType          string     `json:"file_type"`
+	DocType           string     `json:"doc_type"`
+	ContentType       string     `json:"content_type"`
+	Version           int        `json:"version"`
+	TeamID            string     `json:"team_id"`
+	Intake            string     `json:"intake"`
+	AcademicYear      string     `json:"academic_year"`
+	Session           string     `json:"session"`
+	UploadedBy        string     `json:"uploaded_by"`
+	SubmissionID      *uint      `json:"submission_id"`
+	ScanStatus        string     `json:"scan_status"`
+	PlagiarismStatus  string     `json:"plagiarism_status"`
+	PlagiarismPercent *float64   `json:"plagiarism_percent"`
+	IntegrityStatus   string     `json:"integrity_status"`
+	ChecksumSHA256    string     `json:"checksum_sha256"`
+	CreatedAt         time.Time  `json:"created_at"`
+	VerifiedAt        *time.Time `json:"verified_at"`
+}
+
+func newFileDTO(f *model.File) FileDTO {
+	return FileDTO{
+		ID:                f.ID,
+		OriginalName:      f.OriginalName,
+		StorageKey:        f.StorageKey,
+		DownloadURL:       f.DownloadURL,
+		Size:              f.Size,
+		FileType:          f.FileType,
+		DocType:           f.DocType,
+		ContentType:       f.ContentType,
+		Version:           f.Version,
+		TeamID:            f.TeamID,
+		Intake:            f.Intake,
+		AcademicYear:      f.AcademicYear,
+		Session:           f.Session,
+		UploadedBy:        f.UploadedBy,
+		SubmissionID:      f.SubmissionID,
+		ScanStatus:        f.ScanStatus,
+		PlagiarismStatus:  f.PlagiarismStatus,
+		PlagiarismPercent: f.PlagiarismPercent,
+		IntegrityStatus:   f.IntegrityStatus,
+		ChecksumSHA256:    f.ChecksumSHA256,
+		CreatedAt:         f.CreatedAt,
+		VerifiedAt:        f.VerifiedAt,
+	}
+}
+
+// fileFields are the JSON names of FileDTO's fields, which ?fields= picks
+// from.
+var fileFields = func() []string {
+	t := reflect.TypeOf(FileDTO{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	return fields
+}()
+
+const defaultFileSort = "-created_at"
+
+// ListFiles pages through stored files, newest first unless sort names
+// another column ("-" in front for descending order). Filters combine;
+// created_from and created_to take a time or a date, created_to covering
+// the whole day. fields limits each file to the listed fields plus its id.
+// The next page is fetched with the returned cursor, which is also in the
+// Link header.
+func (c *UploadController) ListFiles(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.fileList.Values(r)
+	if !validate(w, c.schemas.fileList, values) {
+		return
+	}
+
+	query := repository.FileQuery{
+		TeamID:           values["team_id"],
+		Intake:           values["intake"],
+		AcademicYear:     values["academic_year"],
+		FileType:         strings.ToLower(values["file_type"]),
+		DocType:          values["doc_type"],
+		UploadedBy:       values["uploaded_by"],
+		ScanStatus:       strings.ToLower(values["scan_status"]),
+		PlagiarismStatus: strings.ToLower(values["plagiarism_status"]),
+		IntegrityStatus:  strings.ToLower(values["integrity_status"]),
+		Sort:             parseFileSort(values["sort"]),
+		Limit:            listLimit(values),
+		WithTotal:        true,
+	}
+	if values["created_from"] != "" {
+		from, _, _ := parseTimeOrDate(values["created_from"])
+		query.CreatedFrom = &from
+	}
+	if values["created_to"] != "" {
+		until, dateOnly, _ := parseTimeOrDate(values["created_to"])
+		if dateOnly {
+			until = until.AddDate(0, 0, 1)
+		} else {
+			until = until.Add(time.Microsecond)
+		}
+		query.CreatedUntil = &until
+	}
+	if values["cursor"] != "" {
+		cursor, _ := decodeFileCursor(values["cursor"])
+		query.After = &repository.FileCursor{Value: cursor.Value, ID: cursor.ID}
+	}
+
+	page, err := c.fileRepo.List(query)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch files", err)
+		return
+	}
+
+	var fields []string
+	if values["fields"] != "" {
+		fields = append([]string{"id"}, strings.Split(values["fields"], ",")...)
+	}
+	files := make([]interface{}, 0, len(page.Files))
+	for i := range page.Files {
+		dto := newFileDTO(&page.Files[i])
+		if fields == nil {
+			files = append(files, dto)
+			continue
+		}
+		sparse, err := pickFields(dto, fields)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to encode files", err)
+			return
+		}
+		files = append(files, sparse)
+	}
+
+	body := map[string]interface{}{
+		"files":       files,
+		"count":       len(files),
+		"total":       page.Total,
+		"next_cursor": nil,
+	}
+	if page.Next != nil {
+		token := encodeFileCursor(fileCursor{Sort: cmp.Or(values["sort"], defaultFileSort), Value: page.Next.Value, ID: page.Next.ID})
+		next := r.URL.Query()
+		next.Set("cursor", token)
+		body["next_cursor"] = token
+		w.Header().Set("Link", fmt.Sprintf(`</api/v1/files?%s>; rel="next"`, next.Encode()))
+	}
+	respondWithJSON(w, http.StatusOK, body)
+}
+
+// fileCursor is the opaque position a listing continues from. It records
+// the sort it was made for, as positions of different sorts do not mix.
+type fileCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    uint   `json:"id"`
+}
+
+func encodeFileCursor(cursor fileCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFileCursor(token string) (fileCursor, error) {
+	var cursor fileCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+func parseFileSort(value string) repository.FileSort {
+	column, desc := strings.CutPrefix(cmp.Or(value, defaultFileSort), "-")
+	return repository.FileSort{Column: column, Desc: desc}
+}
+
+// pickFields encodes only the named fields of v.
+func pickFields(v interface{}, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	picked := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		picked[field] = all[field]
+	}
+	return picked, nil
+}
@@ -0,0 +1,625 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/config"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+// IntakeController manages intakes and their teams, members and projects.
+// Intakes and teams are addressed by the names uploads use, so
+// /intakes/fall-2025/teams/team-01 is the team uploading as team-01.
+type IntakeController struct {
+	intakes  repository.IntakeRepository
+	teams    repository.TeamRepository
+	members  repository.MemberRepository
+	projects repository.ProjectRepository
+	search   repository.SearchRepository
+	schemas  intakeSchemas
+}
+
+type intakeSchemas struct {
+	intake  validation.Schema
+	team    validation.Schema
+	member  validation.Schema
+	project validation.Schema
+	path    validation.Schema
+}
+
+func NewIntakeController(cfg *config.Config, intakes repository.IntakeRepository, teams repository.TeamRepository, members repository.MemberRepository, projects repository.ProjectRepository, search repository.SearchRepository) *IntakeController {
+	return &IntakeController{
+		intakes:  intakes,
+		teams:    teams,
+		members:  members,
+		projects: projects,
+		search:   search,
+		schemas:  newIntakeSchemas(strings.Split(cfg.Validation.Sessions, ",")),
+	}
+}
+
+func newIntakeSchemas(sessions []string) intakeSchemas {
+	identifier := validation.Pattern(validation.Identifier, "letters, digits, '-' or '_' and start with a letter or digit")
+
+	var sessionRules []validation.Rule
+	if allowed := nonEmpty(sessions); len(allowed) > 0 {
+		sessionRules = append(sessionRules, validation.OneOf(allowed...))
+	}
+
+	return intakeSchemas{
+		intake: validation.Schema{
+			{Name: "intake", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "academic_year", Required: true, Rules: []validation.Rule{validation.AcademicYear()}},
+			{Name: "session", Rules: sessionRules},
+			{Name: "deadlines", Rules: []validation.Rule{validDeadlines}},
+		},
+		team: validation.Schema{
+			{Name: "intake", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "team_id", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "name", Rules: []validation.Rule{validation.MaxLength(200)}},
+			{Name: "supervisor", Rules: []validation.Rule{identifier}},
+			{Name: "project_title", Rules: []validation.Rule{validation.MaxLength(300)}},
+		},
+		member: validation.Schema{
+			{Name: "student_id", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "name", Required: true, Rules: []validation.Rule{validation.MaxLength(200)}},
+			{Name: "email", Rules: []validation.Rule{validation.MaxLength(254), validEmail}},
+			{Name: "role", Rules: []validation.Rule{validation.OneOf(model.MemberLead, model.MemberRegular)}},
+		},
+		project: validation.Schema{
+			{Name: "title", Required: true, Rules: []validation.Rule{validation.MaxLength(300)}},
+			{Name: "abstract", Rules: []validation.Rule{validation.MaxLength(5000)}},
+		},
+		path: validation.Schema{
+			{Name: "intake", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "team_id", Rules: []validation.Rule{identifier}},
+			{Name: "id", Rules: []validation.Rule{validation.PositiveInt()}},
+		},
+	}
+}
+
+// CreateIntake adds an intake. Deadlines are given as a comma separated
+// list of doc_type=time pairs, the time in RFC 3339 or as a date, which
+// closes uploads at the end of that day in UTC.
+func (c *IntakeController) CreateIntake(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.intake.Values(r)
+	if !validate(w, c.schemas.intake, values) {
+		return
+	}
+
+	if _, err := c.intakes.FindByName(values["intake"]); err == nil {
+		respondWithError(w, http.StatusConflict, "Intake already exists", nil)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch intake", err)
+		return
+	}
+
+	intake := &model.Intake{Name: values["intake"]}
+	setIntakeFields(intake, values)
+	if err := c.intakes.Create(intake); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save intake", err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/intakes/"+intake.Name)
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"intake": intake,
+	})
+}
+
+func (c *IntakeController) ListIntakes(w http.ResponseWriter, r *http.Request) {
+	intakes, err := c.intakes.List()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch intakes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intakes": intakes,
+	})
+}
+
+// GetIntake returns an intake with its teams and their projects.
+func (c *IntakeController) GetIntake(w http.ResponseWriter, r *http.Request) {
+	intake, ok := c.findIntake(w, r)
+	if !ok {
+		return
+	}
+
+	teams, err := c.teams.FindByIntake(intake.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch teams", err)
+		return
+	}
+	intake.Teams = teams
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake": intake,
+	})
+}
+
+// UpdateIntake replaces an intake's academic year, session and deadlines.
+// Its name is part of every stored file's key and cannot change.
+func (c *IntakeController) UpdateIntake(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.intake.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.intake, values) {
+		return
+	}
+
+	intake, ok := c.findIntake(w, r)
+	if !ok {
+		return
+	}
+	setIntakeFields(intake, values)
+	if err := c.intakes.Update(intake); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save intake", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake": intake,
+	})
+}
+
+// DeleteIntake removes an intake that has no teams left.
+func (c *IntakeController) DeleteIntake(w http.ResponseWriter, r *http.Request) {
+	intake, ok := c.findIntake(w, r)
+	if !ok {
+		return
+	}
+
+	hasTeams, err := c.intakes.HasTeams(intake.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch teams", err)
+		return
+	}
+	if hasTeams {
+		respondWithError(w, http.StatusConflict, "The intake still has teams", nil)
+		return
+	}
+
+	if err := c.intakes.Delete(intake.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete intake", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Intake deleted",
+		"intake":  intake.Name,
+	})
+}
+
+// CreateTeam adds a team to an intake, with its project when a
+// project_title is given. Files the team uploaded before it was added are
+// linked to it.
+func (c *IntakeController) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.team.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.team, values) {
+		return
+	}
+
+	intake, ok := c.findIntake(w, r)
+	if !ok {
+		return
+	}
+	if _, err := c.teams.Find(intake.Name, values["team_id"]); err == nil {
+		respondWithError(w, http.StatusConflict, "Team already exists", nil)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch team", err)
+		return
+	}
+
+	team := &model.Team{
+		IntakeID:   intake.ID,
+		Code:       values["team_id"],
+		Name:       values["name"],
+		Supervisor: values["supervisor"],
+	}
+	if values["project_title"] != "" {
+		team.Project = &model.Project{Title: values["project_title"]}
+	}
+	if err := c.teams.Create(team); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save team", err)
+		return
+	}
+	c.reindexTeam(intake.Name, team.Code)
+
+	w.Header().Set("Location", "/api/v1/intakes/"+intake.Name+"/teams/"+team.Code)
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"team": team,
+	})
+}
+
+func (c *IntakeController) ListTeams(w http.ResponseWriter, r *http.Request) {
+	intake, ok := c.findIntake(w, r)
+	if !ok {
+		return
+	}
+
+	teams, err := c.teams.FindByIntake(intake.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch teams", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake": intake.Name,
+		"teams":  teams,
+	})
+}
+
+// GetTeam returns a team with its project and members.
+func (c *IntakeController) GetTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+
+	project, err := c.projects.FindByTeamID(team.ID)
+	if err == nil {
+		team.Project = project
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch project", err)
+		return
+	}
+	team.Members, err = c.members.FindByTeamID(team.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch members", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"team": team,
+	})
+}
+
+// UpdateTeam replaces a team's name and supervisor. The code is part of
+// every stored file's key and cannot change.
+func (c *IntakeController) UpdateTeam(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.team.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	values["team_id"] = chi.URLParam(r, "team")
+	if !validate(w, c.schemas.team, values) {
+		return
+	}
+
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+	team.Name = values["name"]
+	team.Supervisor = values["supervisor"]
+	if err := c.teams.Update(team); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save team", err)
+		return
+	}
+	c.reindexTeam(values["intake"], team.Code)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"team": team,
+	})
+}
+
+// DeleteTeam removes a team with its members and project. Its files are
+// kept.
+func (c *IntakeController) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.teams.Delete(team.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete team", err)
+		return
+	}
+	c.reindexTeam(chi.URLParam(r, "intake"), team.Code)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Team deleted",
+		"team_id": team.Code,
+	})
+}
+
+// AddMember adds a student to a team. The role defaults to member.
+func (c *IntakeController) AddMember(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.member.Values(r)
+	if !validate(w, c.schemas.member, values) {
+		return
+	}
+
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+	if _, err := c.members.FindByStudentID(team.ID, values["student_id"]); err == nil {
+		respondWithError(w, http.StatusConflict, "The student is already a member of the team", nil)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch member", err)
+		return
+	}
+
+	member := &model.Member{TeamID: team.ID, StudentID: values["student_id"]}
+	setMemberFields(member, values)
+	if err := c.members.Create(member); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save member", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"member": member,
+	})
+}
+
+func (c *IntakeController) ListMembers(w http.ResponseWriter, r *http.Request) {
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := c.members.FindByTeamID(team.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch members", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"team_id": team.Code,
+		"members": members,
+	})
+}
+
+// UpdateMember replaces a member's name, email and role.
+func (c *IntakeController) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := c.findMember(w, r)
+	if !ok {
+		return
+	}
+
+	values := c.schemas.member.Values(r)
+	values["student_id"] = member.StudentID
+	if !validate(w, c.schemas.member, values) {
+		return
+	}
+	setMemberFields(member, values)
+	if err := c.members.Update(member); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save member", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"member": member,
+	})
+}
+
+func (c *IntakeController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := c.findMember(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.members.Delete(member.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete member", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":   "Member removed",
+		"member_id": member.ID,
+	})
+}
+
+func (c *IntakeController) GetProject(w http.ResponseWriter, r *http.Request) {
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+
+	project, err := c.projects.FindByTeamID(team.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Project not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch project", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"project": project,
+	})
+}
+
+// SaveProject creates a team's project or replaces its title and abstract.
+func (c *IntakeController) SaveProject(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.project.Values(r)
+	if !validate(w, c.schemas.project, values) {
+		return
+	}
+
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+	err := c.projects.Save(&model.Project{
+		TeamID:   team.ID,
+		Title:    values["title"],
+		Abstract: values["abstract"],
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save project", err)
+		return
+	}
+	c.reindexTeam(chi.URLParam(r, "intake"), team.Code)
+
+	project, err := c.projects.FindByTeamID(team.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch project", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"project": project,
+	})
+}
+
+func (c *IntakeController) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.projects.DeleteByTeamID(team.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete project", err)
+		return
+	}
+	c.reindexTeam(chi.URLParam(r, "intake"), team.Code)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Project deleted",
+		"team_id": team.Code,
+	})
+}
+
+// reindexTeam refreshes the search entries of a team's files, which carry
+// its name and project title.
+func (c *IntakeController) reindexTeam(intake string, teamID string) {
+	if err := c.search.IndexTeam(intake, teamID); err != nil {
+		log.Printf("Warning: failed to reindex files of team %s in %s for search: %v", teamID, intake, err)
+	}
+}
+
+// pathValues validates the intake, team and member named in the URL.
+func (c *IntakeController) pathValues(w http.ResponseWriter, r *http.Request) (validation.Values, bool) {
+	values := validation.Values{
+		"intake":  chi.URLParam(r, "intake"),
+		"team_id": chi.URLParam(r, "team"),
+		"id":      chi.URLParam(r, "id"),
+	}
+	return values, validate(w, c.schemas.path, values)
+}
+
+func (c *IntakeController) findIntake(w http.ResponseWriter, r *http.Request) (*model.Intake, bool) {
+	values, ok := c.pathValues(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	intake, err := c.intakes.FindByName(values["intake"])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Intake not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch intake", err)
+		return nil, false
+	}
+	return intake, true
+}
+
+func (c *IntakeController) findTeam(w http.ResponseWriter, r *http.Request) (*model.Team, bool) {
+	values, ok := c.pathValues(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	team, err := c.teams.Find(values["intake"], values["team_id"])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Team not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch team", err)
+		return nil, false
+	}
+	return team, true
+}
+
+func (c *IntakeController) findMember(w http.ResponseWriter, r *http.Request) (*model.Member, bool) {
+	team, ok := c.findTeam(w, r)
+	if !ok {
+		return nil, false
+	}
+	id, _ := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+
+	member, err := c.members.FindByID(team.ID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Member not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch member", err)
+		return nil, false
+	}
+	return member, true
+}
+
+func setIntakeFields(intake *model.Intake, values validation.Values) {
+	intake.AcademicYear = values["academic_year"]
+	intake.Session = strings.ToLower(values["session"])
+	intake.Deadlines, _ = parseDeadlines(values["deadlines"])
+}
+
+func setMemberFields(member *model.Member, values validation.Values) {
+	member.Name = values["name"]
+	member.Email = values["email"]
+	member.Role = strings.ToLower(values["role"])
+	if member.Role == "" {
+		member.Role = model.MemberRegular
+	}
+}
+
+// parseDeadlines reads doc_type=time pairs. A date without a time closes
+// at the end of that day in UTC.
+func parseDeadlines(value string) (map[string]time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	deadlines := make(map[string]time.Time)
+	for _, pair := range strings.Split(value, ",") {
+		docType, at, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		docType, at = strings.TrimSpace(docType), strings.TrimSpace(at)
+		if !ok || !validation.DocType.MatchString(docType) {
+			return nil, errors.New("invalid document type")
+		}
+		t, dateOnly, err := parseTimeOrDate(at)
+		if err != nil {
+			return nil, err
+		}
+		if dateOnly {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		deadlines[docType] = t
+	}
+	return deadlines, nil
+}
+
+func validDeadlines(field, value string, values validation.Values) error {
+	if _, err := parseDeadlines(value); err != nil {
+		return validation.Reject(field, "format", field+" must be a comma separated list of doc_type=date pairs, e.g. final_report=2026-01-15")
+	}
+	return nil
+}
+
+func validEmail(field, value string, values validation.Values) error {
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		return validation.Reject(field, "format", field+" must be an email address")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 500
+)
+
+// plagiarismReports converts the checks the pipeline ran on staged into the
+// reports to record.
+func plagiarismReports(staged *pipeline.File, p placement) []model.PlagiarismReport {
+	var reports []model.PlagiarismReport
+	if staged.Plagiarism != nil {
+		reports = append(reports, staged.Plagiarism.Report())
+	}
+	if staged.Similarity != nil {
+		reports = append(reports, staged.Similarity.Report())
+	}
+	if staged.Topic != nil {
+		reports = append(reports, staged.Topic.Report())
+	}
+	if staged.CodeSimilarity != nil {
+		reports = append(reports, staged.CodeSimilarity.Report())
+	}
+
+	for i := range reports {
+		reports[i].OriginalName = staged.OriginalName
+		reports[i].TeamID = p.TeamID
+		reports[i].Intake = p.Intake
+		reports[i].AcademicYear = p.AcademicYear
+	}
+	return reports
+}
+
+// recordUnstoredChecks keeps the plagiarism reports of files that were not
+// stored, so rejected uploads still show up for their intake. Files a stage
+// held for review get a review a supervisor can override.
+func (c *UploadController) recordUnstoredChecks(p placement, staged ...*pipeline.File) {
+	var reports []model.PlagiarismReport
+	for _, s := range staged {
+		if s.ReviewPath != "" {
+			c.holdForReview(s, p)
+			continue
+		}
+		reports = append(reports, plagiarismReports(s, p)...)
+	}
+	if err := c.reportRepo.Create(reports); err != nil {
+		log.Printf("Warning: failed to record plagiarism reports: %v", err)
+	}
+}
+
+// useClearance closes the review whose override let a stored file through
+// its checks.
+func (c *UploadController) useClearance(staged *pipeline.File, fileID uint) {
+	if staged.Clearance == nil {
+		return
+	}
+	if err := c.reviewRepo.UseClearance(staged.Clearance.ID, fileID); err != nil {
+		log.Printf("Warning: failed to close review %d of stored file %d: %v", staged.Clearance.ID, fileID, err)
+	}
+}
+
+func (c *UploadController) holdForReview(staged *pipeline.File, p placement) {
+	review := &model.PlagiarismReview{
+		Status:         model.ReviewHeld,
+		OriginalName:   staged.OriginalName,
+		TeamID:         p.TeamID,
+		Intake:         p.Intake,
+		AcademicYear:   p.AcademicYear,
+		Session:        p.Session,
+		UploadedBy:     p.UploadedBy,
+		DocType:        staged.DocType,
+		ContentType:    staged.ContentType,
+		Size:           staged.Size,
+		ChecksumMD5:    staged.Digest.MD5Hex(),
+		ChecksumSHA256: staged.Digest.SHA256Hex(),
+		QuarantinePath: staged.ReviewPath,
+		FromSubmission: p.Batch,
+		Reports:        plagiarismReports(staged, p),
+	}
+	if p.Batch {
+		expiresAt := time.Now().Add(c.batchReviewTTL)
+		review.ExpiresAt = &expiresAt
+	}
+	if err := c.reviewRepo.Create(review); err != nil {
+		log.Printf("Warning: failed to record review of %s held at %s: %v", staged.OriginalName, staged.ReviewPath, err)
+	}
+}
+
+// GetFilePlagiarism returns every plagiarism check recorded for a stored
+// file, newest first.
+func (c *UploadController) GetFilePlagiarism(w http.ResponseWriter, r *http.Request) {
+	values := validation.Values{"id": chi.URLParam(r, "id")}
+	if !validate(w, c.schemas.recordID, values) {
+		return
+	}
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+
+	if _, err := c.fileRepo.FindByID(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(w, http.StatusNotFound, "File not found", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch file", err)
+		return
+	}
+
+	reports, err := c.reportRepo.FindByFileID(uint(id))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch plagiarism reports", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"file_id": id,
+		"reports": reports,
+	})
+}
+
+// ListFlaggedPlagiarism lists the checks of an intake that reached their
+// threshold, including uploads that were rejected because of it.
+func (c *UploadController) ListFlaggedPlagiarism(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.flagged.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.flagged, values) {
+		return
+	}
+
+	reports, err := c.reportRepo.FindFlagged(values["intake"], values["academic_year"], listLimit(values))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch plagiarism reports", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake":  values["intake"],
+		"reports": reports,
+	})
+}
+
+// listLimit reads the optional, already validated limit of a listing.
+func listLimit(values validation.Values) int {
+	if values["limit"] == "" {
+		return defaultListLimit
+	}
+	n, err := strconv.Atoi(values["limit"])
+	if err != nil || n > maxListLimit {
+		return maxListLimit
+	}
+	return n
+}
@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+// ListPlagiarismReviews lists the uploads of an intake held in quarantine
+// after failing a plagiarism check, oldest first.
+func (c *UploadController) ListPlagiarismReviews(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.intakeList.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.intakeList, values) {
+		return
+	}
+
+	reviews, err := c.reviewRepo.FindHeld(values["intake"], listLimit(values))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch plagiarism reviews", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake":  values["intake"],
+		"reviews": reviews,
+	})
+}
+
+// GetPlagiarismReview returns a held upload with its reports and overrides.
+func (c *UploadController) GetPlagiarismReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := c.findReview(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"review": review,
+	})
+}
+
+// OverridePlagiarismReview accepts a held upload on a supervisor's
+// justification: the file is stored like any accepted upload, the review's
+// reports are attached to it and the override is recorded. Files of a batch
+// submission cannot be accepted on their own, as the rest of the batch was
+// not stored: the override clears the file instead, and it passes its checks
+// when the team submits the batch again.
+func (c *UploadController) OverridePlagiarismReview(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.override.Values(r)
+	values["id"] = chi.URLParam(r, "id")
+	if !validate(w, c.schemas.override, values) {
+		return
+	}
+
+	review, ok := c.findReview(w, values["id"])
+	if !ok {
+		return
+	}
+	if review.Status != model.ReviewHeld {
+		respondWithError(w, http.StatusConflict, "The plagiarism check was already overridden", nil)
+		return
+	}
+	if review.FromSubmission {
+		c.clearBatchReview(w, review, values)
+		return
+	}
+	if _, err := os.Stat(review.QuarantinePath); err != nil {
+		respondWithError(w, http.StatusGone, "The held file is no longer available", nil)
+		return
+	}
+
+	p := placement{
+		TeamID:       review.TeamID,
+		Intake:       review.Intake,
+		AcademicYear: review.AcademicYear,
+		Session:      review.Session,
+		UploadedBy:   review.UploadedBy,
+	}
+	failed := failedCheck(review.Reports)
+	override := newOverride(values, review.TeamID, review.Intake, failed)
+	var record *model.File
+	for attempt := 1; ; attempt++ {
+		var err error
+		record, err = c.storeFile(&pipeline.File{
+			OriginalName: review.OriginalName,
+			Path:         review.QuarantinePath,
+			Size:         review.Size,
+			ContentType:  review.ContentType,
+			DocType:      review.DocType,
+			Digest:       utils.DigestFromHex(review.ChecksumMD5, review.ChecksumSHA256),
+		}, p, nil)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to upload to cloud storage", err)
+			return
+		}
+
+		record.PlagiarismStatus = model.PlagiarismOverridden
+		if failed != nil {
+			record.PlagiarismPercent = failed.Score
+			record.PlagiarismProvider = failed.Provider
+			record.PlagiarismCheckedAt = &failed.CheckedAt
+		}
+
+		err = c.reviewRepo.Release(review, record, override)
+		if err == nil {
+			break
+		}
+		c.discardStoredFiles(record)
+		if errors.Is(err, repository.ErrVersionTaken) && attempt < storeAttempts {
+			// Another upload of the same doc_type got the version first
+			continue
+		}
+		if errors.Is(err, repository.ErrReviewClosed) {
+			respondWithError(w, http.StatusConflict, "The plagiarism check was already overridden", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to save file metadata", err)
+		return
+	}
+
+	c.indexForSearch(record.ID)
+
+	if err := c.quarantine.Discard(review.QuarantinePath); err != nil {
+		log.Printf("Warning: failed to remove quarantined copy of review %d: %v", review.ID, err)
+	}
+	log.Printf("Plagiarism check of %s (review %d) overridden by %s", review.OriginalName, review.ID, override.Supervisor)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"review":   review,
+		"override": override,
+		"file":     record,
+	})
+}
+
+// clearBatchReview records the override of a held batch submission file,
+// which is accepted when the team submits the batch again.
+func (c *UploadController) clearBatchReview(w http.ResponseWriter, review *model.PlagiarismReview, values validation.Values) {
+	override := newOverride(values, review.TeamID, review.Intake, failedCheck(review.Reports))
+	err := c.reviewRepo.Clear(review, override, time.Now().Add(c.batchReviewTTL))
+	if errors.Is(err, repository.ErrReviewClosed) {
+		respondWithError(w, http.StatusConflict, "The plagiarism check was already overridden", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save the override", err)
+		return
+	}
+
+	// The resubmitted batch brings its own copy
+	if err := c.quarantine.Discard(review.QuarantinePath); err != nil {
+		log.Printf("Warning: failed to remove quarantined copy of review %d: %v", review.ID, err)
+	}
+	log.Printf("Plagiarism check of %s (review %d) cleared for resubmission by %s", review.OriginalName, review.ID, override.Supervisor)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "The file will be accepted when the team submits the batch again",
+		"review":   review,
+		"override": override,
+	})
+}
+
+// OverrideFilePlagiarism accepts a stored file whose later plagiarism check
+// failed.
+func (c *UploadController) OverrideFilePlagiarism(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.override.Values(r)
+	values["id"] = chi.URLParam(r, "id")
+	if !validate(w, c.schemas.override, values) {
+		return
+	}
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+
+	file, err := c.fileRepo.FindByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "File not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch file", err)
+		return
+	}
+	if file.PlagiarismStatus == model.PlagiarismOverridden {
+		respondWithError(w, http.StatusConflict, "The plagiarism check was already overridden", nil)
+		return
+	}
+
+	reports, err := c.reportRepo.FindByFileID(file.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch plagiarism reports", err)
+		return
+	}
+	failed := failedCheck(reports)
+	if failed == nil {
+		respondWithError(w, http.StatusConflict, "The file has no failed plagiarism check", nil)
+		return
+	}
+
+	override := newOverride(values, file.TeamID, file.Intake, failed)
+	err = c.reviewRepo.OverrideFile(file.ID, override)
+	if errors.Is(err, repository.ErrFileOverridden) {
+		respondWithError(w, http.StatusConflict, "The plagiarism check was already overridden", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save override", err)
+		return
+	}
+	log.Printf("Plagiarism check of file %d overridden by %s", file.ID, override.Supervisor)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"file_id":  file.ID,
+		"override": override,
+	})
+}
+
+// ListPlagiarismOverrides is the audit log of an intake's overrides, newest
+// first.
+func (c *UploadController) ListPlagiarismOverrides(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.intakeList.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.intakeList, values) {
+		return
+	}
+
+	overrides, err := c.reviewRepo.FindOverrides(values["intake"], listLimit(values))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch plagiarism overrides", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake":    values["intake"],
+		"overrides": overrides,
+	})
+}
+
+func (c *UploadController) findReview(w http.ResponseWriter, rawID string) (*model.PlagiarismReview, bool) {
+	values := validation.Values{"id": rawID}
+	if !validate(w, c.schemas.recordID, values) {
+		return nil, false
+	}
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+
+	review, err := c.reviewRepo.FindByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Plagiarism review not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch plagiarism review", err)
+		return nil, false
+	}
+	return review, true
+}
+
+// failedCheck returns the most recent flagged report, given reports newest
+// first.
+func failedCheck(reports []model.PlagiarismReport) *model.PlagiarismReport {
+	for i := range reports {
+		if reports[i].Flagged {
+			return &reports[i]
+		}
+	}
+	return nil
+}
+
+func newOverride(values validation.Values, teamID string, intake string, failed *model.PlagiarismReport) *model.PlagiarismOverride {
+	override := &model.PlagiarismOverride{
+		TeamID:        teamID,
+		Intake:        intake,
+		Supervisor:    values["supervisor"],
+		Justification: values["justification"],
+	}
+	if failed != nil {
+		override.Score = failed.Score
+		override.Threshold = failed.Threshold
+	}
+	return override
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sohan-reza/capstone-core/internal/repository"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchResult is a file found by a search. Matched words in the snippet
+// are wrapped in <mark>; the rest of it is HTML-escaped.
+type searchResult struct {
+	File         FileDTO `json:"file"`
+	ProjectTitle string  `json:"project_title,omitempty"`
+	Rank         float64 `json:"rank"`
+	Snippet      string  `json:"snippet"`
+}
+
+var snippetMarks = strings.NewReplacer(
+	repository.SnippetStart, "<mark>",
+	repository.SnippetStop, "</mark>",
+)
+
+// Search finds files by name, team, project title and extracted text, best
+// matches first. q takes quoted phrases, "or" and -word exclusions. When
+// no file has every word, files with any of them are returned, and failing
+// that names, teams and project titles are matched by similarity; match
+// says which happened. Facets count the results per year, intake and type.
+func (c *UploadController) Search(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.search.Values(r)
+	if !validate(w, c.schemas.search, values) {
+		return
+	}
+
+	limit := defaultSearchLimit
+	if values["limit"] != "" {
+		limit, _ = strconv.Atoi(values["limit"])
+		limit = min(limit, maxSearchLimit)
+	}
+	page := 1
+	if values["page"] != "" {
+		page, _ = strconv.Atoi(values["page"])
+	}
+
+	results, err := c.searchRepo.Search(repository.SearchQuery{
+		Text:         values["q"],
+		AcademicYear: values["academic_year"],
+		Intake:       values["intake"],
+		DocType:      values["doc_type"],
+		FileType:     strings.ToLower(values["file_type"]),
+		TeamID:       values["team_id"],
+		Limit:        limit,
+		Offset:       (page - 1) * limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to search files", err)
+		return
+	}
+
+	hits := make([]searchResult, len(results.Hits))
+	for i := range results.Hits {
+		hit := &results.Hits[i]
+		hits[i] = searchResult{
+			File:         newFileDTO(&hit.File),
+			ProjectTitle: hit.ProjectTitle,
+			Rank:         hit.Rank,
+			Snippet:      snippet(hit.Snippet),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"query":   values["q"],
+		"match":   results.Match,
+		"total":   results.Total,
+		"page":    page,
+		"limit":   limit,
+		"results": hits,
+		"facets":  results.Facets,
+	})
+}
+
+// snippet flattens the line breaks of extracted text and escapes it for
+// HTML, marking the matched words.
+func snippet(text string) string {
+	return snippetMarks.Replace(html.EscapeString(strings.Join(strings.Fields(text), " ")))
+}
+
+// indexForSearch adds newly stored files to the search index. Files it
+// misses are indexed when the API next starts.
+func (c *UploadController) indexForSearch(fileIDs ...uint) {
+	if err := c.searchRepo.Index(fileIDs...); err != nil {
+		log.Printf("Warning: failed to index files %v for search: %v", fileIDs, err)
+	}
+}
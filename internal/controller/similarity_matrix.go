@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"gorm.io/gorm"
+)
+
+var exportFormats = []string{"json", "csv", "html"}
+
+// CreateSimilarityMatrix queues an intake-wide comparison of the latest
+// documents of every team. The matrix is computed in the background; its
+// status and result are fetched from the returned URL.
+func (c *UploadController) CreateSimilarityMatrix(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.matrix.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.matrix, values) {
+		return
+	}
+
+	matrix := &model.SimilarityMatrix{
+		Intake:   values["intake"],
+		DocTypes: c.matrixTypes,
+		Cutoff:   c.matrixCutoff,
+		Status:   model.JobQueued,
+	}
+	if values["doc_types"] != "" {
+		var docTypes []string
+		for _, docType := range strings.Split(values["doc_types"], ",") {
+			docTypes = append(docTypes, strings.TrimSpace(docType))
+		}
+		matrix.DocTypes = strings.Join(docTypes, ",")
+	}
+	if values["cutoff"] != "" {
+		matrix.Cutoff, _ = strconv.ParseFloat(values["cutoff"], 64)
+	}
+
+	if err := c.matrixRepo.Create(matrix); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create similarity matrix", err)
+		return
+	}
+	c.matrices.Start(context.Background(), matrix)
+
+	statusURL := fmt.Sprintf("/api/v1/similarity-matrices/%d", matrix.ID)
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":     "accepted",
+		"message":    "Similarity matrix queued",
+		"matrix_id":  matrix.ID,
+		"status_url": statusURL,
+	})
+}
+
+// ListSimilarityMatrices lists the matrices computed for an intake, newest
+// first, without their results.
+func (c *UploadController) ListSimilarityMatrices(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.intakeList.Values(r)
+	values["intake"] = chi.URLParam(r, "intake")
+	if !validate(w, c.schemas.intakeList, values) {
+		return
+	}
+
+	matrices, err := c.matrixRepo.FindByIntake(values["intake"], listLimit(values))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch similarity matrices", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"intake":   values["intake"],
+		"matrices": matrices,
+	})
+}
+
+// GetSimilarityMatrix returns a matrix with its result. With format=csv or
+// format=html the finished result is downloaded as a file instead.
+func (c *UploadController) GetSimilarityMatrix(w http.ResponseWriter, r *http.Request) {
+	values := c.schemas.export.Values(r)
+	values["id"] = chi.URLParam(r, "id")
+	if !validate(w, c.schemas.export, values) {
+		return
+	}
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+
+	matrix, err := c.matrixRepo.FindByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(w, http.StatusNotFound, "Similarity matrix not found", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch similarity matrix", err)
+		return
+	}
+
+	format := strings.ToLower(values["format"])
+	if format == "" || format == "json" {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"matrix": matrix,
+		})
+		return
+	}
+	if matrix.Status != model.JobSucceeded {
+		respondWithError(w, http.StatusConflict, "Similarity matrix is not ready", nil)
+		return
+	}
+
+	filename := fmt.Sprintf("similarity-%s-%d.%s", matrix.Intake, matrix.ID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = writeMatrixCSV(w, matrix)
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = matrixTemplate.Execute(w, newMatrixView(matrix))
+	}
+	if err != nil {
+		log.Printf("Failed to write similarity matrix %d as %s: %v", matrix.ID, format, err)
+	}
+}
+
+// writeMatrixCSV writes one row per pair of teams, with the cluster each
+// pair belongs to when it reached the cutoff.
+func writeMatrixCSV(w http.ResponseWriter, matrix *model.SimilarityMatrix) error {
+	clusterOf := make(map[string]int)
+	for i, cluster := range matrix.Result.Clusters {
+		for _, team := range cluster.Teams {
+			clusterOf[team] = i + 1
+		}
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"team_a", "team_b", "similarity", "basis", "doc_type", "file_a", "file_b", "cluster"})
+	for _, pair := range matrix.Result.Pairs {
+		cluster := ""
+		if pair.Similarity >= matrix.Cutoff {
+			cluster = strconv.Itoa(clusterOf[pair.TeamA])
+		}
+		out.Write([]string{
+			pair.TeamA,
+			pair.TeamB,
+			strconv.FormatFloat(pair.Similarity, 'f', 2, 64),
+			pair.Basis,
+			pair.DocType,
+			strconv.FormatUint(uint64(pair.FileA), 10),
+			strconv.FormatUint(uint64(pair.FileB), 10),
+			cluster,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+type matrixCell struct {
+	Value   float64
+	Self    bool
+	Flagged bool
+	Shade   int
+}
+
+type matrixRow struct {
+	Team  string
+	Cells []matrixCell
+}
+
+type matrixView struct {
+	*model.SimilarityMatrix
+	Rows []matrixRow
+}
+
+func newMatrixView(matrix *model.SimilarityMatrix) matrixView {
+	view := matrixView{SimilarityMatrix: matrix}
+	similarity := make(map[[2]string]float64, len(matrix.Result.Pairs)*2)
+	for _, pair := range matrix.Result.Pairs {
+		similarity[[2]string{pair.TeamA, pair.TeamB}] = pair.Similarity
+		similarity[[2]string{pair.TeamB, pair.TeamA}] = pair.Similarity
+	}
+
+	for _, a := range matrix.Result.Teams {
+		row := matrixRow{Team: a}
+		for _, b := range matrix.Result.Teams {
+			value := similarity[[2]string{a, b}]
+			row.Cells = append(row.Cells, matrixCell{
+				Value:   value,
+				Self:    a == b,
+				Flagged: a != b && value >= matrix.Cutoff,
+				// Lightness of the cell's red, from white at 0% down to 55%
+				Shade: 100 - int(value*45/100),
+			})
+		}
+		view.Rows = append(view.Rows, row)
+	}
+	return view
+}
+
+var matrixTemplate = template.Must(template.New("matrix").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Similarity matrix for {{.Intake}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th { background: #f4f4f4; }
+td.self { background: #eee; }
+td.flagged { font-weight: bold; outline: 2px solid #b00; }
+</style>
+</head>
+<body>
+<h1>Similarity matrix for {{.Intake}}</h1>
+<p>Document types: {{.DocTypes}}. Cutoff: {{percent .Cutoff}}%.{{if .CompletedAt}} Computed {{.CompletedAt.Format "2006-01-02 15:04"}}.{{end}}</p>
+
+<h2>Clusters</h2>
+{{if .Result.Clusters}}<ol>
+{{range .Result.Clusters}}<li>{{range $i, $t := .Teams}}{{if $i}}, {{end}}{{$t}}{{end}} (up to {{percent .MaxSimilarity}}%)</li>
+{{end}}</ol>
+{{else}}<p>No teams reached the cutoff.</p>
+{{end}}
+<h2>Matrix</h2>
+<table>
+<tr><th></th>{{range .Result.Teams}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.Team}}</th>{{range .Cells}}{{if .Self}}<td class="self"></td>{{else}}<td{{if .Flagged}} class="flagged"{{end}} style="background: hsl(0, 70%, {{.Shade}}%)">{{percent .Value}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+
+<h2>Pairs</h2>
+<table>
+<tr><th>Team</th><th>Team</th><th>Similarity</th><th>Basis</th><th>Document type</th></tr>
+{{range .Result.Pairs}}<tr><td>{{.TeamA}}</td><td>{{.TeamB}}</td><td>{{percent .Similarity}}%</td><td>{{.Basis}}</td><td>{{.DocType}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,426 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/progress"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+type submissionFileResult struct {
+	Field    string                 `json:"field"`
+	FileName string                 `json:"file_name"`
+	Status   string                 `json:"status"`
+	File     *model.File            `json:"file,omitempty"`
+	Info     *FileResponse          `json:"file_info,omitempty"`
+	Error    map[string]interface{} `json:"error,omitempty"`
+}
+
+// HandleBatchSubmission accepts several files for one milestone in a single
+// multipart request. Each file part's form field name is used as its
+// doc_type (e.g. report, slides, code). The files are staged and queued as
+// one job; the workers validate them individually but store them as one
+// submission: if any of them fails, none are kept. The response carries the
+// job ID to poll at GET /api/v1/uploads/{id}, which reports the submission
+// once it is stored. With an upload_id the progress of every file is
+// published as it is validated. Checksums for a file go in Content-MD5 or
+// X-Checksum-SHA256 headers on its part.
+func (c *UploadController) HandleBatchSubmission(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(100 << 20); err != nil {
+		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	values := c.schemas.submission.Values(r)
+	values["upload_id"] = progress.UploadID(r)
+	if !validate(w, c.schemas.submission, values) {
+		return
+	}
+	uploadID := values["upload_id"]
+	if uploadID != "" {
+		if _, err := c.jobRepo.FindByID(uploadID); err == nil {
+			respondWithError(w, http.StatusConflict, "upload_id is already in use", nil)
+			return
+		}
+	}
+
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var fieldErrs validation.Errors
+	for _, field := range fields {
+		if !validation.DocType.MatchString(field) {
+			fieldErrs = append(fieldErrs, validation.Reject(field, "format", "file fields name the doc_type and must be lowercase letters, digits, '-' or '_'"))
+			continue
+		}
+		err := c.schemas.deadline(field, field, values)
+		var fieldErr *validation.FieldError
+		if errors.As(err, &fieldErr) {
+			fieldErrs = append(fieldErrs, fieldErr)
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to validate request", err)
+			return
+		}
+	}
+	if len(fieldErrs) > 0 {
+		respondWithValidationErrors(w, fieldErrs)
+		return
+	}
+
+	// Stage every part; the workers take over the files once the job is
+	// queued
+	var results []*submissionFileResult
+	var parts []model.JobPart
+	removeParts := func() {
+		for _, part := range parts {
+			os.Remove(part.TempPath)
+		}
+	}
+	failed := false
+	for _, field := range fields {
+		for _, header := range r.MultipartForm.File[field] {
+			result := &submissionFileResult{
+				Field:    field,
+				FileName: utils.DisplayFilename(header.Filename),
+			}
+			results = append(results, result)
+
+			expected, err := expectedDigest(r, header, false)
+			if err != nil {
+				failed = true
+				result.Status = "error"
+				result.Error = map[string]interface{}{
+					"status":  "error",
+					"message": "Invalid checksum",
+					"error":   err.Error(),
+				}
+				continue
+			}
+
+			s, err := c.saveStaged(header, field)
+			if err != nil {
+				failed = true
+				result.Status = "error"
+				result.Error = map[string]interface{}{
+					"status":  "error",
+					"message": "Failed to save temporary file",
+				}
+				continue
+			}
+			parts = append(parts, model.JobPart{
+				Field:        field,
+				OriginalName: s.OriginalName,
+				Size:         s.Size,
+				ContentType:  s.ContentType,
+				TempPath:     s.Path,
+
+				ChecksumMD5:    s.Digest.MD5Hex(),
+				ChecksumSHA256: s.Digest.SHA256Hex(),
+			})
+
+			if err := verifyDigest(s, expected); err != nil {
+				failed = true
+				result.Status = "error"
+				_, result.Error = processingError(err)
+				continue
+			}
+			result.Status = "accepted"
+		}
+	}
+
+	if len(results) == 0 {
+		c.respondBatch(w, uploadID, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "No files in submission",
+		})
+		return
+	}
+
+	if failed {
+		removeParts()
+		c.respondBatch(w, uploadID, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "One or more files failed validation, nothing was stored",
+			"results": results,
+		})
+		return
+	}
+
+	job := c.newSubmissionJob(uploadID, parts, placementFrom(values), values["milestone"])
+	if err := c.jobRepo.Create(job); err != nil {
+		removeParts()
+		respondWithError(w, http.StatusInternalServerError, "Failed to create upload job", err)
+		return
+	}
+
+	if !c.enqueue(job.ID) {
+		job.Finish(model.JobFailed)
+		job.ResultCode = http.StatusServiceUnavailable
+		c.jobRepo.Update(job)
+		removeParts()
+		respondWithError(w, http.StatusServiceUnavailable, "Upload queue is full, try again later", nil)
+		return
+	}
+
+	statusURL := "/api/v1/uploads/" + job.ID
+	c.events.Publish(job.ID, progress.EventQueued, map[string]interface{}{
+		"job_id":     job.ID,
+		"status_url": statusURL,
+		"stages":     job.Stages,
+	})
+
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":     "accepted",
+		"message":    "Submission accepted for processing",
+		"job_id":     job.ID,
+		"status_url": statusURL,
+		"events_url": statusURL + "/events",
+		"results":    results,
+	})
+}
+
+func (c *UploadController) newSubmissionJob(id string, parts []model.JobPart, p placement, milestone string) *model.UploadJob {
+	if id == "" {
+		id = generateJobID()
+	}
+	var size int64
+	for _, part := range parts {
+		size += part.Size
+	}
+	job := &model.UploadJob{
+		ID:           id,
+		Status:       model.JobQueued,
+		Size:         size,
+		TeamID:       p.TeamID,
+		Intake:       p.Intake,
+		AcademicYear: p.AcademicYear,
+		Session:      p.Session,
+		UploadedBy:   p.UploadedBy,
+		Milestone:    milestone,
+		Parts:        parts,
+
+		WorkerID:       c.workerID,
+		LeaseExpiresAt: c.leaseExpiry(),
+	}
+	job.Stages = c.pendingStages(job)
+	return job
+}
+
+// runSubmissionJob validates every part of a batch submission job and, if
+// they all pass, stores them as one submission.
+func (c *UploadController) runSubmissionJob(ctx context.Context, job *model.UploadJob) {
+	requeued := false
+	defer func() {
+		if !requeued {
+			for _, part := range job.Parts {
+				os.Remove(part.TempPath)
+			}
+		}
+	}()
+
+	p := jobPlacement(job)
+	p.Batch = true
+
+	// Jobs taken over from a stopped instance start over
+	job.Status = model.JobProcessing
+	job.Stages = c.pendingStages(job)
+	c.startJobStage(job, stageValidation)
+
+	var results []*submissionFileResult
+	var staged []*pipeline.File
+	failed := false
+	for _, part := range job.Parts {
+		result := &submissionFileResult{
+			Field:    part.Field,
+			FileName: part.OriginalName,
+		}
+		results = append(results, result)
+
+		if _, err := os.Stat(part.TempPath); err != nil {
+			c.failJob(job, http.StatusGone, map[string]interface{}{
+				"status":  "error",
+				"message": "Uploaded files are no longer available, please submit again",
+			})
+			return
+		}
+		s := &pipeline.File{
+			OriginalName: part.OriginalName,
+			Size:         part.Size,
+			ContentType:  part.ContentType,
+			DocType:      part.Field,
+			TeamID:       job.TeamID,
+			Intake:       job.Intake,
+			AcademicYear: job.AcademicYear,
+			Path:         part.TempPath,
+			Digest:       utils.DigestFromHex(part.ChecksumMD5, part.ChecksumSHA256),
+		}
+		staged = append(staged, s)
+
+		field, name := part.Field, part.OriginalName
+		onStage := func(stage string) {
+			c.events.Publish(job.ID, progress.EventStage, map[string]interface{}{
+				"stage": stage,
+				"file":  name,
+				"field": field,
+			})
+		}
+		err := c.processor.Run(ctx, s, onStage)
+		c.publishStageResults(job.ID, name, s, 0)
+		if err != nil && ctx.Err() != nil {
+			requeued = true
+			c.requeueJob(job)
+			return
+		}
+		if err != nil {
+			failed = true
+			result.Status = "error"
+			_, result.Error = processingError(err)
+			continue
+		}
+
+		info := fileInfo(s)
+		result.Status = "validated"
+		result.Info = &info
+	}
+
+	if failed {
+		c.recordUnstoredChecks(p, staged...)
+		c.failJob(job, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "One or more files failed validation, nothing was stored",
+			"results": results,
+		})
+		return
+	}
+
+	c.startJobStage(job, stageStorage)
+	var submission *model.Submission
+	for attempt := 1; ; attempt++ {
+		submission = &model.Submission{
+			TeamID:       p.TeamID,
+			Intake:       p.Intake,
+			AcademicYear: p.AcademicYear,
+			Session:      p.Session,
+			Milestone:    job.Milestone,
+		}
+
+		// Upload everything, removing what was already uploaded on failure
+		versions := make(map[string]int)
+		var stored []*model.File
+		for _, s := range staged {
+			record, err := c.storeFile(s, p, versions)
+			if err != nil {
+				log.Printf("Failed to store %s: %v", s.OriginalName, err)
+				c.discardStoredFiles(stored...)
+				c.recordUnstoredChecks(p, staged...)
+				c.failJob(job, http.StatusInternalServerError, map[string]interface{}{
+					"status":  "error",
+					"message": "Failed to upload to cloud storage, nothing was stored",
+				})
+				return
+			}
+			stored = append(stored, record)
+			submission.Files = append(submission.Files, *record)
+		}
+
+		// Record the submission and its files in one transaction
+		err := c.submissionRepo.Create(submission)
+		if err == nil {
+			break
+		}
+		c.discardStoredFiles(stored...)
+		if errors.Is(err, repository.ErrVersionTaken) && attempt < storeAttempts {
+			// Another upload of the same doc_type got a version first
+			continue
+		}
+		c.recordUnstoredChecks(p, staged...)
+		c.failJob(job, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to save submission",
+		})
+		return
+	}
+
+	ids := make([]uint, len(submission.Files))
+	for i := range submission.Files {
+		ids[i] = submission.Files[i].ID
+		c.useClearance(staged[i], ids[i])
+	}
+	c.indexForSearch(ids...)
+
+	for i, result := range results {
+		result.Status = "success"
+		result.File = &submission.Files[i]
+		if result.File.Document != nil {
+			result.File.Document.Text = ""
+		}
+	}
+
+	job.SubmissionID = &submission.ID
+	job.ResultCode = http.StatusCreated
+	job.Result = map[string]interface{}{
+		"status":        "success",
+		"message":       "Submission successfully processed and uploaded",
+		"submission_id": submission.ID,
+		"results":       results,
+	}
+	job.Finish(model.JobSucceeded)
+	if err := c.jobRepo.Update(job); err != nil {
+		log.Printf("Warning: failed to update upload job %s: %v", job.ID, err)
+	}
+	c.events.Publish(job.ID, progress.EventComplete, jobOutcome(job))
+}
+
+// respondBatch writes the response to a batch submission and ends its
+// progress stream with the same outcome.
+func (c *UploadController) respondBatch(w http.ResponseWriter, uploadID string, code int, body map[string]interface{}) {
+	eventType := progress.EventComplete
+	if code >= http.StatusBadRequest {
+		eventType = progress.EventFailed
+	}
+	c.events.Publish(uploadID, eventType, map[string]interface{}{
+		"result_code": code,
+		"result":      body,
+	})
+
+	respondWithJSON(w, code, body)
+}
+
+func (c *UploadController) GetSubmission(w http.ResponseWriter, r *http.Request) {
+	values := validation.Values{"id": chi.URLParam(r, "id")}
+	if !validate(w, c.schemas.recordID, values) {
+		return
+	}
+	id, _ := strconv.ParseUint(values["id"], 10, 64)
+
+	submission, err := c.submissionRepo.FindByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Submission not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch submission", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"submission": submission,
+	})
+}
@@ -86,7 +86,7 @@
 // 	return hex.EncodeToString(randomBytes) + ext
 // }
 
-// func saveUploadedFile(file multipart.File, dstPath string) error {
+// func saveUploadedFile(file io.Reader, dstPath string) error {
 // 	dst, err := os.Create(dstPath)
 // 	if err != nil {
 // 		return err
@@ -101,44 +101,80 @@ package controller
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
-	"net/http/httptest"
 	"os"
 	"path/filepath"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sohan-reza/capstone-core/internal/config"
 	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/progress"
 	"github.com/sohan-reza/capstone-core/internal/repository"
 	"github.com/sohan-reza/capstone-core/internal/service"
 	"github.com/sohan-reza/capstone-core/internal/utils"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
 )
 
 type UploadController struct {
-	pdfController     *PDFController
-	archiveController *ArchiveController
-	uploadDir         string
-	awsService        service.AWSService
-	fileRepo          repository.FileRepository
+	uploadDir      string
+	awsService     service.AWSService
+	fileRepo       repository.FileRepository
+	submissionRepo repository.SubmissionRepository
+	jobRepo        repository.UploadJobRepository
+	reportRepo     repository.PlagiarismReportRepository
+	reviewRepo     repository.PlagiarismReviewRepository
+	batchReviewTTL time.Duration
+	matrixRepo     repository.SimilarityMatrixRepository
+	teamRepo       repository.TeamRepository
+	searchRepo     repository.SearchRepository
+	quarantine     service.QuarantineStore
+	matrices       *service.SimilarityMatrixBuilder
+	matrixTypes    string
+	matrixCutoff   float64
+	jobs           chan string
+	workerID       string
+	jobLease       time.Duration
+	heldMu         sync.Mutex
+	held           map[string]bool
+	processor      *pipeline.Pipeline
+	events         *progress.Broker
+	schemas        requestSchemas
 }
 
-func NewUploadController(cfg *config.Config, awsService service.AWSService, fileRepo repository.FileRepository) *UploadController {
+func NewUploadController(cfg *config.Config, awsService service.AWSService, fileRepo repository.FileRepository, submissionRepo repository.SubmissionRepository, jobRepo repository.UploadJobRepository, reportRepo repository.PlagiarismReportRepository, reviewRepo repository.PlagiarismReviewRepository, matrixRepo repository.SimilarityMatrixRepository, teamRepo repository.TeamRepository, searchRepo repository.SearchRepository, quarantine service.QuarantineStore, matrices *service.SimilarityMatrixBuilder, processor *pipeline.Pipeline, events *progress.Broker, registry validation.Registry) *UploadController {
 	os.MkdirAll(cfg.Upload.Dir, 0755)
 
 	return &UploadController{
-		pdfController: NewPDFController(
-			cfg.Upload.Dir,
-			cfg.Plagiarism.APIEndpoint,
-			cfg.Plagiarism.Threshold,
-		),
-		archiveController: &ArchiveController{},
-		uploadDir:         cfg.Upload.Dir,
-		awsService:        awsService,
-		fileRepo:          fileRepo,
+		uploadDir:      cfg.Upload.Dir,
+		awsService:     awsService,
+		fileRepo:       fileRepo,
+		submissionRepo: submissionRepo,
+		jobRepo:        jobRepo,
+		reportRepo:     reportRepo,
+		reviewRepo:     reviewRepo,
+		batchReviewTTL: cfg.Plagiarism.BatchReviewTTL,
+		matrixRepo:     matrixRepo,
+		teamRepo:       teamRepo,
+		searchRepo:     searchRepo,
+		quarantine:     quarantine,
+		matrices:       matrices,
+		matrixTypes:    cfg.SimilarityMatrix.DocTypes,
+		matrixCutoff:   cfg.SimilarityMatrix.Cutoff,
+		jobs:           make(chan string, cfg.Upload.QueueSize),
+		workerID:       cfg.Upload.WorkerID,
+		jobLease:       cfg.Upload.JobLease,
+		held:           make(map[string]bool),
+		processor:      processor,
+		events:         events,
+		schemas:        newRequestSchemas(registry, strings.Split(cfg.Validation.Sessions, ","), strings.Split(cfg.Plagiarism.Supervisors, ",")),
 	}
 }
 
@@ -220,6 +256,14 @@ func NewUploadController(cfg *config.Config, awsService service.AWSService, file
 //		// Include the download URL in the response if needed
 //		// You can modify your PDF/Archive controller responses to include this
 //	}
+
+// HandleFileUpload accepts a file and queues it for processing. The
+// processing pipeline and storage run in the background; the
+// response carries the job ID to poll at GET /api/v1/uploads/{id}. Clients
+// that want to follow the transfer as well pick the ID themselves with the
+// upload_id parameter and subscribe to its events before sending the file.
+// A Content-MD5 or X-Checksum-SHA256 header or form field is checked against
+// what was received before the upload is accepted.
 func (c *UploadController) HandleFileUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -232,105 +276,272 @@ func (c *UploadController) HandleFileUpload(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	file, header, err := r.FormFile("file")
+	values := c.schemas.upload.Values(r)
+	values["upload_id"] = progress.UploadID(r)
+	if !validate(w, c.schemas.upload, values) {
+		return
+	}
+
+	uploadID := values["upload_id"]
+	if uploadID != "" {
+		if _, err := c.jobRepo.FindByID(uploadID); err == nil {
+			respondWithError(w, http.StatusConflict, "upload_id is already in use", nil)
+			return
+		}
+	}
+
+	_, header, err := r.FormFile("file")
 	if err != nil {
 		http.Error(w, "Error retrieving file", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
-
-	// Create temporary file for validation
-	newFilename := generateUniqueFilename(header.Filename)
-	filePath := filepath.Join(c.uploadDir, newFilename)
 
-	if err := saveUploadedFile(file, filePath); err != nil {
-		http.Error(w, "Failed to save temporary file", http.StatusInternalServerError)
+	expected, err := expectedDigest(r, header, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid checksum", err)
 		return
 	}
-	defer os.Remove(filePath) // Ensure cleanup
 
-	recorder := httptest.NewRecorder()
+	staged, err := c.saveStaged(header, values["doc_type"])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save temporary file", err)
+		return
+	}
 
-	switch utils.DetectFileType(header) {
-	case utils.PDF:
-		c.pdfController.HandleUpload(recorder, r, header.Filename, newFilename, filePath)
-	case utils.Archive:
-		c.archiveController.HandleUpload(recorder, r, header.Filename, newFilename, filePath)
-	default:
-		respondWithJSON(w, http.StatusUnsupportedMediaType, map[string]interface{}{
-			"status":  "error",
-			"message": "Unsupported file type",
-		})
+	if err := verifyDigest(staged, expected); err != nil {
+		os.Remove(staged.Path)
+		code, body := processingError(err)
+		respondWithJSON(w, code, body)
 		return
 	}
 
-	if recorder.Code != http.StatusOK {
-		// Forward the error response from the handler
-		for k, v := range recorder.Header() {
-			w.Header()[k] = v
-		}
-		w.WriteHeader(recorder.Code)
-		w.Write(recorder.Body.Bytes())
+	job := c.newUploadJob(uploadID, staged, placementFrom(values))
+	if err := c.jobRepo.Create(job); err != nil {
+		os.Remove(staged.Path)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create upload job", err)
 		return
 	}
 
-	var fileResp FileResponse
-	if err := json.Unmarshal(recorder.Body.Bytes(), &fileResp); err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"status":  "error",
-			"message": "Failed to parse handler response",
-		})
+	if !c.enqueue(job.ID) {
+		job.Finish(model.JobFailed)
+		job.ResultCode = http.StatusServiceUnavailable
+		c.jobRepo.Update(job)
+		os.Remove(staged.Path)
+		respondWithError(w, http.StatusServiceUnavailable, "Upload queue is full, try again later", nil)
 		return
 	}
 
-	// Only upload to AWS after successful validation
-	key, originalName, err := c.awsService.UploadFile(filePath, header.Filename, strconv.Itoa(time.Now().Year()), r.FormValue("intake"), r.FormValue("team_id"))
+	statusURL := "/api/v1/uploads/" + job.ID
+	c.events.Publish(job.ID, progress.EventQueued, map[string]interface{}{
+		"job_id":     job.ID,
+		"status_url": statusURL,
+		"stages":     job.Stages,
+	})
+
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":     "accepted",
+		"message":    "File accepted for processing",
+		"job_id":     job.ID,
+		"status_url": statusURL,
+		"events_url": statusURL + "/events",
+	})
+}
+
+// saveStaged copies a multipart file into the upload directory. The caller
+// owns the returned path.
+func (c *UploadController) saveStaged(header *multipart.FileHeader, docType string) (*pipeline.File, error) {
+	file, err := header.Open()
 	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"status":  "error",
-			"message": "Failed to upload to cloud storage",
-		})
-		return
+		return nil, err
+	}
+	defer file.Close()
+
+	// Digests are taken on the way to disk so the file is read only once
+	digest := utils.NewDigestWriter()
+	filePath := filepath.Join(c.uploadDir, generateUniqueFilename(header.Filename))
+	if err := saveUploadedFile(io.TeeReader(file, digest), filePath); err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	return &pipeline.File{
+		OriginalName: utils.DisplayFilename(header.Filename),
+		Size:         header.Size,
+		ContentType:  header.Header.Get("Content-Type"),
+		DocType:      docType,
+		Path:         filePath,
+		Digest:       digest.Digest(),
+	}, nil
+}
+
+// storeFile uploads a processed file to S3 and returns the record to persist.
+// versions holds the versions handed out to the other files of a batch,
+// which are not recorded yet; it is nil for single files. Creating the
+// record fails with repository.ErrVersionTaken if another upload got the
+// version first.
+func (c *UploadController) storeFile(staged *pipeline.File, p placement, versions map[string]int) (*model.File, error) {
+	version, err := c.fileRepo.NextVersion(p.TeamID, p.Intake, staged.DocType)
+	if err != nil {
+		return nil, err
+	}
+	if versions != nil {
+		version = max(version, versions[staged.DocType]+1)
+		versions[staged.DocType] = version
+	}
+	team, err := c.findTeam(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key's {year} is the year of the file's creation time
+	createdAt := time.Now()
+	key, originalName, err := c.awsService.UploadFile(staged.Path, service.ObjectKey{
+		CreatedAt:    createdAt,
+		AcademicYear: p.AcademicYear,
+		Intake:       p.Intake,
+		TeamID:       p.TeamID,
+		Session:      p.Session,
+		DocType:      staged.DocType,
+		Version:      version,
+		FileName:     staged.OriginalName,
+	}, staged.Digest)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate presigned URL
 	downloadURL, err := c.awsService.GeneratePresignedURL(key)
 	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"status":  "error",
-			"message": "Failed to generate download link",
-		})
-		return
+		c.awsService.DeleteFile(key)
+		return nil, err
 	}
 
-	// Save file metadata to database
-	fileRecord := &model.File{
+	record := &model.File{
 		OriginalName: originalName,
 		StorageKey:   key,
 		DownloadURL:  downloadURL,
-		Size:         header.Size,
-		TeamID:       r.FormValue("team_id"),
-		FileType:     filepath.Ext(header.Filename)[1:],
-		ContentType:  header.Header.Get("Content-Type"),
+		Size:         staged.Size,
+		TeamID:       p.TeamID,
+		Intake:       p.Intake,
+		AcademicYear: p.AcademicYear,
+		Session:      p.Session,
+		UploadedBy:   p.UploadedBy,
+		FileType:     filepath.Ext(staged.OriginalName)[1:],
+		DocType:      staged.DocType,
+		Version:      version,
+		ContentType:  staged.ContentType,
+		CreatedAt:    createdAt,
+
+		Document:          staged.Document,
+		Signature:         staged.Signature,
+		TopicVector:       staged.TopicVector,
+		CodeSignature:     staged.CodeSignature,
+		PlagiarismReports: plagiarismReports(staged, p),
+
+		ChecksumMD5:     staged.Digest.MD5Hex(),
+		ChecksumSHA256:  staged.Digest.SHA256Hex(),
+		IntegrityStatus: model.IntegrityUnverified,
+	}
+	if team != nil {
+		record.IntakeRefID = &team.IntakeID
+		record.TeamRefID = &team.ID
+	}
+	if len(staged.Digest.SHA256) > 0 {
+		// S3 has checked the object against the digest on the way in
+		now := time.Now()
+		record.IntegrityStatus = model.IntegrityOK
+		record.VerifiedAt = &now
+	}
+	if staged.Scan != nil {
+		record.ScanStatus = string(staged.Scan.Status)
+		record.ScanEngine = staged.Scan.Engine
+		record.ScanSignature = staged.Scan.Signature
+		record.ScannedAt = &staged.Scan.ScannedAt
+	}
+	if staged.Plagiarism != nil {
+		record.PlagiarismStatus = string(staged.Plagiarism.Status)
+		record.PlagiarismProvider = staged.Plagiarism.Provider
+		if staged.Plagiarism.Status == service.PlagiarismChecked {
+			record.PlagiarismPercent = &staged.Plagiarism.MatchPercent
+			record.PlagiarismCheckedAt = &staged.Plagiarism.CheckedAt
+		}
+	}
+	if staged.Clearance != nil {
+		record.PlagiarismStatus = model.PlagiarismOverridden
+	}
+
+	return record, nil
+}
+
+// findTeam returns the team record the file belongs to, or nil when the
+// team is not recorded, as with a registry file.
+func (c *UploadController) findTeam(p placement) (*model.Team, error) {
+	team, err := c.teamRepo.Find(p.Intake, p.TeamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return team, err
+}
+
+// discardStoredFiles removes already uploaded objects when the files could
+// not be recorded in the database.
+func (c *UploadController) discardStoredFiles(files ...*model.File) {
+	for _, f := range files {
+		if err := c.awsService.DeleteFile(f.StorageKey); err != nil {
+			log.Printf("Warning: failed to remove orphaned object %s: %v", f.StorageKey, err)
+		}
+	}
+}
+
+// fileInfo summarises a processed file for the client.
+func fileInfo(f *pipeline.File) FileResponse {
+	message := "File processed successfully"
+	switch f.Type {
+	case utils.PDF:
+		message = "PDF processed successfully"
+	case utils.Archive:
+		message = "Archive processed successfully"
 	}
 
-	if err := c.fileRepo.Create(fileRecord); err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
+	return FileResponse{
+		Status:   "success",
+		Message:  message,
+		FileName: f.OriginalName,
+		FileSize: f.Size,
+		FileType: filepath.Ext(f.OriginalName),
+		Metadata: f.Metadata,
+	}
+}
+
+// processingError turns a pipeline error into a status code and response body.
+func processingError(err error) (int, map[string]interface{}) {
+	var stageErr *pipeline.Error
+	if !errors.As(err, &stageErr) {
+		return http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
-			"message": "Failed to save file metadata",
-		})
-		return
+			"message": "File processing failed",
+			"error":   err.Error(),
+		}
 	}
 
-	// Return success response with handler data and upload info
-	response := map[string]interface{}{
-		"status":      "success",
-		"message":     "File successfully processed and uploaded",
-		"fileInfo":    fileResp,
-		"downloadURL": downloadURL,
+	// Services that could not be reached report the underlying error, checks
+	// that failed report what was wrong with the file
+	if stageErr.Err != nil {
+		return stageErr.Code, map[string]interface{}{
+			"status":  "error",
+			"message": stageErr.Message,
+			"error":   stageErr.Err.Error(),
+			"stage":   stageErr.Stage,
+		}
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	return stageErr.Code, map[string]interface{}{
+		"status":  "error",
+		"error":   stageErr.Message,
+		"stage":   stageErr.Stage,
+		"details": stageErr.Details,
+	}
 }
 
 // func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -340,13 +551,13 @@ func (c *UploadController) HandleFileUpload(w http.ResponseWriter, r *http.Reque
 // }
 
 func generateUniqueFilename(original string) string {
-	ext := filepath.Ext(original)
+	ext := filepath.Ext(utils.SanitizeFilename(original))
 	randomBytes := make([]byte, 8)
 	rand.Read(randomBytes)
 	return hex.EncodeToString(randomBytes) + ext
 }
 
-func saveUploadedFile(file multipart.File, dstPath string) error {
+func saveUploadedFile(file io.Reader, dstPath string) error {
 	dst, err := os.Create(dstPath)
 	println(dstPath)
 	if err != nil {
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/progress"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+)
+
+const (
+	sseHeartbeat  = 15 * time.Second
+	sseRetryDelay = 3000
+)
+
+// StreamUploadEvents streams the progress of an upload as Server-Sent
+// Events: transfer progress, pipeline stages and their results, and a final
+// complete or failed event, after which the stream ends. Clients that
+// reconnect with Last-Event-ID are replayed what they missed.
+func (c *UploadController) StreamUploadEvents(w http.ResponseWriter, r *http.Request) {
+	values := validation.Values{"id": chi.URLParam(r, "id")}
+	if !validate(w, c.schemas.uploadID, values) {
+		return
+	}
+	id := values["id"]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming is not supported", nil)
+		return
+	}
+
+	lastEventID := lastEventID(r)
+	sub := c.events.Subscribe(id, lastEventID)
+	defer sub.Cancel()
+
+	// Events published before a restart are gone; a finished job is
+	// answered from its stored outcome instead
+	var stored *progress.Event
+	if sub.New {
+		if job, err := c.jobRepo.FindByID(id); err == nil && job.CompletedAt != nil {
+			stored = storedOutcome(job)
+		}
+	}
+
+	// 204 tells EventSource not to reconnect once the client has seen the end
+	if ended(sub, stored, lastEventID) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryDelay)
+
+	if stored != nil {
+		writeEvent(w, *stored)
+		flusher.Flush()
+		return
+	}
+
+	for _, event := range sub.Replay {
+		writeEvent(w, event)
+		if event.Terminal() {
+			flusher.Flush()
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Either the stream ended or this client fell behind; in
+				// the latter case it reconnects and catches up
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+			if event.Terminal() {
+				return
+			}
+		}
+	}
+}
+
+func lastEventID(r *http.Request) int64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		// EventSource polyfills cannot always set headers
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseInt(value, 10, 64)
+	return id
+}
+
+func ended(sub *progress.Subscription, stored *progress.Event, lastEventID int64) bool {
+	if stored != nil {
+		return lastEventID >= stored.ID
+	}
+	return sub.Done && len(sub.Replay) == 0
+}
+
+func storedOutcome(job *model.UploadJob) *progress.Event {
+	eventType := progress.EventFailed
+	if job.Status == model.JobSucceeded {
+		eventType = progress.EventComplete
+	}
+	// The event that ended the stream was published after the job was
+	// completed, so clients that saw it resume from this ID or later
+	return &progress.Event{
+		ID:   progress.EventIDAt(*job.CompletedAt),
+		Type: eventType,
+		Time: *job.CompletedAt,
+		Data: jobOutcome(job),
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event progress.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
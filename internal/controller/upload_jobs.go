@@ -0,0 +1,406 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/pipeline"
+	"github.com/sohan-reza/capstone-core/internal/progress"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+// stageStorage follows the processing pipeline stages in every job.
+const stageStorage = "storage"
+
+// storeAttempts is how often a file is stored again under the next version
+// when another upload took its version.
+const storeAttempts = 3
+
+// stageValidation covers the pipeline runs of every part of a batch
+// submission job.
+const stageValidation = "validation"
+
+func (c *UploadController) newUploadJob(id string, staged *pipeline.File, p placement) *model.UploadJob {
+	if id == "" {
+		id = generateJobID()
+	}
+	job := &model.UploadJob{
+		ID:           id,
+		Status:       model.JobQueued,
+		OriginalName: staged.OriginalName,
+		Size:         staged.Size,
+		ContentType:  staged.ContentType,
+		TeamID:       p.TeamID,
+		Intake:       p.Intake,
+		AcademicYear: p.AcademicYear,
+		Session:      p.Session,
+		UploadedBy:   p.UploadedBy,
+		DocType:      staged.DocType,
+		TempPath:     staged.Path,
+
+		ChecksumMD5:    staged.Digest.MD5Hex(),
+		ChecksumSHA256: staged.Digest.SHA256Hex(),
+
+		WorkerID:       c.workerID,
+		LeaseExpiresAt: c.leaseExpiry(),
+	}
+	job.Stages = c.pendingStages(job)
+	return job
+}
+
+// pendingStages lists the stages the job's file type goes through. The type
+// is only known for certain once type detection has run, so the list is
+// based on the file name.
+func (c *UploadController) pendingStages(job *model.UploadJob) []model.JobStage {
+	if len(job.Parts) > 0 {
+		return []model.JobStage{
+			{Name: stageValidation, Status: model.StagePending},
+			{Name: stageStorage, Status: model.StagePending},
+		}
+	}
+	names := append(c.processor.Stages(utils.DetectFileTypeByName(job.OriginalName)), stageStorage)
+	stages := make([]model.JobStage, 0, len(names))
+	for _, name := range names {
+		stages = append(stages, model.JobStage{Name: name, Status: model.StagePending})
+	}
+	return stages
+}
+
+func (c *UploadController) leaseExpiry() *time.Time {
+	until := time.Now().Add(c.jobLease)
+	return &until
+}
+
+// enqueue queues a job this instance holds the lease on. The lease is
+// renewed until the job has run.
+func (c *UploadController) enqueue(jobID string) bool {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	if c.held[jobID] {
+		return true
+	}
+	select {
+	case c.jobs <- jobID:
+		c.held[jobID] = true
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *UploadController) release(jobID string) {
+	c.heldMu.Lock()
+	delete(c.held, jobID)
+	c.heldMu.Unlock()
+}
+
+// StartWorkers launches n upload workers. While they run, the leases on the
+// jobs of this instance are renewed and jobs whose lease has expired, left
+// by an instance that stopped, are taken over.
+func (c *UploadController) StartWorkers(ctx context.Context, n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-c.jobs:
+					c.runJob(ctx, id)
+				}
+			}
+		}()
+	}
+
+	// Jobs left by an earlier run of this instance need not wait for their
+	// lease to expire
+	if err := c.jobRepo.ReleaseLeases(c.workerID); err != nil {
+		log.Printf("Warning: failed to release upload job leases: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(c.jobLease / 3)
+		defer ticker.Stop()
+		for {
+			c.renewLeases()
+			c.takeOverAbandoned()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *UploadController) renewLeases() {
+	c.heldMu.Lock()
+	ids := make([]string, 0, len(c.held))
+	for id := range c.held {
+		ids = append(ids, id)
+	}
+	c.heldMu.Unlock()
+
+	if err := c.jobRepo.RenewLeases(c.workerID, ids, *c.leaseExpiry()); err != nil {
+		log.Printf("Warning: failed to renew upload job leases: %v", err)
+	}
+}
+
+// takeOverAbandoned claims and queues the unfinished jobs whose lease has
+// expired. A job that does not fit in the queue is left for its lease to
+// expire again.
+func (c *UploadController) takeOverAbandoned() {
+	abandoned, err := c.jobRepo.FindAbandoned(time.Now())
+	if err != nil {
+		log.Printf("Warning: failed to load abandoned upload jobs: %v", err)
+		return
+	}
+	for _, job := range abandoned {
+		claimed, err := c.jobRepo.Claim(job.ID, c.workerID, *c.leaseExpiry())
+		if err != nil {
+			log.Printf("Warning: failed to claim upload job %s: %v", job.ID, err)
+			continue
+		}
+		if claimed && !c.enqueue(job.ID) {
+			return
+		}
+	}
+}
+
+func (c *UploadController) runJob(ctx context.Context, id string) {
+	defer c.release(id)
+
+	claimed, err := c.jobRepo.Claim(id, c.workerID, *c.leaseExpiry())
+	if err != nil {
+		log.Printf("Failed to claim upload job %s: %v", id, err)
+		return
+	}
+	if !claimed {
+		// Finished already, or taken over by another instance
+		return
+	}
+
+	job, err := c.jobRepo.FindByID(id)
+	if err != nil {
+		log.Printf("Failed to load upload job %s: %v", id, err)
+		return
+	}
+	if len(job.Parts) > 0 {
+		c.runSubmissionJob(ctx, job)
+		return
+	}
+	requeued := false
+	defer func() {
+		if !requeued {
+			os.Remove(job.TempPath)
+		}
+	}()
+
+	if _, err := os.Stat(job.TempPath); err != nil {
+		c.failJob(job, http.StatusGone, map[string]interface{}{
+			"status":  "error",
+			"message": "Uploaded file is no longer available, please upload again",
+		})
+		return
+	}
+
+	staged := &pipeline.File{
+		OriginalName: job.OriginalName,
+		Size:         job.Size,
+		ContentType:  job.ContentType,
+		DocType:      job.DocType,
+		TeamID:       job.TeamID,
+		Intake:       job.Intake,
+		AcademicYear: job.AcademicYear,
+		Path:         job.TempPath,
+		Digest:       utils.DigestFromHex(job.ChecksumMD5, job.ChecksumSHA256),
+	}
+
+	// Jobs taken over from a stopped instance start over from the first
+	// stage
+	job.Status = model.JobProcessing
+	job.Stages = c.pendingStages(job)
+	reported := 0
+	onStage := func(stage string) {
+		reported = c.publishStageResults(job.ID, "", staged, reported)
+		c.startJobStage(job, stage)
+	}
+
+	p := jobPlacement(job)
+	err = c.processor.Run(ctx, staged, onStage)
+	reported = c.publishStageResults(job.ID, "", staged, reported)
+	if err != nil && ctx.Err() != nil {
+		requeued = true
+		c.requeueJob(job)
+		return
+	}
+	if err != nil {
+		c.recordUnstoredChecks(p, staged)
+		code, result := processingError(err)
+		c.failJob(job, code, result)
+		return
+	}
+
+	onStage(stageStorage)
+	var fileRecord *model.File
+	for attempt := 1; ; attempt++ {
+		fileRecord, err = c.storeFile(staged, p, nil)
+		if err != nil {
+			log.Printf("Failed to store %s: %v", job.OriginalName, err)
+			c.recordUnstoredChecks(p, staged)
+			c.failJob(job, http.StatusInternalServerError, map[string]interface{}{
+				"status":  "error",
+				"message": "Failed to upload to cloud storage",
+			})
+			return
+		}
+
+		err = c.fileRepo.Create(fileRecord)
+		if err == nil {
+			break
+		}
+		c.discardStoredFiles(fileRecord)
+		if errors.Is(err, repository.ErrVersionTaken) && attempt < storeAttempts {
+			// Another upload of the same doc_type got the version first
+			continue
+		}
+		c.recordUnstoredChecks(p, staged)
+		c.failJob(job, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to save file metadata",
+		})
+		return
+	}
+
+	c.useClearance(staged, fileRecord.ID)
+	c.indexForSearch(fileRecord.ID)
+
+	job.FileID = &fileRecord.ID
+	job.ResultCode = http.StatusOK
+	job.Result = map[string]interface{}{
+		"status":      "success",
+		"message":     "File successfully processed and uploaded",
+		"fileInfo":    fileInfo(staged),
+		"downloadURL": fileRecord.DownloadURL,
+	}
+	job.Finish(model.JobSucceeded)
+	if err := c.jobRepo.Update(job); err != nil {
+		log.Printf("Warning: failed to update upload job %s: %v", job.ID, err)
+	}
+	c.events.Publish(job.ID, progress.EventComplete, jobOutcome(job))
+}
+
+func jobPlacement(job *model.UploadJob) placement {
+	return placement{
+		TeamID:       job.TeamID,
+		Intake:       job.Intake,
+		AcademicYear: job.AcademicYear,
+		Session:      job.Session,
+		UploadedBy:   job.UploadedBy,
+	}
+}
+
+func (c *UploadController) startJobStage(job *model.UploadJob, stage string) {
+	job.StartStage(stage)
+	if err := c.jobRepo.Update(job); err != nil {
+		log.Printf("Warning: failed to update upload job %s: %v", job.ID, err)
+	}
+	c.events.Publish(job.ID, progress.EventStage, map[string]interface{}{
+		"stage":  stage,
+		"stages": job.Stages,
+	})
+}
+
+// requeueJob puts back a job interrupted by shutdown. It stays queued for
+// the next instance to take over once the lease expires; the caller keeps
+// its staged files.
+func (c *UploadController) requeueJob(job *model.UploadJob) {
+	job.Status = model.JobQueued
+	job.Stage = ""
+	job.Stages = c.pendingStages(job)
+	if err := c.jobRepo.Update(job); err != nil {
+		log.Printf("Warning: failed to update upload job %s: %v", job.ID, err)
+	}
+}
+
+func (c *UploadController) failJob(job *model.UploadJob, code int, result map[string]interface{}) {
+	job.ResultCode = code
+	job.Result = result
+	job.Finish(model.JobFailed)
+	if err := c.jobRepo.Update(job); err != nil {
+		log.Printf("Warning: failed to update upload job %s: %v", job.ID, err)
+	}
+	c.events.Publish(job.ID, progress.EventFailed, jobOutcome(job))
+}
+
+// jobOutcome is the payload of the event that ends a job's stream.
+func jobOutcome(job *model.UploadJob) map[string]interface{} {
+	outcome := map[string]interface{}{
+		"status":      job.Status,
+		"result_code": job.ResultCode,
+		"result":      job.Result,
+		"file_id":     job.FileID,
+		"stages":      job.Stages,
+	}
+	if job.SubmissionID != nil {
+		outcome["submission_id"] = job.SubmissionID
+	}
+	return outcome
+}
+
+// publishStageResults publishes the stage results recorded on f starting at
+// index from, and returns the index to continue from. file names the file
+// within a batch submission.
+func (c *UploadController) publishStageResults(uploadID string, file string, f *pipeline.File, from int) int {
+	for _, result := range f.Results[from:] {
+		data := map[string]interface{}{
+			"stage":       result.Stage,
+			"status":      result.Status,
+			"duration_ms": result.Duration.Milliseconds(),
+			"details":     result.Details,
+		}
+		if file != "" {
+			data["file"] = file
+		}
+		c.events.Publish(uploadID, progress.EventStageResult, data)
+	}
+	return len(f.Results)
+}
+
+// GetUploadJob reports the stage-by-stage status of an upload and, once it
+// has succeeded, the stored file record.
+func (c *UploadController) GetUploadJob(w http.ResponseWriter, r *http.Request) {
+	values := validation.Values{"id": chi.URLParam(r, "id")}
+	if !validate(w, c.schemas.uploadID, values) {
+		return
+	}
+
+	job, err := c.jobRepo.FindByID(values["id"])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(w, http.StatusNotFound, "Upload not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch upload", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"upload": job,
+	})
+}
+
+func generateJobID() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/progress"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/service"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+)
+
+// requestSchemas declares the fields each endpoint accepts.
+type requestSchemas struct {
+	upload     validation.Schema
+	submission validation.Schema
+	teamFiles  validation.Schema
+	deleteFile validation.Schema
+	recordID   validation.Schema
+	uploadID   validation.Schema
+	flagged    validation.Schema
+	override   validation.Schema
+	intakeList validation.Schema
+	matrix     validation.Schema
+	export     validation.Schema
+	fileList   validation.Schema
+	search     validation.Schema
+	// deadline rejects a doc_type whose deadline in the intake has passed
+	deadline validation.Rule
+}
+
+func newRequestSchemas(registry validation.Registry, sessions []string, supervisors []string) requestSchemas {
+	identifier := validation.Pattern(validation.Identifier, "letters, digits, '-' or '_' and start with a letter or digit")
+
+	var sessionRules []validation.Rule
+	if allowed := nonEmpty(sessions); len(allowed) > 0 {
+		sessionRules = append(sessionRules, validation.OneOf(allowed...))
+	}
+	sessionRules = append(sessionRules,
+		validation.MatchesIntake(registry, "intake", func(i *validation.Intake) string { return i.Session }),
+	)
+
+	placement := validation.Schema{
+		{Name: "intake", Required: true, Rules: []validation.Rule{
+			identifier,
+			validation.KnownIntake(registry),
+		}},
+		{Name: "team_id", Required: true, Rules: []validation.Rule{
+			identifier,
+			validation.KnownTeam(registry, "intake"),
+		}},
+		{Name: "academic_year", Required: true, Rules: []validation.Rule{
+			validation.AcademicYear(),
+			validation.MatchesIntake(registry, "intake", func(i *validation.Intake) string { return i.AcademicYear }),
+		}},
+		{Name: "session", Rules: sessionRules},
+		{Name: "uploaded_by", Rules: []validation.Rule{identifier}},
+	}
+	supervisorRules := []validation.Rule{identifier}
+	if allowed := nonEmpty(supervisors); len(allowed) > 0 {
+		supervisorRules = append(supervisorRules, validation.OneOf(allowed...))
+	}
+
+	uploadIDField := validation.Field{Name: "upload_id", Rules: []validation.Rule{validUploadID}}
+	deadline := validation.BeforeDeadline(registry, "intake", time.Now)
+
+	upload := append(validation.Schema{}, placement...)
+	upload = append(upload,
+		validation.Field{Name: "doc_type", Rules: []validation.Rule{
+			validation.Pattern(validation.DocType, "lowercase letters, digits, '-' or '_'"),
+			deadline,
+		}},
+		uploadIDField,
+	)
+
+	submission := append(validation.Schema{}, placement...)
+	submission = append(submission,
+		validation.Field{Name: "milestone", Rules: []validation.Rule{identifier}},
+		uploadIDField,
+	)
+
+	return requestSchemas{
+		upload:     upload,
+		submission: submission,
+		teamFiles: validation.Schema{
+			{Name: "team_id", Required: true, Rules: []validation.Rule{identifier}},
+		},
+		deleteFile: validation.Schema{
+			{Name: "key", Required: true, Rules: []validation.Rule{validation.MaxLength(1024)}},
+		},
+		recordID: validation.Schema{
+			{Name: "id", Required: true, Rules: []validation.Rule{validation.PositiveInt()}},
+		},
+		uploadID: validation.Schema{
+			{Name: "id", Required: true, Rules: []validation.Rule{validUploadID}},
+		},
+		flagged: validation.Schema{
+			{Name: "intake", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "academic_year", Rules: []validation.Rule{validation.AcademicYear()}},
+			{Name: "limit", Rules: []validation.Rule{validation.PositiveInt()}},
+		},
+		override: validation.Schema{
+			{Name: "id", Required: true, Rules: []validation.Rule{validation.PositiveInt()}},
+			{Name: "supervisor", Required: true, Rules: supervisorRules},
+			{Name: "justification", Required: true, Rules: []validation.Rule{
+				validation.MinLength(20),
+				validation.MaxLength(2000),
+			}},
+		},
+		intakeList: validation.Schema{
+			{Name: "intake", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "limit", Rules: []validation.Rule{validation.PositiveInt()}},
+		},
+		matrix: validation.Schema{
+			{Name: "intake", Required: true, Rules: []validation.Rule{identifier}},
+			{Name: "doc_types", Rules: []validation.Rule{validDocTypes}},
+			{Name: "cutoff", Rules: []validation.Rule{validation.Percentage()}},
+		},
+		export: validation.Schema{
+			{Name: "id", Required: true, Rules: []validation.Rule{validation.PositiveInt()}},
+			{Name: "format", Rules: []validation.Rule{validation.OneOf(exportFormats...)}},
+		},
+		fileList: validation.Schema{
+			{Name: "team_id", Rules: []validation.Rule{identifier}},
+			{Name: "intake", Rules: []validation.Rule{identifier}},
+			{Name: "academic_year", Rules: []validation.Rule{validation.AcademicYear()}},
+			{Name: "file_type", Rules: []validation.Rule{identifier}},
+			{Name: "doc_type", Rules: []validation.Rule{validation.Pattern(validation.DocType, "a document type")}},
+			{Name: "uploaded_by", Rules: []validation.Rule{identifier}},
+			{Name: "scan_status", Rules: []validation.Rule{validation.OneOf(
+				string(service.ScanClean), string(service.ScanInfected), string(service.ScanSkipped), string(service.ScanTooLarge),
+			)}},
+			{Name: "plagiarism_status", Rules: []validation.Rule{validation.OneOf(
+				model.PlagiarismChecked, model.PlagiarismPending, model.PlagiarismProviderError, model.PlagiarismOverridden,
+			)}},
+			{Name: "integrity_status", Rules: []validation.Rule{validation.OneOf(
+				model.IntegrityUnverified, model.IntegrityOK, model.IntegrityCorrupt, model.IntegrityMissing,
+			)}},
+			{Name: "created_from", Rules: []validation.Rule{validTimeOrDate}},
+			{Name: "created_to", Rules: []validation.Rule{validTimeOrDate}},
+			{Name: "sort", Rules: []validation.Rule{validFileSort}},
+			{Name: "fields", Rules: []validation.Rule{validFileFields}},
+			{Name: "cursor", Rules: []validation.Rule{validFileCursor}},
+			{Name: "limit", Rules: []validation.Rule{validation.PositiveInt()}},
+		},
+		search: validation.Schema{
+			{Name: "q", Required: true, Rules: []validation.Rule{validation.MaxLength(200)}},
+			{Name: "academic_year", Rules: []validation.Rule{validation.AcademicYear()}},
+			{Name: "intake", Rules: []validation.Rule{identifier}},
+			{Name: "doc_type", Rules: []validation.Rule{validation.Pattern(validation.DocType, "a document type")}},
+			{Name: "file_type", Rules: []validation.Rule{identifier}},
+			{Name: "team_id", Rules: []validation.Rule{identifier}},
+			{Name: "limit", Rules: []validation.Rule{validation.PositiveInt()}},
+			{Name: "page", Rules: []validation.Rule{validation.PositiveInt()}},
+		},
+		deadline: deadline,
+	}
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// validDocTypes accepts a comma separated list of document types.
+func validDocTypes(field, value string, values validation.Values) error {
+	for _, docType := range strings.Split(value, ",") {
+		if !validation.DocType.MatchString(strings.TrimSpace(docType)) {
+			return validation.Reject(field, "format", field+" must be a comma separated list of document types")
+		}
+	}
+	return nil
+}
+
+// parseTimeOrDate reads an RFC 3339 time or a date, which is midnight UTC.
+// dateOnly reports which it was.
+func parseTimeOrDate(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.DateOnly, value)
+	return t, true, err
+}
+
+func validTimeOrDate(field, value string, values validation.Values) error {
+	if _, _, err := parseTimeOrDate(value); err != nil {
+		return validation.Reject(field, "format", field+" must be a date (2006-01-02) or an RFC 3339 time")
+	}
+	return nil
+}
+
+func validFileSort(field, value string, values validation.Values) error {
+	column := strings.TrimPrefix(value, "-")
+	if !slices.Contains(repository.FileSortColumns(), column) {
+		return validation.Reject(field, "format", field+" must be one of "+
+			strings.Join(repository.FileSortColumns(), ", ")+", with '-' in front for descending order")
+	}
+	return nil
+}
+
+func validFileFields(field, value string, values validation.Values) error {
+	for _, name := range strings.Split(value, ",") {
+		if !slices.Contains(fileFields, strings.TrimSpace(name)) {
+			return validation.Reject(field, "format", field+" must be a comma separated list of "+strings.Join(fileFields, ", "))
+		}
+	}
+	return nil
+}
+
+// validFileCursor accepts a cursor from an earlier page of a listing with
+// the same sort.
+func validFileCursor(field, value string, values validation.Values) error {
+	cursor, err := decodeFileCursor(value)
+	if err != nil || cursor.ID == 0 {
+		return validation.Reject(field, "format", field+" must be a next_cursor returned by the previous page")
+	}
+	if cursor.Sort != cmp.Or(values["sort"], defaultFileSort) {
+		return validation.Reject(field, "sort_mismatch", field+" was returned for a different sort")
+	}
+	return nil
+}
+
+func validUploadID(field, value string, values validation.Values) error {
+	if !progress.ValidID(value) {
+		return validation.Reject(field, "format", field+" must be 8 to 32 letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+// validate checks values against schema and writes the error response when
+// they are not acceptable.
+func validate(w http.ResponseWriter, schema validation.Schema, values validation.Values) bool {
+	err := schema.Validate(values)
+	if err == nil {
+		return true
+	}
+
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		respondWithValidationErrors(w, fieldErrs)
+		return false
+	}
+
+	respondWithError(w, http.StatusInternalServerError, "Failed to validate request", err)
+	return false
+}
+
+func respondWithValidationErrors(w http.ResponseWriter, errs validation.Errors) {
+	respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"status":  "error",
+		"message": "Validation failed",
+		"errors":  errs,
+	})
+}
+
+// placement is where a file belongs: the team, its intake and the academic
+// year and session it was handed in for, and who on the team uploaded it.
+type placement struct {
+	TeamID       string
+	Intake       string
+	AcademicYear string
+	Session      string
+	UploadedBy   string
+	// Batch is set for the files of a batch submission
+	Batch bool
+}
+
+func placementFrom(values validation.Values) placement {
+	return placement{
+		TeamID:       values["team_id"],
+		Intake:       values["intake"],
+		AcademicYear: values["academic_year"],
+		Session:      strings.ToLower(values["session"]),
+		UploadedBy:   values["uploaded_by"],
+	}
+}
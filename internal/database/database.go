@@ -0,0 +1,97 @@
+// Package database opens the database the config names. Postgres is the
+// production database, its schema owned by the migrations in
+// internal/migrate. SQLite lets the API run without a database server for
+// local development and tests; as the migrations are written for Postgres,
+// SQLite schemas are created from the models instead. The SQLite driver is
+// pure Go, so builds without cgo support both.
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sohan-reza/capstone-core/internal/config"
+	"github.com/sohan-reza/capstone-core/internal/migrate"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Drivers
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Memory is the SQLite path of a database that lives only as long as the
+// process.
+const Memory = ":memory:"
+
+// models are the tables of a SQLite schema.
+var models = []interface{}{
+	&model.Intake{}, &model.Team{}, &model.Member{}, &model.Project{},
+	&model.Submission{}, &model.File{}, &model.FileDocument{},
+	&model.IdempotencyRecord{}, &model.UploadJob{},
+	&model.DocumentSignature{}, &model.SimilarityBand{}, &model.TopicVector{},
+	&model.CodeSignature{}, &model.CodeSource{}, &model.CodeFingerprint{},
+	&model.PlagiarismReport{}, &model.PlagiarismReview{}, &model.PlagiarismOverride{},
+	&model.SimilarityMatrix{}, &model.RekeyMove{},
+}
+
+// Open connects to the configured database.
+func Open(cfg *config.Config) (*gorm.DB, error) {
+	switch cfg.Database.Driver {
+	case Postgres:
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+			cfg.Database.Host, cfg.Database.User, cfg.Database.Password, cfg.Database.Name, cfg.Database.Port)
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	case SQLite:
+		return OpenSQLite(cfg.Database.Path)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q, expected %s or %s", cfg.Database.Driver, Postgres, SQLite)
+	}
+}
+
+// OpenSQLite opens the SQLite database at path, creating it if needed, with
+// foreign keys enforced.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != Memory {
+		// Readers are not blocked by the upload workers' writes
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	if path == Memory {
+		// Every connection would get a database of its own
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+// Prepare makes sure the schema matches this build before the database is
+// used. Postgres schemas are only checked, as they are migrated separately;
+// SQLite schemas are created or updated from the models.
+func Prepare(ctx context.Context, db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case SQLite:
+		return db.WithContext(ctx).AutoMigrate(models...)
+	default:
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := migrate.Verify(ctx, sqlDB); err != nil {
+			return fmt.Errorf("%w; run `make migrate`", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,370 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	idempotencyHeader   = "Idempotency-Key"
+	replayedHeader      = "Idempotent-Replayed"
+	maxIdempotencyKey   = 255
+	inFlightPollPeriod  = 250 * time.Millisecond
+	requestSpoolPattern = "idempotency-*.body"
+
+	// multipartSlack allows for the form fields and part headers sent
+	// along with an upload of the largest accepted size
+	multipartSlack = 1 << 20
+)
+
+// Idempotency makes unsafe endpoints safe to retry. The first response to a
+// request carrying an Idempotency-Key header is stored per client and key;
+// later requests with the same key get the stored response back, and
+// concurrent duplicates wait for the in-flight request to finish. A request
+// holds its key under a lock it renews while it runs; once the lock has
+// expired, as when the process died mid-request, a retry takes the key over.
+type Idempotency struct {
+	repo     repository.IdempotencyRepository
+	ttl      time.Duration
+	lock     time.Duration
+	wait     time.Duration
+	spoolDir string
+	maxBody  int64
+}
+
+// NewIdempotency spools request bodies of up to maxUpload bytes, plus room
+// for the multipart framing; larger requests are refused before they reach
+// the disk.
+func NewIdempotency(repo repository.IdempotencyRepository, ttl time.Duration, lock time.Duration, wait time.Duration, spoolDir string, maxUpload int64) *Idempotency {
+	return &Idempotency{
+		repo:     repo,
+		ttl:      ttl,
+		lock:     lock,
+		wait:     wait,
+		spoolDir: spoolDir,
+		maxBody:  maxUpload + multipartSlack,
+	}
+}
+
+func (m *Idempotency) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key is too long", nil)
+			return
+		}
+
+		// The body is spooled to disk so it can be hashed and still be read
+		// by the handler.
+		r.Body = http.MaxBytesReader(w, r.Body, m.maxBody)
+		spool, hash, err := m.spoolAndHash(r)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body is too large", nil)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to read request body", err)
+			return
+		}
+		defer func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}()
+		r.Body = spool
+
+		now := time.Now()
+		lockedUntil := now.Add(m.lock)
+		record := &model.IdempotencyRecord{
+			ClientID:    clientID(r),
+			Key:         key,
+			RequestHash: hash,
+			Status:      model.IdempotencyInFlight,
+			ExpiresAt:   now.Add(m.ttl),
+			LockedUntil: &lockedUntil,
+		}
+
+		existing, acquired, err := m.repo.Acquire(record)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to record idempotency key", err)
+			return
+		}
+
+		if !acquired {
+			m.replay(w, r, existing, hash)
+			return
+		}
+
+		m.execute(w, r, next, record)
+	})
+}
+
+func (m *Idempotency) execute(w http.ResponseWriter, r *http.Request, next http.Handler, record *model.IdempotencyRecord) {
+	capture := &captureWriter{ResponseWriter: w, code: http.StatusOK}
+
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		// Panics and server errors release the key so the client may retry
+		if err := m.repo.Release(record.ID); err != nil {
+			log.Printf("Warning: failed to release idempotency key %s: %v", record.Key, err)
+		}
+	}()
+
+	stopRenewing := m.renewLock(record)
+	next.ServeHTTP(capture, r)
+	stopRenewing()
+
+	if capture.code >= http.StatusInternalServerError {
+		return
+	}
+
+	if err := m.repo.Complete(record.ID, capture.code, capture.headers, capture.body.Bytes()); err != nil {
+		log.Printf("Warning: failed to store response for idempotency key %s: %v", record.Key, err)
+		return
+	}
+	completed = true
+}
+
+// renewLock keeps the record's lock alive until the returned function is
+// called.
+func (m *Idempotency) renewLock(record *model.IdempotencyRecord) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.lock / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.repo.Renew(record.ID, time.Now().Add(m.lock)); err != nil {
+					log.Printf("Warning: failed to renew idempotency key %s: %v", record.Key, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (m *Idempotency) replay(w http.ResponseWriter, r *http.Request, record *model.IdempotencyRecord, hash string) {
+	if record.RequestHash != hash {
+		writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request", nil)
+		return
+	}
+
+	if record.Status == model.IdempotencyInFlight {
+		var err error
+		record, err = m.awaitCompletion(r.Context(), record)
+		if err != nil {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed", nil)
+			return
+		}
+	}
+
+	for k, v := range record.ResponseHeaders {
+		w.Header()[k] = v
+	}
+	w.Header().Set(replayedHeader, "true")
+	w.WriteHeader(record.ResponseCode)
+	w.Write(record.ResponseBody)
+}
+
+var errStillInFlight = errors.New("request still in flight")
+
+// awaitCompletion polls until the original request finishes, the wait
+// budget runs out, or the client goes away.
+func (m *Idempotency) awaitCompletion(ctx context.Context, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.wait)
+	defer cancel()
+
+	ticker := time.NewTicker(inFlightPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errStillInFlight
+		case <-ticker.C:
+		}
+
+		current, err := m.repo.Find(record.ClientID, record.Key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The original request failed and released the key
+			return nil, errStillInFlight
+		}
+		if err != nil {
+			return nil, err
+		}
+		if current.Status == model.IdempotencyCompleted {
+			return current, nil
+		}
+	}
+}
+
+// StartCleanup deletes expired records every interval until ctx is done.
+func (m *Idempotency) StartCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := m.repo.DeleteExpired(time.Now()); err != nil {
+					log.Printf("Warning: failed to delete expired idempotency keys: %v", err)
+				} else if n > 0 {
+					log.Printf("Deleted %d expired idempotency keys", n)
+				}
+			}
+		}
+	}()
+}
+
+// spoolAndHash copies the request body to a temporary file and returns it
+// rewound together with a hash of the request. Multipart bodies are hashed
+// part by part so that a browser retry, which picks a new boundary, still
+// matches the original request.
+func (m *Idempotency) spoolAndHash(r *http.Request) (*os.File, string, error) {
+	spool, err := os.CreateTemp(m.spoolDir, requestSpoolPattern)
+	if err != nil {
+		return nil, "", err
+	}
+	fail := func(err error) (*os.File, string, error) {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, "", err
+	}
+
+	if _, err := io.Copy(spool, r.Body); err != nil {
+		return fail(err)
+	}
+	r.Body.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		if err := hashMultipart(h, spool, params["boundary"]); err != nil {
+			return fail(err)
+		}
+	} else if _, err := io.Copy(h, spool); err != nil {
+		return fail(err)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+
+	return spool, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashMultipart(h io.Writer, body io.Reader, boundary string) error {
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "part %q %q\n", part.FormName(), part.FileName())
+		if _, err := io.Copy(h, part); err != nil {
+			return err
+		}
+		part.Close()
+	}
+}
+
+// clientID identifies the caller a key belongs to: an explicit X-Client-ID,
+// then a hash of the Authorization header, then the remote address.
+func clientID(r *http.Request) string {
+	if id := r.Header.Get("X-Client-ID"); id != "" {
+		return "client:" + id
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return "auth:" + hex.EncodeToString(sum[:])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// captureWriter passes the response through while keeping a copy of it.
+type captureWriter struct {
+	http.ResponseWriter
+	code        int
+	headers     map[string][]string
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.code = code
+	c.headers = c.ResponseWriter.Header().Clone()
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string, err error) {
+	response := map[string]interface{}{
+		"status":  "error",
+		"message": message,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/progress"
+)
+
+const (
+	transferMinStep     = 64 << 10
+	transferMinInterval = 250 * time.Millisecond
+)
+
+// UploadProgress publishes transfer events while the request body of an
+// upload carrying a client supplied upload ID is being received. It must run
+// before anything that buffers the body, such as the idempotency middleware.
+type UploadProgress struct {
+	broker *progress.Broker
+}
+
+func NewUploadProgress(broker *progress.Broker) *UploadProgress {
+	return &UploadProgress{broker: broker}
+}
+
+func (m *UploadProgress) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := progress.UploadID(r)
+		if id == "" || !progress.ValidID(id) || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body = &countingBody{
+			ReadCloser: r.Body,
+			broker:     m.broker,
+			uploadID:   id,
+			total:      r.ContentLength,
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// countingBody reports how much of the body has been read, at most every
+// transferMinInterval or 1% of the body, whichever is larger.
+type countingBody struct {
+	io.ReadCloser
+	broker   *progress.Broker
+	uploadID string
+	total    int64
+	received int64
+
+	reported     int64
+	lastReported time.Time
+	finished     bool
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.received += int64(n)
+
+	if err == io.EOF {
+		c.report(true)
+	} else if c.due() {
+		c.report(false)
+	}
+	return n, err
+}
+
+func (c *countingBody) due() bool {
+	step := int64(transferMinStep)
+	if c.total > 0 && c.total/100 > step {
+		step = c.total / 100
+	}
+	return c.received-c.reported >= step && time.Since(c.lastReported) >= transferMinInterval
+}
+
+func (c *countingBody) report(done bool) {
+	if c.finished {
+		return
+	}
+	c.finished = done
+	c.reported = c.received
+	c.lastReported = time.Now()
+
+	data := map[string]interface{}{
+		"received": c.received,
+		"done":     done,
+	}
+	if c.total > 0 {
+		data["total"] = c.total
+		data["percent"] = float64(c.received) * 100 / float64(c.total)
+	}
+	c.broker.Publish(c.uploadID, progress.EventTransfer, data)
+}
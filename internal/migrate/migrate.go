@@ -0,0 +1,401 @@
+// Package migrate applies the versioned SQL migrations embedded in the
+// binary. Migrations live in migrations/ as NNNN_name.up.sql and
+// NNNN_name.down.sql pairs and are applied in version order, each in its
+// own transaction, with the applied versions recorded in schema_migrations.
+// A Postgres advisory lock is held while migrating, so instances started
+// together apply each migration once.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// lockKey identifies the advisory lock taken while migrating; it is
+// "capstone" in ASCII.
+const lockKey int64 = 0x63617073746f6e65
+
+var (
+	// ErrOutdated means migrations embedded in the binary have not been
+	// applied to the database.
+	ErrOutdated = errors.New("database schema is out of date")
+	// ErrUnknownVersion means the database has migrations applied that the
+	// binary does not know, usually because it is older than the schema.
+	ErrUnknownVersion = errors.New("database schema is newer than this build")
+)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// State is a migration and when it was applied, if it was. Migrations
+// applied to the database but unknown to the binary are Unknown.
+type State struct {
+	Migration
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in fsys, sorted by version. Every migration
+// needs an up file; the down file is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	hasUp := make(map[int64]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_create_table.up.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %s: version %d is also used by %s", entry.Name(), version, migration)
+		}
+		if m[3] == "up" {
+			migration.Up = string(body)
+			hasUp[version] = true
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if !hasUp[m.Version] {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Embedded returns the migrations built into the binary.
+func Embedded() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Migrator applies migrations to a Postgres database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the embedded migrations.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Embedded()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := apply(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				migration.Version, migration.Name, time.Now())
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		slices.Sort(versions)
+		slices.Reverse(versions)
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("migration %04d_%s is not known to this build: %w", version, done[version], ErrUnknownVersion)
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("migration %s has no down file", migration)
+			}
+			err := apply(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every migration by version with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]State, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var states []State
+	exists, err := tableExists(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[int64]State)
+	if exists {
+		result, err := conn.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer result.Close()
+		for result.Next() {
+			var s State
+			var appliedAt time.Time
+			if err := result.Scan(&s.Version, &s.Name, &appliedAt); err != nil {
+				return nil, err
+			}
+			s.AppliedAt = &appliedAt
+			s.Unknown = true
+			rows[s.Version] = s
+		}
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, migration := range m.migrations {
+		s := State{Migration: migration}
+		if row, ok := rows[migration.Version]; ok {
+			s.AppliedAt = row.AppliedAt
+			delete(rows, migration.Version)
+		}
+		states = append(states, s)
+	}
+	for _, row := range rows {
+		states = append(states, row)
+	}
+	slices.SortFunc(states, func(a, b State) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return states, nil
+}
+
+// Check returns ErrOutdated if a migration is pending, or ErrUnknownVersion
+// if the database has migrations the binary does not know. It only reads
+// the schema table.
+func (m *Migrator) Check(ctx context.Context) error {
+	states, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending, unknown []string
+	for _, s := range states {
+		switch {
+		case s.Unknown:
+			unknown = append(unknown, s.String())
+		case s.AppliedAt == nil:
+			pending = append(pending, s.String())
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s applied", ErrUnknownVersion, strings.Join(unknown, ", "))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s pending", ErrOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Verify checks that db has exactly the embedded migrations applied. Programs
+// call it at startup instead of migrating the schema themselves.
+func Verify(ctx context.Context, db *sql.DB) error {
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
+	return m.Check(ctx)
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs fn on a connection holding the migration lock, creating the
+// schema table first. Other instances wait for the lock, then find the
+// migrations already applied.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply runs a migration's statements and the schema table update in one
+// transaction.
+func apply(ctx context.Context, conn *sql.Conn, statements string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var name string
+		if err := rows.Scan(&version, &name); err != nil {
+			return nil, err
+		}
+		versions[version] = name
+	}
+	return versions, rows.Err()
+}
+
+func tableExists(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var exists bool
+	err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	return exists, err
+}
+
+var unsafeName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes an empty up and down migration to dir, numbered after the
+// last migration there, and returns their paths.
+func Create(dir string, name string) (string, string, error) {
+	name = strings.Trim(unsafeName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name must contain letters or digits")
+	}
+
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	next := Migration{Version: 1, Name: name}
+	if len(existing) > 0 {
+		next.Version = existing[len(existing)-1].Version + 1
+	}
+
+	up := filepath.Join(dir, next.String()+".up.sql")
+	down := filepath.Join(dir, next.String()+".down.sql")
+	for _, path := range []string{up, down} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		f.Close()
+	}
+	return up, down, nil
+}
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS "rekey_moves";
+DROP TABLE IF EXISTS "topic_vectors";
+DROP TABLE IF EXISTS "similarity_matrices";
+DROP TABLE IF EXISTS "code_fingerprints";
+DROP TABLE IF EXISTS "code_sources";
+DROP TABLE IF EXISTS "code_signatures";
+DROP TABLE IF EXISTS "plagiarism_overrides";
+DROP TABLE IF EXISTS "plagiarism_reports";
+DROP TABLE IF EXISTS "plagiarism_reviews";
+DROP TABLE IF EXISTS "similarity_bands";
+DROP TABLE IF EXISTS "document_signatures";
+DROP TABLE IF EXISTS "upload_jobs";
+DROP TABLE IF EXISTS "idempotency_records";
+DROP TABLE IF EXISTS "file_documents";
+DROP TABLE IF EXISTS "files";
+DROP TABLE IF EXISTS "submissions";
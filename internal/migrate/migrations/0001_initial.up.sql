@@ -0,0 +1,303 @@
+-- Baseline schema, as previously created by AutoMigrate. Every statement
+-- is guarded so databases created that way are adopted: tables they have
+-- are kept, and the files table of the first releases, which AutoMigrate
+-- only created with its first columns, gets the columns added since.
+
+CREATE TABLE IF NOT EXISTS "submissions" (
+    "id" bigserial,
+    "team_id" text,
+    "intake" text,
+    "academic_year" text,
+    "session" text,
+    "milestone" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_submissions_team_id" ON "submissions" ("team_id");
+
+CREATE TABLE IF NOT EXISTS "files" (
+    "id" bigserial,
+    "original_name" text,
+    "storage_key" text,
+    "download_url" text,
+    "size" bigint,
+    "team_id" text,
+    "intake" text,
+    "academic_year" text,
+    "session" text,
+    "file_type" text,
+    "doc_type" text,
+    "version" bigint DEFAULT 1,
+    "submission_id" bigint,
+    "content_type" text,
+    "created_at" timestamptz,
+    "scan_status" text,
+    "scan_engine" text,
+    "scan_signature" text,
+    "scanned_at" timestamptz,
+    "plagiarism_status" text,
+    "plagiarism_percent" decimal,
+    "plagiarism_provider" text,
+    "plagiarism_checked_at" timestamptz,
+    "checksum_md5" text,
+    "checksum_sha256" text,
+    "integrity_status" text,
+    "verified_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_submissions_files" FOREIGN KEY ("submission_id") REFERENCES "submissions"("id")
+);
+ALTER TABLE "files"
+    ADD COLUMN IF NOT EXISTS "intake" text,
+    ADD COLUMN IF NOT EXISTS "academic_year" text,
+    ADD COLUMN IF NOT EXISTS "session" text,
+    ADD COLUMN IF NOT EXISTS "doc_type" text,
+    ADD COLUMN IF NOT EXISTS "version" bigint DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS "submission_id" bigint,
+    ADD COLUMN IF NOT EXISTS "scan_status" text,
+    ADD COLUMN IF NOT EXISTS "scan_engine" text,
+    ADD COLUMN IF NOT EXISTS "scan_signature" text,
+    ADD COLUMN IF NOT EXISTS "scanned_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "plagiarism_status" text,
+    ADD COLUMN IF NOT EXISTS "plagiarism_percent" decimal,
+    ADD COLUMN IF NOT EXISTS "plagiarism_provider" text,
+    ADD COLUMN IF NOT EXISTS "plagiarism_checked_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "checksum_md5" text,
+    ADD COLUMN IF NOT EXISTS "checksum_sha256" text,
+    ADD COLUMN IF NOT EXISTS "integrity_status" text,
+    ADD COLUMN IF NOT EXISTS "verified_at" timestamptz;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_submissions_files') THEN
+        ALTER TABLE "files" ADD CONSTRAINT "fk_submissions_files"
+            FOREIGN KEY ("submission_id") REFERENCES "submissions"("id");
+    END IF;
+END
+$$;
+CREATE INDEX IF NOT EXISTS "idx_files_intake" ON "files" ("intake");
+CREATE INDEX IF NOT EXISTS "idx_files_submission_id" ON "files" ("submission_id");
+CREATE INDEX IF NOT EXISTS "idx_files_plagiarism_status" ON "files" ("plagiarism_status");
+CREATE INDEX IF NOT EXISTS "idx_files_integrity_status" ON "files" ("integrity_status");
+CREATE INDEX IF NOT EXISTS "idx_files_verified_at" ON "files" ("verified_at");
+
+CREATE TABLE IF NOT EXISTS "file_documents" (
+    "id" bigserial,
+    "file_id" bigint NOT NULL,
+    "page_count" bigint,
+    "title" text,
+    "author" text,
+    "producer" text,
+    "creation_date" timestamptz,
+    "encrypted" boolean,
+    "word_count" bigint,
+    "text" text,
+    "created_at" timestamptz,
+    "unembedded_fonts" text,
+    "pdfa_conformance" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_files_document" FOREIGN KEY ("file_id") REFERENCES "files"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_file_documents_file_id" ON "file_documents" ("file_id");
+
+CREATE TABLE IF NOT EXISTS "idempotency_records" (
+    "id" bigserial,
+    "client_id" text NOT NULL,
+    "key" text NOT NULL,
+    "request_hash" text NOT NULL,
+    "status" text NOT NULL,
+    "response_code" bigint,
+    "response_headers" text,
+    "response_body" bytea,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idempotency_client_key" ON "idempotency_records" ("client_id", "key");
+CREATE INDEX IF NOT EXISTS "idx_idempotency_records_expires_at" ON "idempotency_records" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "upload_jobs" (
+    "id" varchar(32),
+    "status" text,
+    "stage" text,
+    "stages" text,
+    "result_code" bigint,
+    "result" text,
+    "file_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    "original_name" text,
+    "size" bigint,
+    "content_type" text,
+    "team_id" text,
+    "intake" text,
+    "doc_type" text,
+    "academic_year" text,
+    "session" text,
+    "temp_path" text,
+    "checksum_md5" text,
+    "checksum_sha256" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_upload_jobs_file" FOREIGN KEY ("file_id") REFERENCES "files"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_upload_jobs_status" ON "upload_jobs" ("status");
+
+CREATE TABLE IF NOT EXISTS "document_signatures" (
+    "id" bigserial,
+    "file_id" bigint NOT NULL,
+    "shingle_count" bigint,
+    "min_hash" bytea,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_files_signature" FOREIGN KEY ("file_id") REFERENCES "files"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_document_signatures_file_id" ON "document_signatures" ("file_id");
+
+CREATE TABLE IF NOT EXISTS "similarity_bands" (
+    "id" bigserial,
+    "signature_id" bigint NOT NULL,
+    "key" bigint NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_document_signatures_bands" FOREIGN KEY ("signature_id") REFERENCES "document_signatures"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_similarity_bands_signature_id" ON "similarity_bands" ("signature_id");
+CREATE INDEX IF NOT EXISTS "idx_similarity_bands_key" ON "similarity_bands" ("key");
+
+CREATE TABLE IF NOT EXISTS "plagiarism_reviews" (
+    "id" bigserial,
+    "status" text,
+    "original_name" text,
+    "team_id" text,
+    "intake" text,
+    "academic_year" text,
+    "session" text,
+    "doc_type" text,
+    "content_type" text,
+    "size" bigint,
+    "checksum_md5" text,
+    "checksum_sha256" text,
+    "quarantine_path" text,
+    "file_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_reviews_status" ON "plagiarism_reviews" ("status");
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_reviews_intake" ON "plagiarism_reviews" ("intake");
+
+CREATE TABLE IF NOT EXISTS "plagiarism_reports" (
+    "id" bigserial,
+    "file_id" bigint,
+    "review_id" bigint,
+    "original_name" text,
+    "team_id" text,
+    "intake" text,
+    "academic_year" text,
+    "checker" text,
+    "provider" text,
+    "status" text,
+    "score" decimal,
+    "threshold" decimal,
+    "flagged" boolean,
+    "sources" text,
+    "raw_payload" jsonb,
+    "checked_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_files_plagiarism_reports" FOREIGN KEY ("file_id") REFERENCES "files"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_plagiarism_reviews_reports" FOREIGN KEY ("review_id") REFERENCES "plagiarism_reviews"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_reports_file_id" ON "plagiarism_reports" ("file_id");
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_reports_review_id" ON "plagiarism_reports" ("review_id");
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_intake_flagged" ON "plagiarism_reports" ("intake", "flagged");
+
+CREATE TABLE IF NOT EXISTS "plagiarism_overrides" (
+    "id" bigserial,
+    "review_id" bigint,
+    "file_id" bigint,
+    "team_id" text,
+    "intake" text,
+    "supervisor" text,
+    "justification" text,
+    "score" decimal,
+    "threshold" decimal,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_plagiarism_reviews_overrides" FOREIGN KEY ("review_id") REFERENCES "plagiarism_reviews"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_overrides_review_id" ON "plagiarism_overrides" ("review_id");
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_overrides_file_id" ON "plagiarism_overrides" ("file_id");
+CREATE INDEX IF NOT EXISTS "idx_plagiarism_overrides_intake" ON "plagiarism_overrides" ("intake");
+
+CREATE TABLE IF NOT EXISTS "code_signatures" (
+    "id" bigserial,
+    "file_id" bigint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_files_code_signature" FOREIGN KEY ("file_id") REFERENCES "files"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_code_signatures_file_id" ON "code_signatures" ("file_id");
+
+CREATE TABLE IF NOT EXISTS "code_sources" (
+    "id" bigserial,
+    "signature_id" bigint NOT NULL,
+    "path" text,
+    "language" text,
+    "lines" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_code_signatures_sources" FOREIGN KEY ("signature_id") REFERENCES "code_signatures"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_code_sources_signature_id" ON "code_sources" ("signature_id");
+
+CREATE TABLE IF NOT EXISTS "code_fingerprints" (
+    "id" bigserial,
+    "source_id" bigint NOT NULL,
+    "hash" bigint NOT NULL,
+    "start_line" bigint,
+    "end_line" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_code_sources_fingerprints" FOREIGN KEY ("source_id") REFERENCES "code_sources"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_code_fingerprints_source_id" ON "code_fingerprints" ("source_id");
+CREATE INDEX IF NOT EXISTS "idx_code_fingerprints_hash" ON "code_fingerprints" ("hash");
+
+CREATE TABLE IF NOT EXISTS "similarity_matrices" (
+    "id" bigserial,
+    "intake" text,
+    "doc_types" text,
+    "cutoff" decimal,
+    "status" text,
+    "error" text,
+    "result" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_similarity_matrices_intake" ON "similarity_matrices" ("intake");
+CREATE INDEX IF NOT EXISTS "idx_similarity_matrices_status" ON "similarity_matrices" ("status");
+
+CREATE TABLE IF NOT EXISTS "topic_vectors" (
+    "id" bigserial,
+    "file_id" bigint NOT NULL,
+    "terms" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_files_topic_vector" FOREIGN KEY ("file_id") REFERENCES "files"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_topic_vectors_file_id" ON "topic_vectors" ("file_id");
+
+CREATE TABLE IF NOT EXISTS "rekey_moves" (
+    "id" bigserial,
+    "layout" text,
+    "file_id" bigint,
+    "old_key" text,
+    "new_key" text,
+    "status" text,
+    "error" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_rekey_layout_file" ON "rekey_moves" ("layout", "file_id");
+CREATE INDEX IF NOT EXISTS "idx_rekey_moves_status" ON "rekey_moves" ("status");
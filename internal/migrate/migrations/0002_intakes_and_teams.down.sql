@@ -0,0 +1,8 @@
+ALTER TABLE "files"
+    DROP COLUMN IF EXISTS "team_ref_id",
+    DROP COLUMN IF EXISTS "intake_ref_id";
+
+DROP TABLE IF EXISTS "projects";
+DROP TABLE IF EXISTS "members";
+DROP TABLE IF EXISTS "teams";
+DROP TABLE IF EXISTS "intakes";
@@ -0,0 +1,61 @@
+CREATE TABLE "intakes" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "academic_year" text,
+    "session" text,
+    "deadlines" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+-- Intake names and team codes are matched case-insensitively
+CREATE UNIQUE INDEX "idx_intakes_name" ON "intakes" (lower("name"));
+
+CREATE TABLE "teams" (
+    "id" bigserial,
+    "intake_id" bigint NOT NULL,
+    "code" text NOT NULL,
+    "name" text,
+    "supervisor" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_intakes_teams" FOREIGN KEY ("intake_id") REFERENCES "intakes"("id")
+);
+CREATE INDEX "idx_teams_intake_id" ON "teams" ("intake_id");
+CREATE UNIQUE INDEX "idx_teams_intake_code" ON "teams" ("intake_id", lower("code"));
+
+CREATE TABLE "members" (
+    "id" bigserial,
+    "team_id" bigint NOT NULL,
+    "student_id" text NOT NULL,
+    "name" text,
+    "email" text,
+    "role" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_teams_members" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_members_team_id" ON "members" ("team_id");
+CREATE UNIQUE INDEX "idx_members_team_student" ON "members" ("team_id", "student_id");
+
+CREATE TABLE "projects" (
+    "id" bigserial,
+    "team_id" bigint NOT NULL,
+    "title" text NOT NULL,
+    "abstract" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_teams_project" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_projects_team_id" ON "projects" ("team_id");
+
+ALTER TABLE "files"
+    ADD COLUMN "intake_ref_id" bigint,
+    ADD COLUMN "team_ref_id" bigint,
+    ADD CONSTRAINT "fk_files_intake" FOREIGN KEY ("intake_ref_id") REFERENCES "intakes"("id") ON DELETE SET NULL,
+    ADD CONSTRAINT "fk_files_team" FOREIGN KEY ("team_ref_id") REFERENCES "teams"("id") ON DELETE SET NULL;
+CREATE INDEX "idx_files_intake_ref_id" ON "files" ("intake_ref_id");
+CREATE INDEX "idx_files_team_ref_id" ON "files" ("team_ref_id");
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "idx_files_created_at_id";
+DROP INDEX IF EXISTS "idx_files_team_id";
+
+ALTER TABLE "plagiarism_reviews" DROP COLUMN IF EXISTS "uploaded_by";
+ALTER TABLE "upload_jobs" DROP COLUMN IF EXISTS "uploaded_by";
+ALTER TABLE "files" DROP COLUMN IF EXISTS "uploaded_by";
@@ -0,0 +1,8 @@
+ALTER TABLE "files" ADD COLUMN "uploaded_by" text;
+ALTER TABLE "upload_jobs" ADD COLUMN "uploaded_by" text;
+ALTER TABLE "plagiarism_reviews" ADD COLUMN "uploaded_by" text;
+
+CREATE INDEX "idx_files_uploaded_by" ON "files" ("uploaded_by");
+CREATE INDEX "idx_files_team_id" ON "files" ("team_id");
+-- Listings page through files by creation time, ties broken by ID
+CREATE INDEX "idx_files_created_at_id" ON "files" ("created_at", "id");
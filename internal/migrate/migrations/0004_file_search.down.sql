@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "file_search";
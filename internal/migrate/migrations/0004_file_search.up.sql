@@ -0,0 +1,29 @@
+-- Trigram matching catches misspelled and partial names that full-text
+-- search misses
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- One row per file with the text search looks at. The tsvector and the
+-- trigram label are generated from it, so indexing a file only writes text.
+CREATE TABLE "file_search" (
+    "file_id" bigint NOT NULL,
+    "original_name" text NOT NULL DEFAULT '',
+    "title" text NOT NULL DEFAULT '',
+    "team" text NOT NULL DEFAULT '',
+    "project_title" text NOT NULL DEFAULT '',
+    "placement" text NOT NULL DEFAULT '',
+    "body" text NOT NULL DEFAULT '',
+    "document" tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', "original_name" || ' ' || "title" || ' ' || "project_title"), 'A') ||
+        setweight(to_tsvector('english', "team"), 'B') ||
+        setweight(to_tsvector('english', "body"), 'C') ||
+        setweight(to_tsvector('english', "placement"), 'D')
+    ) STORED,
+    "label" text GENERATED ALWAYS AS (
+        "original_name" || ' ' || "title" || ' ' || "team" || ' ' || "project_title"
+    ) STORED,
+    "indexed_at" timestamptz NOT NULL,
+    PRIMARY KEY ("file_id"),
+    CONSTRAINT "fk_files_search" FOREIGN KEY ("file_id") REFERENCES "files"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_file_search_document" ON "file_search" USING gin ("document");
+CREATE INDEX "idx_file_search_label" ON "file_search" USING gin ("label" gin_trgm_ops);
@@ -0,0 +1 @@
+ALTER TABLE "plagiarism_reviews" DROP COLUMN IF EXISTS "from_submission";
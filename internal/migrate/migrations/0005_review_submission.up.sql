@@ -0,0 +1,2 @@
+-- Files held from a batch submission cannot be accepted on their own
+ALTER TABLE "plagiarism_reviews" ADD COLUMN "from_submission" boolean NOT NULL DEFAULT false;
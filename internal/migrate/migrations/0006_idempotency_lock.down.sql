@@ -0,0 +1 @@
+ALTER TABLE "idempotency_records" DROP COLUMN IF EXISTS "locked_until";
@@ -0,0 +1,3 @@
+-- In-flight requests hold their key under a lock they renew, so a retry
+-- can take over the key of a request whose process died
+ALTER TABLE "idempotency_records" ADD COLUMN "locked_until" timestamptz;
@@ -0,0 +1 @@
+ALTER TABLE "file_documents" DROP COLUMN IF EXISTS "restricted";
@@ -0,0 +1,2 @@
+-- PDFs that open without a password but carry an owner password
+ALTER TABLE "file_documents" ADD COLUMN "restricted" boolean;
@@ -0,0 +1,4 @@
+ALTER TABLE "similarity_matrices" DROP COLUMN IF EXISTS "lease_expires_at";
+ALTER TABLE "similarity_matrices" DROP COLUMN IF EXISTS "worker_id";
+ALTER TABLE "upload_jobs" DROP COLUMN IF EXISTS "lease_expires_at";
+ALTER TABLE "upload_jobs" DROP COLUMN IF EXISTS "worker_id";
@@ -0,0 +1,6 @@
+-- Upload jobs and similarity matrices are leased by the instance running
+-- them; others only take over those whose lease has expired
+ALTER TABLE "upload_jobs" ADD COLUMN "worker_id" text;
+ALTER TABLE "upload_jobs" ADD COLUMN "lease_expires_at" timestamptz;
+ALTER TABLE "similarity_matrices" ADD COLUMN "worker_id" text;
+ALTER TABLE "similarity_matrices" ADD COLUMN "lease_expires_at" timestamptz;
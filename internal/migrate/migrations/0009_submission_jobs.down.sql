@@ -0,0 +1,3 @@
+ALTER TABLE "upload_jobs" DROP COLUMN IF EXISTS "submission_id";
+ALTER TABLE "upload_jobs" DROP COLUMN IF EXISTS "parts";
+ALTER TABLE "upload_jobs" DROP COLUMN IF EXISTS "milestone";
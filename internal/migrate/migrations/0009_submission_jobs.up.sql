@@ -0,0 +1,4 @@
+-- Batch submissions run as one upload job holding every part
+ALTER TABLE "upload_jobs" ADD COLUMN "milestone" text;
+ALTER TABLE "upload_jobs" ADD COLUMN "parts" text;
+ALTER TABLE "upload_jobs" ADD COLUMN "submission_id" bigint;
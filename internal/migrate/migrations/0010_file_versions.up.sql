@@ -0,0 +1,26 @@
+-- Files that were given a version another file of the same team and
+-- doc_type already had move after the latest version
+UPDATE "files" SET "version" = moved."version"
+FROM (
+    SELECT d."id", latest."version" + ROW_NUMBER() OVER (
+        PARTITION BY d."team_id", d."intake", d."doc_type" ORDER BY d."id"
+    ) AS "version"
+    FROM (
+        SELECT "id", "team_id", "intake", "doc_type", ROW_NUMBER() OVER (
+            PARTITION BY "team_id", "intake", "doc_type", "version" ORDER BY "id"
+        ) AS "n"
+        FROM "files"
+    ) d
+    JOIN (
+        SELECT "team_id", "intake", "doc_type", MAX("version") AS "version"
+        FROM "files"
+        GROUP BY "team_id", "intake", "doc_type"
+    ) latest
+        ON latest."team_id" IS NOT DISTINCT FROM d."team_id"
+        AND latest."intake" IS NOT DISTINCT FROM d."intake"
+        AND latest."doc_type" IS NOT DISTINCT FROM d."doc_type"
+    WHERE d."n" > 1
+) moved
+WHERE "files"."id" = moved."id";
+
+CREATE UNIQUE INDEX "idx_files_version" ON "files" ("team_id", "intake", "doc_type", "version");
@@ -0,0 +1 @@
+ALTER TABLE "plagiarism_reviews" DROP COLUMN IF EXISTS "expires_at";
@@ -0,0 +1,5 @@
+-- Reviews of batch submission files expire when not used. Those held
+-- already get the default two weeks from when they were held
+ALTER TABLE "plagiarism_reviews" ADD COLUMN "expires_at" timestamptz;
+UPDATE "plagiarism_reviews" SET "expires_at" = "created_at" + INTERVAL '14 days'
+WHERE "from_submission" AND "status" = 'held';
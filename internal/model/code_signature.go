@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// CodeSignature holds the winnowed fingerprints of the source files in an
+// archive, used to find code shared with other teams' archives.
+type CodeSignature struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	FileID    uint         `json:"file_id" gorm:"uniqueIndex;not null"`
+	Sources   []CodeSource `json:"sources,omitempty" gorm:"foreignKey:SignatureID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// CodeSource is one source file inside an archive.
+type CodeSource struct {
+	ID           uint              `json:"id" gorm:"primaryKey"`
+	SignatureID  uint              `json:"-" gorm:"index;not null"`
+	Path         string            `json:"path"`
+	Language     string            `json:"language"`
+	Lines        int               `json:"lines"`
+	Fingerprints []CodeFingerprint `json:"-" gorm:"foreignKey:SourceID;constraint:OnDelete:CASCADE"`
+}
+
+// CodeFingerprint is a selected k-gram hash of a source file and the lines
+// it covers. Files sharing a hash share the tokens behind it.
+type CodeFingerprint struct {
+	ID        uint  `gorm:"primaryKey"`
+	SourceID  uint  `gorm:"index;not null"`
+	Hash      int64 `gorm:"index;not null"`
+	StartLine int
+	EndLine   int
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// DocumentSignature is the MinHash signature of a file's extracted text,
+// used to find earlier submissions it shares text with.
+type DocumentSignature struct {
+	ID           uint             `json:"id" gorm:"primaryKey"`
+	FileID       uint             `json:"file_id" gorm:"uniqueIndex;not null"`
+	ShingleCount int              `json:"shingle_count"`
+	MinHash      []byte           `json:"-"`
+	Bands        []SimilarityBand `json:"-" gorm:"foreignKey:SignatureID;constraint:OnDelete:CASCADE"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// SimilarityBand is one hashed band of a signature. Documents sharing a band
+// key are candidates for comparison.
+type SimilarityBand struct {
+	ID          uint  `gorm:"primaryKey"`
+	SignatureID uint  `gorm:"index;not null"`
+	Key         int64 `gorm:"index;not null"`
+}
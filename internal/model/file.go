@@ -2,14 +2,66 @@ package model
 
 import "time"
 
+const (
+	IntegrityUnverified = "unverified"
+	IntegrityOK         = "ok"
+	IntegrityCorrupt    = "corrupt"
+	IntegrityMissing    = "missing"
+)
+
+const (
+	PlagiarismChecked = "checked"
+	PlagiarismPending = "pending"
+	// PlagiarismProviderError marks pending files every provider refused to
+	// check, so they are not sent again
+	PlagiarismProviderError = "provider_error"
+)
+
 type File struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
 	OriginalName string    `json:"original_name"`
 	StorageKey   string    `json:"storage_key"`
 	DownloadURL  string    `json:"download_url"`
 	Size         int64     `json:"size"`
-	TeamID       string    `json:"team_id"`
+	TeamID       string    `json:"team_id" gorm:"index;uniqueIndex:idx_files_version,priority:1"`
+	Intake       string    `json:"intake" gorm:"index;uniqueIndex:idx_files_version,priority:2"`
+	AcademicYear string    `json:"academic_year"`
+	Session      string    `json:"session,omitempty"`
+	UploadedBy   string    `json:"uploaded_by,omitempty" gorm:"index"`
 	FileType     string    `json:"file_type"`
+	DocType      string    `json:"doc_type" gorm:"uniqueIndex:idx_files_version,priority:3"`
+	Version      int       `json:"version" gorm:"default:1;uniqueIndex:idx_files_version,priority:4"`
+	SubmissionID *uint     `json:"submission_id,omitempty" gorm:"index"`
 	ContentType  string    `json:"content_type"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	// IntakeRefID and TeamRefID point at the intake and team records when
+	// they exist; Intake and TeamID keep the names the file is stored under
+	IntakeRefID *uint `json:"intake_ref_id,omitempty" gorm:"index"`
+	TeamRefID   *uint `json:"team_ref_id,omitempty" gorm:"index"`
+
+	ScanStatus    string     `json:"scan_status"`
+	ScanEngine    string     `json:"scan_engine,omitempty"`
+	ScanSignature string     `json:"scan_signature,omitempty"`
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
+
+	// PlagiarismStatus is "checked", "pending" when the check was skipped
+	// because no provider was available, or empty when it does not apply
+	PlagiarismStatus    string     `json:"plagiarism_status,omitempty" gorm:"index"`
+	PlagiarismPercent   *float64   `json:"plagiarism_percent,omitempty"`
+	PlagiarismProvider  string     `json:"plagiarism_provider,omitempty"`
+	PlagiarismCheckedAt *time.Time `json:"plagiarism_checked_at,omitempty"`
+
+	ChecksumMD5     string     `json:"checksum_md5,omitempty"`
+	ChecksumSHA256  string     `json:"checksum_sha256,omitempty"`
+	IntegrityStatus string     `json:"integrity_status,omitempty" gorm:"index"`
+	VerifiedAt      *time.Time `json:"verified_at,omitempty" gorm:"index"`
+
+	Document    *FileDocument      `json:"document,omitempty" gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
+	Signature   *DocumentSignature `json:"-" gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
+	TopicVector *TopicVector       `json:"-" gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
+	// CodeSignature is set for archives containing source code
+	CodeSignature *CodeSignature `json:"-" gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
+
+	PlagiarismReports []PlagiarismReport `json:"-" gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// FileDocument holds what was parsed out of an uploaded PDF. Encrypted
+// documents need a password to open, so nothing else could be read from
+// them; Restricted ones open without one but carry an owner password that
+// limits e.g. printing or copying.
+type FileDocument struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	FileID       uint       `json:"file_id" gorm:"uniqueIndex;not null"`
+	PageCount    int        `json:"page_count"`
+	Title        string     `json:"title,omitempty"`
+	Author       string     `json:"author,omitempty"`
+	Producer     string     `json:"producer,omitempty"`
+	CreationDate *time.Time `json:"creation_date,omitempty"`
+	Encrypted    bool       `json:"encrypted"`
+	Restricted   bool       `json:"restricted"`
+	WordCount    int        `json:"word_count"`
+	Text         string     `json:"text,omitempty" gorm:"type:text"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	UnembeddedFonts []string `json:"unembedded_fonts,omitempty" gorm:"serializer:json"`
+	PDFAConformance string   `json:"pdfa_conformance,omitempty"`
+}
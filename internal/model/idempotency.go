@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	IdempotencyInFlight  = "in_flight"
+	IdempotencyCompleted = "completed"
+)
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key header so that retries can be answered without repeating
+// the side effects.
+type IdempotencyRecord struct {
+	ID              uint                `json:"id" gorm:"primaryKey"`
+	ClientID        string              `json:"client_id" gorm:"uniqueIndex:idx_idempotency_client_key;not null"`
+	Key             string              `json:"key" gorm:"uniqueIndex:idx_idempotency_client_key;not null"`
+	RequestHash     string              `json:"request_hash" gorm:"not null"`
+	Status          string              `json:"status" gorm:"not null"`
+	ResponseCode    int                 `json:"response_code"`
+	ResponseHeaders map[string][]string `json:"response_headers" gorm:"serializer:json"`
+	ResponseBody    []byte              `json:"response_body"`
+	ExpiresAt       time.Time           `json:"expires_at" gorm:"index"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+
+	// LockedUntil is when an in-flight request is given up for lost and a
+	// retry may take the key over. The request renews it while it runs.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
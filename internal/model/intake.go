@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Intake is a cohort of teams working in the same academic year and
+// session. Deadlines maps a document type to the time its uploads close.
+type Intake struct {
+	ID           uint                 `json:"id" gorm:"primaryKey"`
+	Name         string               `json:"name" gorm:"not null"`
+	AcademicYear string               `json:"academic_year"`
+	Session      string               `json:"session,omitempty"`
+	Deadlines    map[string]time.Time `json:"deadlines,omitempty" gorm:"serializer:json"`
+	Teams        []Team               `json:"teams,omitempty" gorm:"foreignKey:IntakeID"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
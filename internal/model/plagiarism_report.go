@@ -0,0 +1,84 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// CheckerProvider reports come from an external plagiarism provider,
+	// CheckerSimilarity reports from the local comparison with earlier
+	// submissions and CheckerCode reports from comparing the source code in
+	// archives. CheckerTopic reports compare the topic of a proposal with
+	// earlier projects.
+	CheckerProvider   = "provider"
+	CheckerSimilarity = "similarity"
+	CheckerCode       = "code"
+	CheckerTopic      = "topic"
+)
+
+// PlagiarismReport records one plagiarism check of an upload. FileID is
+// empty for uploads that were rejected and never stored; ReviewID links the
+// reports of a file held for a supervisor's review.
+type PlagiarismReport struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	FileID       *uint  `json:"file_id,omitempty" gorm:"index"`
+	ReviewID     *uint  `json:"review_id,omitempty" gorm:"index"`
+	OriginalName string `json:"original_name"`
+	TeamID       string `json:"team_id"`
+	Intake       string `json:"intake" gorm:"index:idx_plagiarism_intake_flagged"`
+	AcademicYear string `json:"academic_year"`
+
+	Checker  string `json:"checker"`
+	Provider string `json:"provider,omitempty"`
+	// Status is "checked", or "pending" when no provider was available
+	Status string `json:"status"`
+	// Score is the matching percentage; it is empty for pending checks
+	Score     *float64 `json:"score,omitempty"`
+	Threshold float64  `json:"threshold"`
+	Flagged   bool     `json:"flagged" gorm:"index:idx_plagiarism_intake_flagged"`
+
+	Sources    []PlagiarismSource `json:"sources" gorm:"serializer:json"`
+	RawPayload json.RawMessage    `json:"raw_payload,omitempty" gorm:"type:jsonb"`
+	CheckedAt  time.Time          `json:"checked_at"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// PlagiarismSource is a document the upload was found to match: an earlier
+// file for local checks, or whatever the provider reported.
+type PlagiarismSource struct {
+	FileID       *uint               `json:"file_id,omitempty"`
+	Title        string              `json:"title,omitempty"`
+	URL          string              `json:"url,omitempty"`
+	TeamID       string              `json:"team_id,omitempty"`
+	Intake       string              `json:"intake,omitempty"`
+	AcademicYear string              `json:"academic_year,omitempty"`
+	Percent      float64             `json:"percent"`
+	Passages     []PlagiarismPassage `json:"passages,omitempty"`
+	Files        []CodeFileMatch     `json:"files,omitempty"`
+}
+
+type PlagiarismPassage struct {
+	Text    string  `json:"text"`
+	Words   int     `json:"words"`
+	Percent float64 `json:"percent"`
+}
+
+// CodeFileMatch is a source file of the upload that shares code with a file
+// of the matched archive.
+type CodeFileMatch struct {
+	Path        string `json:"path"`
+	MatchedPath string `json:"matched_path"`
+	// Percent is the share of the file's fingerprints found in MatchedPath
+	Percent float64         `json:"percent"`
+	Lines   []CodeLineRange `json:"lines"`
+}
+
+// CodeLineRange pairs lines of the upload's file with the matching lines
+// of the other file.
+type CodeLineRange struct {
+	Start        int `json:"start"`
+	End          int `json:"end"`
+	MatchedStart int `json:"matched_start"`
+	MatchedEnd   int `json:"matched_end"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import "time"
+
+const (
+	ReviewHeld       = "held"
+	ReviewOverridden = "overridden"
+	// ReviewCleared is a batch submission file a supervisor accepted; the
+	// file is stored when the team submits the batch again
+	ReviewCleared = "cleared"
+	// ReviewExpired is a batch submission review that was not used in time
+	ReviewExpired = "expired"
+)
+
+// PlagiarismOverridden is the plagiarism status of files a supervisor
+// accepted despite a failed check.
+const PlagiarismOverridden = "overridden"
+
+// PlagiarismReview is an upload that failed its plagiarism check. The file is
+// kept in quarantine until a supervisor overrides the check, which stores it
+// like any accepted upload. A file of a batch submission cannot be stored on
+// its own: the override clears it for the next submission of the batch
+// instead, and the review expires if that does not come.
+type PlagiarismReview struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	Status         string `json:"status" gorm:"index"`
+	OriginalName   string `json:"original_name"`
+	TeamID         string `json:"team_id"`
+	Intake         string `json:"intake" gorm:"index"`
+	AcademicYear   string `json:"academic_year"`
+	Session        string `json:"session,omitempty"`
+	UploadedBy     string `json:"uploaded_by,omitempty"`
+	DocType        string `json:"doc_type"`
+	ContentType    string `json:"content_type"`
+	Size           int64  `json:"size"`
+	ChecksumMD5    string `json:"checksum_md5,omitempty"`
+	ChecksumSHA256 string `json:"checksum_sha256,omitempty"`
+	QuarantinePath string `json:"-"`
+	// FromSubmission marks files of a batch submission, which is stored
+	// whole or not at all; they cannot be accepted on their own
+	FromSubmission bool `json:"from_submission"`
+	// ExpiresAt is set on batch submission reviews, which are closed and
+	// their quarantined copy discarded if not used by then
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// FileID is the stored file once the check was overridden
+	FileID    *uint     `json:"file_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Reports   []PlagiarismReport   `json:"reports,omitempty" gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE"`
+	Overrides []PlagiarismOverride `json:"overrides,omitempty" gorm:"foreignKey:ReviewID"`
+}
+
+// PlagiarismOverride is the audit record of a supervisor accepting a file
+// that failed a plagiarism check. Records are only ever added.
+type PlagiarismOverride struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	ReviewID *uint  `json:"review_id,omitempty" gorm:"index"`
+	FileID   *uint  `json:"file_id,omitempty" gorm:"index"`
+	TeamID   string `json:"team_id"`
+	Intake   string `json:"intake" gorm:"index"`
+
+	Supervisor    string `json:"supervisor"`
+	Justification string `json:"justification"`
+	// Score and Threshold are those of the check that was overridden
+	Score     *float64  `json:"score,omitempty"`
+	Threshold float64   `json:"threshold"`
+	CreatedAt time.Time `json:"created_at"`
+}
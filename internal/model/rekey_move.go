@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Re-keying moves go through these states in order. A move that is
+// interrupted resumes from the state it was left in.
+const (
+	RekeyPlanned  = "planned"  // new key chosen
+	RekeyCopied   = "copied"   // object copied to the new key
+	RekeySwitched = "switched" // file record points at the new key
+	RekeyDone     = "done"     // old object removed, or kept on request
+	RekeySkipped  = "skipped"  // source object or file record went away
+)
+
+// RekeyMove records moving one file's object to the key given by a new
+// storage layout.
+type RekeyMove struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Layout    string    `json:"layout" gorm:"uniqueIndex:idx_rekey_layout_file"`
+	FileID    uint      `json:"file_id" gorm:"uniqueIndex:idx_rekey_layout_file"`
+	OldKey    string    `json:"old_key"`
+	NewKey    string    `json:"new_key"`
+	Status    string    `json:"status" gorm:"index"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// SimilarityMatrix is a batch comparison of the latest documents of every
+// team in an intake. It moves through the same statuses as upload jobs.
+type SimilarityMatrix struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	Intake string `json:"intake" gorm:"index"`
+	// DocTypes is the comma separated list of document types compared
+	DocTypes string  `json:"doc_types"`
+	Cutoff   float64 `json:"cutoff"`
+	Status   string  `json:"status" gorm:"index"`
+	Error    string  `json:"error,omitempty"`
+
+	Result      *MatrixResult `json:"result,omitempty" gorm:"serializer:json"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+
+	// Matrices are leased by the instance computing them, like upload jobs
+	WorkerID       string     `json:"-"`
+	LeaseExpiresAt *time.Time `json:"-"`
+}
+
+type MatrixResult struct {
+	Teams     []string         `json:"teams"`
+	Documents []MatrixDocument `json:"documents"`
+	// Pairs holds every pair of teams sharing anything, most similar first
+	Pairs []MatrixPair `json:"pairs"`
+	// Clusters groups teams linked by pairs at or above the cutoff
+	Clusters []MatrixCluster `json:"clusters"`
+}
+
+// MatrixDocument is a file that took part in the comparison. Basis is
+// "text" for documents compared by their extracted text and "code" for
+// archives compared by their source fingerprints.
+type MatrixDocument struct {
+	FileID       uint   `json:"file_id"`
+	TeamID       string `json:"team_id"`
+	OriginalName string `json:"original_name"`
+	DocType      string `json:"doc_type"`
+	Basis        string `json:"basis"`
+}
+
+// MatrixPair is the highest similarity between documents of two teams.
+type MatrixPair struct {
+	TeamA      string  `json:"team_a"`
+	TeamB      string  `json:"team_b"`
+	Similarity float64 `json:"similarity"`
+	Basis      string  `json:"basis"`
+	DocType    string  `json:"doc_type"`
+	FileA      uint    `json:"file_a"`
+	FileB      uint    `json:"file_b"`
+}
+
+type MatrixCluster struct {
+	Teams         []string `json:"teams"`
+	MaxSimilarity float64  `json:"max_similarity"`
+}
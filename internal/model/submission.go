@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Submission groups the files a team hands in together for one milestone,
+// e.g. a report, its slides and the code archive.
+type Submission struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	TeamID       string    `json:"team_id" gorm:"index"`
+	Intake       string    `json:"intake"`
+	AcademicYear string    `json:"academic_year"`
+	Session      string    `json:"session,omitempty"`
+	Milestone    string    `json:"milestone"`
+	Files        []File    `json:"files,omitempty" gorm:"foreignKey:SubmissionID"`
+	CreatedAt    time.Time `json:"created_at"`
+}
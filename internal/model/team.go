@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+const (
+	MemberLead    = "lead"
+	MemberRegular = "member"
+)
+
+// Team is a group of students in an intake working on one project. Code is
+// the team_id its files are uploaded with and stored under.
+type Team struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	IntakeID   uint      `json:"intake_id" gorm:"not null;index"`
+	Code       string    `json:"code" gorm:"not null"`
+	Name       string    `json:"name,omitempty"`
+	Supervisor string    `json:"supervisor,omitempty"`
+	Project    *Project  `json:"project,omitempty" gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE"`
+	Members    []Member  `json:"members,omitempty" gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Member is a student on a team.
+type Member struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TeamID    uint      `json:"team_id" gorm:"not null;index"`
+	StudentID string    `json:"student_id" gorm:"not null"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Project is what a team is building.
+type Project struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TeamID    uint      `json:"team_id" gorm:"not null;uniqueIndex"`
+	Title     string    `json:"title" gorm:"not null"`
+	Abstract  string    `json:"abstract,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// TopicVector holds the term counts of a document's extracted text, used to
+// find earlier projects on the same topic.
+type TopicVector struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	FileID    uint           `json:"file_id" gorm:"uniqueIndex;not null"`
+	Terms     map[string]int `json:"-" gorm:"serializer:json"`
+	CreatedAt time.Time      `json:"created_at"`
+}
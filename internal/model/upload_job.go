@@ -0,0 +1,119 @@
+package model
+
+import "time"
+
+const (
+	JobQueued     = "queued"
+	JobProcessing = "processing"
+	JobSucceeded  = "succeeded"
+	JobFailed     = "failed"
+)
+
+const (
+	StagePending = "pending"
+	StageRunning = "running"
+	StageDone    = "done"
+	StageFailed  = "failed"
+	StageSkipped = "skipped"
+)
+
+type JobStage struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobPart is one file of a batch submission job, staged until the workers
+// have validated every part. Parts are kept with the job, not shown with it.
+type JobPart struct {
+	Field        string `json:"field"`
+	OriginalName string `json:"original_name"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"content_type"`
+	TempPath     string `json:"temp_path"`
+
+	ChecksumMD5    string `json:"checksum_md5"`
+	ChecksumSHA256 string `json:"checksum_sha256"`
+}
+
+// UploadJob tracks an accepted upload while the workers scan, validate and
+// store it. A batch submission is a single job with one part per file,
+// stored together once every part has passed.
+type UploadJob struct {
+	ID          string                 `json:"id" gorm:"primaryKey;size:32"`
+	Status      string                 `json:"status" gorm:"index"`
+	Stage       string                 `json:"stage"`
+	Stages      []JobStage             `json:"stages" gorm:"serializer:json"`
+	ResultCode  int                    `json:"result_code,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty" gorm:"serializer:json"`
+	FileID      *uint                  `json:"file_id,omitempty"`
+	File        *File                  `json:"file,omitempty" gorm:"foreignKey:FileID"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+
+	OriginalName string `json:"original_name"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"content_type"`
+	TeamID       string `json:"team_id"`
+	Intake       string `json:"intake"`
+	DocType      string `json:"doc_type"`
+	AcademicYear string `json:"academic_year"`
+	Session      string `json:"session,omitempty"`
+	UploadedBy   string `json:"uploaded_by,omitempty"`
+	TempPath     string `json:"-"`
+
+	// Digests taken while the upload was received
+	ChecksumMD5    string `json:"-"`
+	ChecksumSHA256 string `json:"-"`
+
+	// Set for batch submissions
+	Milestone    string    `json:"milestone,omitempty"`
+	Parts        []JobPart `json:"-" gorm:"serializer:json"`
+	SubmissionID *uint     `json:"submission_id,omitempty"`
+
+	// WorkerID is the instance running the job, which holds it until
+	// LeaseExpiresAt and renews the lease while it is up
+	WorkerID       string     `json:"-"`
+	LeaseExpiresAt *time.Time `json:"-"`
+}
+
+// StartStage finishes the running stage and marks name as running.
+func (j *UploadJob) StartStage(name string) {
+	now := time.Now()
+	for i := range j.Stages {
+		stage := &j.Stages[i]
+		if stage.Status == StageRunning {
+			stage.Status = StageDone
+			stage.FinishedAt = &now
+		}
+		if stage.Name == name {
+			stage.Status = StageRunning
+			stage.StartedAt = &now
+		}
+	}
+	j.Stage = name
+}
+
+// Finish closes the running stage with status and skips the stages that
+// never ran.
+func (j *UploadJob) Finish(status string) {
+	now := time.Now()
+	for i := range j.Stages {
+		stage := &j.Stages[i]
+		switch stage.Status {
+		case StageRunning:
+			stage.FinishedAt = &now
+			if status == JobSucceeded {
+				stage.Status = StageDone
+			} else {
+				stage.Status = StageFailed
+			}
+		case StagePending:
+			stage.Status = StageSkipped
+		}
+	}
+	j.Status = status
+	j.CompletedAt = &now
+}
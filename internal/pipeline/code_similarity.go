@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sohan-reza/capstone-core/internal/codesim"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+const StageCodeSimilarity = "code_similarity"
+
+type codeSimilarityStage struct {
+	engine     *service.CodeSimilarityEngine
+	thresholds *service.PlagiarismThresholds
+	review     reviewHold
+}
+
+// NewCodeSimilarityStage returns a stage that compares the source code in an
+// archive with the archives of other teams. Archives are rejected when a
+// match reaches the threshold for their intake and document type, and kept
+// in quarantine for a supervisor to review; a threshold of 0 only reports
+// the matches.
+func NewCodeSimilarityStage(engine *service.CodeSimilarityEngine, thresholds *service.PlagiarismThresholds, quarantine service.QuarantineStore, clearances Clearances) FileProcessor {
+	return &codeSimilarityStage{
+		engine:     engine,
+		thresholds: thresholds,
+		review:     reviewHold{quarantine: quarantine, clearances: clearances},
+	}
+}
+
+func (s *codeSimilarityStage) Name() string {
+	return StageCodeSimilarity
+}
+
+func (s *codeSimilarityStage) Process(ctx context.Context, f *File) (Result, error) {
+	report, signature, err := s.engine.Check(f.Path, f.TeamID, f.Intake, f.AcademicYear)
+	if errors.Is(err, codesim.ErrUnsupportedArchive) {
+		return Result{Status: StatusSkipped, Details: map[string]interface{}{
+			"code_similarity_skipped": "archive format cannot be read",
+		}}, nil
+	}
+	if errors.Is(err, codesim.ErrInvalidArchive) {
+		return Result{}, Reject(http.StatusBadRequest, "Archive could not be read", map[string]interface{}{
+			"message": err.Error(),
+			"type":    "archive",
+		})
+	}
+	if err != nil {
+		return Result{}, Unavailable("Code similarity check unavailable", err)
+	}
+	if report == nil {
+		return Result{Status: StatusSkipped}, nil
+	}
+	f.CodeSignature = signature
+	f.CodeSimilarity = report
+	threshold := s.thresholds.For(f.Intake, f.DocType)
+	report.Threshold = threshold
+	report.Flagged = threshold > 0 && report.Percent >= threshold
+
+	if report.Flagged && s.review.cleared(f) {
+		return Result{Details: map[string]interface{}{
+			"code_similarity_status":  model.PlagiarismOverridden,
+			"code_similarity_percent": report.Percent,
+			"code_similarity_sources": report.Sources,
+			"code_similarity_matches": report.Matches,
+			"review_id":               f.Clearance.ID,
+		}}, nil
+	}
+	if report.Flagged {
+		action := s.review.hold(f, fmt.Sprintf("code similarity: %g%% match, %g%% accepted", report.Percent, threshold))
+		return Result{}, Reject(http.StatusBadRequest, "Code similarity check failed", map[string]interface{}{
+			"message":   fmt.Sprintf("%g%% or more of the source code matches other teams' archives", threshold),
+			"type":      "code_similarity",
+			"detected":  report.Percent,
+			"threshold": threshold,
+			"matches":   report.Matches,
+			"action":    action,
+		})
+	}
+
+	return Result{Details: map[string]interface{}{
+		"code_similarity_percent": report.Percent,
+		"code_similarity_sources": report.Sources,
+		"code_similarity_matches": report.Matches,
+	}}, nil
+}
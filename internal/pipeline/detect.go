@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sohan-reza/capstone-core/internal/utils"
+)
+
+// sniffLength covers the tar header magic at offset 257.
+const sniffLength = 1024
+
+// pdfHeaderSlack is how far into a file its %PDF header may start, for the
+// few bytes of junk some writers put before it. Further in, the header is
+// more likely a PDF stored inside an archive.
+const pdfHeaderSlack = 8
+
+type typeDetector struct {
+	allowed map[string]bool
+}
+
+// NewTypeDetector returns the type_detection stage. Files are typed by
+// extension, which must be in allowedExtensions, and rejected when their
+// content is recognisably a different kind of file.
+func NewTypeDetector(allowedExtensions []string) FileProcessor {
+	allowed := make(map[string]bool, len(allowedExtensions))
+	for _, ext := range allowedExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		allowed[ext] = true
+	}
+	return &typeDetector{allowed: allowed}
+}
+
+func (d *typeDetector) Name() string {
+	return StageTypeDetection
+}
+
+func (d *typeDetector) Process(ctx context.Context, f *File) (Result, error) {
+	ext := strings.ToLower(filepath.Ext(f.OriginalName))
+	f.Type = utils.DetectFileTypeByName(f.OriginalName)
+
+	if f.Type == utils.Unknown || (len(d.allowed) > 0 && !d.allowed[ext]) {
+		return Result{}, Reject(http.StatusUnsupportedMediaType, "Unsupported file type", map[string]interface{}{
+			"message":   fmt.Sprintf("%q files are not accepted", ext),
+			"type":      "file_type",
+			"extension": ext,
+		})
+	}
+
+	head, err := readHead(f.Path)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Unrecognised content is left to the later stages; only a file that is
+	// clearly something else is rejected here
+	if sniffed := sniffFileType(head); sniffed != utils.Unknown && sniffed != f.Type {
+		return Result{}, Reject(http.StatusUnsupportedMediaType, "File content does not match its extension", map[string]interface{}{
+			"message":   fmt.Sprintf("The file is named %s but contains %s data", ext, sniffed),
+			"type":      "file_type",
+			"extension": ext,
+			"detected":  sniffed,
+		})
+	}
+
+	return Result{}, nil
+}
+
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+var archiveSignatures = [][]byte{
+	[]byte("PK\x03\x04"),
+	[]byte("PK\x05\x06"),
+	[]byte("\x1f\x8b"),
+	[]byte("Rar!\x1a\x07"),
+	[]byte("7z\xbc\xaf\x27\x1c"),
+}
+
+// sniffFileType checks archive signatures first, as archives may hold PDFs
+// stored uncompressed near their start.
+func sniffFileType(head []byte) utils.FileType {
+	for _, sig := range archiveSignatures {
+		if bytes.HasPrefix(head, sig) {
+			return utils.Archive
+		}
+	}
+	if len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")) {
+		return utils.Archive
+	}
+	if i := bytes.Index(head, []byte("%PDF-")); i >= 0 && i <= pdfHeaderSlack {
+		return utils.PDF
+	}
+	return utils.Unknown
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+const (
+	StagePDFMetadata = "pdf_metadata"
+	StagePDFPolicy   = "pdf_policy"
+)
+
+type pdfMetadataStage struct {
+	extractor service.PDFExtractor
+}
+
+// NewPDFMetadataStage returns a stage that extracts the document metadata and
+// text stored alongside a PDF. Extraction failures are not fatal; the policy
+// stage decides whether an unparsable PDF is acceptable.
+func NewPDFMetadataStage(extractor service.PDFExtractor) FileProcessor {
+	return &pdfMetadataStage{extractor: extractor}
+}
+
+func (s *pdfMetadataStage) Name() string {
+	return StagePDFMetadata
+}
+
+func (s *pdfMetadataStage) Process(ctx context.Context, f *File) (Result, error) {
+	doc, err := s.extractor.Extract(f.Path)
+	if err != nil {
+		log.Printf("Warning: failed to extract PDF metadata from %s: %v", f.OriginalName, err)
+		return Result{Status: StatusSkipped}, nil
+	}
+	f.Document = doc
+
+	return Result{Details: map[string]interface{}{
+		"page_count":       doc.PageCount,
+		"word_count":       doc.WordCount,
+		"title":            doc.Title,
+		"encrypted":        doc.Encrypted,
+		"restricted":       doc.Restricted,
+		"pdfa_conformance": doc.PDFAConformance,
+	}}, nil
+}
+
+type pdfPolicyStage struct {
+	policies *service.PDFPolicySet
+}
+
+// NewPDFPolicyStage returns a stage that checks the extracted document
+// against the policy for its doc_type. It must run after pdf_metadata.
+func NewPDFPolicyStage(policies *service.PDFPolicySet) FileProcessor {
+	return &pdfPolicyStage{policies: policies}
+}
+
+func (s *pdfPolicyStage) Name() string {
+	return StagePDFPolicy
+}
+
+func (s *pdfPolicyStage) Process(ctx context.Context, f *File) (Result, error) {
+	violations, warnings := s.policies.For(f.DocType).Validate(f.Document)
+	if len(violations) > 0 {
+		return Result{}, Reject(http.StatusBadRequest, "PDF policy check failed", map[string]interface{}{
+			"message":    fmt.Sprintf("The PDF does not meet the requirements for %s submissions", docTypeLabel(f.DocType)),
+			"type":       "pdf_policy",
+			"doc_type":   f.DocType,
+			"violations": violations,
+		})
+	}
+
+	if len(warnings) == 0 {
+		return Result{}, nil
+	}
+	return Result{Details: map[string]interface{}{
+		"policy_warnings": warnings,
+	}}, nil
+}
+
+func docTypeLabel(docType string) string {
+	if docType == "" {
+		return "untyped"
+	}
+	return docType
+}
@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/service"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+)
+
+// StageTypeDetection always runs first; it decides which per-type stage list
+// the rest of the file goes through.
+const StageTypeDetection = "type_detection"
+
+const (
+	StatusPassed  = "passed"
+	StatusSkipped = "skipped"
+)
+
+// File is an upload moving through the pipeline. Stages read the staged copy
+// at Path and record what they learn on the File for later stages and for
+// storage.
+type File struct {
+	OriginalName string
+	Path         string
+	Size         int64
+	ContentType  string
+	DocType      string
+	TeamID       string
+	Intake       string
+	AcademicYear string
+	Type         utils.FileType
+	Digest       utils.FileDigest
+
+	Scan       *service.ScanResult
+	Document   *model.FileDocument
+	Plagiarism *service.PlagiarismResult
+	Similarity *service.SimilarityReport
+	Signature  *model.DocumentSignature
+
+	Topic          *service.TopicReport
+	TopicVector    *model.TopicVector
+	CodeSimilarity *service.CodeSimilarityReport
+	CodeSignature  *model.CodeSignature
+
+	// ReviewPath is where a file that failed a check was quarantined to
+	// await a supervisor's decision
+	ReviewPath string
+	// Clearance is the review of an earlier batch submission whose override
+	// lets the file through the checks it fails
+	Clearance *model.PlagiarismReview
+
+	// Metadata collects the details reported by each stage
+	Metadata map[string]interface{}
+	Results  []Result
+}
+
+// Result is what a stage reports when it lets a file through.
+type Result struct {
+	Stage    string                 `json:"stage"`
+	Status   string                 `json:"status"`
+	Duration time.Duration          `json:"duration"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error is returned when a stage rejects a file or cannot run. Code is the
+// HTTP status the rejection maps to.
+type Error struct {
+	Stage   string
+	Code    int
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Stage, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Stage, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Reject reports a file that failed a check.
+func Reject(code int, message string, details map[string]interface{}) *Error {
+	return &Error{Code: code, Message: message, Details: details}
+}
+
+// Unavailable reports a stage that could not reach the service it depends on.
+func Unavailable(message string, err error) *Error {
+	return &Error{Code: http.StatusServiceUnavailable, Message: message, Err: err}
+}
+
+// FileProcessor is one stage of the pipeline. Process returns an *Error to
+// reject the file; any other error is treated as an internal failure.
+type FileProcessor interface {
+	Name() string
+	Process(ctx context.Context, f *File) (Result, error)
+}
+
+// Registry holds the stages available to pipelines by name.
+type Registry struct {
+	stages map[string]FileProcessor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{stages: make(map[string]FileProcessor)}
+}
+
+// Register adds a stage. It panics if a stage with the same name is already
+// registered.
+func (r *Registry) Register(p FileProcessor) {
+	if _, exists := r.stages[p.Name()]; exists {
+		panic("pipeline: stage " + p.Name() + " registered twice")
+	}
+	r.stages[p.Name()] = p
+}
+
+// Pipeline runs the stages configured for each file type.
+type Pipeline struct {
+	detect FileProcessor
+	stages map[utils.FileType][]FileProcessor
+}
+
+// New builds a pipeline from the stage names configured per file type. The
+// registry must contain a type_detection stage.
+func New(registry *Registry, stagesByType map[utils.FileType][]string) (*Pipeline, error) {
+	detect, ok := registry.stages[StageTypeDetection]
+	if !ok {
+		return nil, errors.New("pipeline: no type_detection stage registered")
+	}
+
+	p := &Pipeline{
+		detect: detect,
+		stages: make(map[utils.FileType][]FileProcessor),
+	}
+	for fileType, names := range stagesByType {
+		stages := make([]FileProcessor, 0, len(names))
+		for _, name := range names {
+			stage, ok := registry.stages[name]
+			if !ok {
+				return nil, fmt.Errorf("pipeline: unknown stage %q configured for %s files", name, fileType)
+			}
+			if name == StageTypeDetection {
+				continue
+			}
+			stages = append(stages, stage)
+		}
+		p.stages[fileType] = stages
+	}
+
+	return p, nil
+}
+
+// ParseStages splits a comma separated list of stage names.
+func ParseStages(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Stages returns the names of the stages a file of the given type goes
+// through, in order.
+func (p *Pipeline) Stages(fileType utils.FileType) []string {
+	names := []string{p.detect.Name()}
+	for _, stage := range p.stages[fileType] {
+		names = append(names, stage.Name())
+	}
+	return names
+}
+
+// Run passes f through type detection and then through the stages
+// configured for its type, reporting each stage it enters to progress. The
+// returned error, if any, is an *Error.
+func (p *Pipeline) Run(ctx context.Context, f *File, progress func(stage string)) error {
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	if err := p.runStage(ctx, p.detect, f, progress); err != nil {
+		return err
+	}
+
+	stages, ok := p.stages[f.Type]
+	if !ok {
+		return &Error{
+			Stage:   StageTypeDetection,
+			Code:    http.StatusUnsupportedMediaType,
+			Message: "Unsupported file type",
+			Details: map[string]interface{}{
+				"message": fmt.Sprintf("No processing pipeline is configured for %s files", f.Type),
+				"type":    "file_type",
+			},
+		}
+	}
+
+	for _, stage := range stages {
+		if err := ctx.Err(); err != nil {
+			return &Error{Stage: stage.Name(), Code: http.StatusServiceUnavailable, Message: "Processing was cancelled", Err: err}
+		}
+		if err := p.runStage(ctx, stage, f, progress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Pipeline) runStage(ctx context.Context, stage FileProcessor, f *File, progress func(stage string)) error {
+	progress(stage.Name())
+
+	start := time.Now()
+	result, err := stage.Process(ctx, f)
+	if err != nil {
+		var stageErr *Error
+		if !errors.As(err, &stageErr) {
+			stageErr = &Error{Code: http.StatusInternalServerError, Message: "File processing failed", Err: err}
+		}
+		stageErr.Stage = stage.Name()
+		return stageErr
+	}
+
+	result.Stage = stage.Name()
+	result.Duration = time.Since(start)
+	if result.Status == "" {
+		result.Status = StatusPassed
+	}
+	f.Results = append(f.Results, result)
+
+	if len(result.Details) > 0 {
+		if f.Metadata == nil {
+			f.Metadata = make(map[string]interface{})
+		}
+		for k, v := range result.Details {
+			f.Metadata[k] = v
+		}
+	}
+
+	return nil
+}
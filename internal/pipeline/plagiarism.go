@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+const StagePlagiarism = "plagiarism"
+
+// PlagiarismSkip is the unavailable action that stores files unchecked and
+// flags them as pending instead of rejecting the upload.
+const PlagiarismSkip = "skip"
+
+type plagiarismStage struct {
+	checker           service.PlagiarismChecker
+	thresholds        *service.PlagiarismThresholds
+	review            reviewHold
+	unavailableAction string
+}
+
+// NewPlagiarismStage returns a stage that submits the file to the plagiarism
+// checker and rejects it when the match percentage reaches the threshold for
+// its intake and document type. Rejected files are moved to quarantine until
+// a supervisor overrides the check; files of a batch submission a supervisor
+// cleared are let through. When no provider can be reached the upload
+// fails, unless unavailableAction is "skip", in which case the file
+// continues flagged as pending.
+func NewPlagiarismStage(checker service.PlagiarismChecker, thresholds *service.PlagiarismThresholds, quarantine service.QuarantineStore, clearances Clearances, unavailableAction string) FileProcessor {
+	return &plagiarismStage{
+		checker:           checker,
+		thresholds:        thresholds,
+		review:            reviewHold{quarantine: quarantine, clearances: clearances},
+		unavailableAction: unavailableAction,
+	}
+}
+
+func (s *plagiarismStage) Name() string {
+	return StagePlagiarism
+}
+
+func (s *plagiarismStage) Process(ctx context.Context, f *File) (Result, error) {
+	result, err := s.checker.Check(ctx, service.PlagiarismRequest{
+		FilePath:    f.Path,
+		FileName:    f.OriginalName,
+		ContentType: f.ContentType,
+		DocType:     f.DocType,
+		SHA256:      f.Digest.SHA256Hex(),
+	})
+	threshold := s.thresholds.For(f.Intake, f.DocType)
+	if err != nil {
+		if s.unavailableAction != PlagiarismSkip || ctx.Err() != nil {
+			return Result{}, Unavailable("Plagiarism service unavailable", err)
+		}
+
+		log.Printf("Warning: plagiarism check skipped for %s, flagged for a later check: %v", f.OriginalName, err)
+		f.Plagiarism = &service.PlagiarismResult{
+			Status:    service.PlagiarismPending,
+			CheckedAt: time.Now(),
+		}
+		f.Plagiarism.ApplyThreshold(threshold)
+		return Result{Status: StatusSkipped, Details: map[string]interface{}{
+			"plagiarism_checked": false,
+			"plagiarism_status":  service.PlagiarismPending,
+		}}, nil
+	}
+
+	f.Plagiarism = result
+	result.ApplyThreshold(threshold)
+	if result.Flagged && s.review.cleared(f) {
+		return Result{Details: map[string]interface{}{
+			"plagiarism_checked":  true,
+			"plagiarism_status":   model.PlagiarismOverridden,
+			"plagiarism_percent":  result.MatchPercent,
+			"plagiarism_provider": result.Provider,
+			"review_id":           f.Clearance.ID,
+		}}, nil
+	}
+	if result.Flagged {
+		return Result{}, s.reject(f, result)
+	}
+
+	return Result{Details: map[string]interface{}{
+		"plagiarism_checked":  true,
+		"plagiarism_status":   service.PlagiarismChecked,
+		"plagiarism_percent":  result.MatchPercent,
+		"plagiarism_provider": result.Provider,
+	}}, nil
+}
+
+// reject holds the file for review and returns the rejection.
+func (s *plagiarismStage) reject(f *File, result *service.PlagiarismResult) error {
+	action := s.review.hold(f, fmt.Sprintf("plagiarism: %g%% match, %g%% accepted", result.MatchPercent, result.Threshold))
+
+	return Reject(http.StatusBadRequest, "Plagiarism check failed", map[string]interface{}{
+		"message":   fmt.Sprintf("%g%% or less plagiarism is accepted", result.Threshold),
+		"type":      "plagiarism",
+		"detected":  result.MatchPercent,
+		"threshold": result.Threshold,
+		"provider":  result.Provider,
+		"action":    action,
+	})
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"errors"
+	"log"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// Clearances finds the overrides supervisors gave files that failed a check
+// as part of a batch submission that was not stored.
+type Clearances interface {
+	FindClearance(intake string, teamID string, docType string, sha256 string) (*model.PlagiarismReview, error)
+}
+
+// reviewHold holds files that fail a plagiarism-type check in quarantine for
+// a supervisor to review, and lets through files a supervisor cleared.
+type reviewHold struct {
+	quarantine service.QuarantineStore
+	clearances Clearances
+}
+
+// cleared reports whether a supervisor cleared f for the checks it fails and
+// records the clearance on f.
+func (h reviewHold) cleared(f *File) bool {
+	if f.Clearance != nil {
+		return true
+	}
+	if h.clearances == nil {
+		return false
+	}
+	review, err := h.clearances.FindClearance(f.Intake, f.TeamID, f.DocType, f.Digest.SHA256Hex())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Warning: failed to look up a clearance for %s: %v", f.OriginalName, err)
+		return false
+	}
+	f.Clearance = review
+	return true
+}
+
+// hold quarantines f and returns the action to report: "held_for_review", or
+// "rejected" when the file could not be held.
+func (h reviewHold) hold(f *File, reason string) string {
+	quarantinedPath, err := h.quarantine.Quarantine(f.Path, f.OriginalName, reason)
+	if err != nil {
+		log.Printf("Warning: failed to hold %s for review: %v", f.OriginalName, err)
+		return "rejected"
+	}
+	f.ReviewPath = quarantinedPath
+	return "held_for_review"
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+const StageScan = "scan"
+
+// ScanSkip is the oversize action that stores files too large for clamd
+// unscanned instead of rejecting the upload.
+const ScanSkip = "skip"
+
+type scanStage struct {
+	scanner        service.Scanner
+	quarantine     service.QuarantineStore
+	infectedAction string
+	oversizeAction string
+}
+
+// NewScanStage returns a stage that runs the file through the antivirus
+// scanner. Infected files are rejected and, when infectedAction is
+// "quarantine", moved to the quarantine store. Files longer than the
+// scanner's stream limit are rejected, unless oversizeAction is "skip", in
+// which case they continue unscanned.
+func NewScanStage(scanner service.Scanner, quarantine service.QuarantineStore, infectedAction string, oversizeAction string) FileProcessor {
+	return &scanStage{
+		scanner:        scanner,
+		quarantine:     quarantine,
+		infectedAction: infectedAction,
+		oversizeAction: oversizeAction,
+	}
+}
+
+func (s *scanStage) Name() string {
+	return StageScan
+}
+
+func (s *scanStage) Process(ctx context.Context, f *File) (Result, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	f.Scan, err = s.scanner.Scan(ctx, file)
+	if err != nil {
+		return Result{}, Unavailable("Antivirus scanner unavailable", err)
+	}
+
+	switch f.Scan.Status {
+	case service.ScanInfected:
+		return Result{}, s.rejectInfected(f)
+	case service.ScanSkipped:
+		return Result{Status: StatusSkipped}, nil
+	case service.ScanTooLarge:
+		return s.tooLarge(f)
+	}
+
+	return Result{}, nil
+}
+
+// tooLarge handles a file the scanner stopped reading at its stream limit.
+func (s *scanStage) tooLarge(f *File) (Result, error) {
+	if s.oversizeAction != ScanSkip {
+		return Result{}, Reject(http.StatusRequestEntityTooLarge, "File too large to scan", map[string]interface{}{
+			"message": "The uploaded file is larger than the antivirus scanner accepts",
+			"type":    "scan_size",
+			"size":    f.Size,
+			"engine":  f.Scan.Engine,
+		})
+	}
+
+	log.Printf("Warning: %s (%d bytes) is larger than the scanner accepts, stored unscanned", f.OriginalName, f.Size)
+	return Result{Status: StatusSkipped, Details: map[string]interface{}{
+		"scan_status": service.ScanTooLarge,
+	}}, nil
+}
+
+func (s *scanStage) rejectInfected(f *File) error {
+	action := "rejected"
+	if s.infectedAction == "quarantine" {
+		quarantinedPath, err := s.quarantine.Quarantine(f.Path, f.OriginalName, "malware: "+f.Scan.Signature)
+		if err != nil {
+			log.Printf("Warning: failed to quarantine infected upload %s: %v", f.OriginalName, err)
+		} else {
+			action = "quarantined"
+			log.Printf("Quarantined infected upload %s (%s) at %s", f.OriginalName, f.Scan.Signature, quarantinedPath)
+		}
+	}
+
+	return Reject(http.StatusUnprocessableEntity, "Malware detected", map[string]interface{}{
+		"message":   "The uploaded file was flagged by the antivirus scanner",
+		"type":      "malware",
+		"signature": f.Scan.Signature,
+		"engine":    f.Scan.Engine,
+		"action":    action,
+	})
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+const StageSimilarity = "similarity"
+
+type similarityStage struct {
+	engine     *service.SimilarityEngine
+	thresholds *service.PlagiarismThresholds
+	review     reviewHold
+}
+
+// NewSimilarityStage returns a stage that compares the extracted text with
+// earlier submissions of other teams and years. It must run after
+// pdf_metadata. Files are rejected when a match reaches the threshold for
+// their intake and document type, and held for review like failed
+// plagiarism checks; a threshold of 0 only reports the matches.
+func NewSimilarityStage(engine *service.SimilarityEngine, thresholds *service.PlagiarismThresholds, quarantine service.QuarantineStore, clearances Clearances) FileProcessor {
+	return &similarityStage{
+		engine:     engine,
+		thresholds: thresholds,
+		review:     reviewHold{quarantine: quarantine, clearances: clearances},
+	}
+}
+
+func (s *similarityStage) Name() string {
+	return StageSimilarity
+}
+
+func (s *similarityStage) Process(ctx context.Context, f *File) (Result, error) {
+	if f.Document == nil || f.Document.Text == "" {
+		return Result{Status: StatusSkipped}, nil
+	}
+
+	report, signature, err := s.engine.Check(f.Document.Text, f.TeamID, f.Intake)
+	if err != nil {
+		return Result{}, Unavailable("Similarity check unavailable", err)
+	}
+	if report == nil {
+		return Result{Status: StatusSkipped}, nil
+	}
+	f.Signature = signature
+	f.Similarity = report
+	threshold := s.thresholds.For(f.Intake, f.DocType)
+	report.Threshold = threshold
+	report.Flagged = threshold > 0 && report.Percent >= threshold
+
+	if report.Flagged && s.review.cleared(f) {
+		return Result{Details: map[string]interface{}{
+			"similarity_status":  model.PlagiarismOverridden,
+			"similarity_percent": report.Percent,
+			"similarity_matches": report.Matches,
+			"review_id":          f.Clearance.ID,
+		}}, nil
+	}
+	if report.Flagged {
+		action := s.review.hold(f, fmt.Sprintf("similarity: %g%% match, %g%% accepted", report.Percent, threshold))
+		return Result{}, Reject(http.StatusBadRequest, "Similarity check failed", map[string]interface{}{
+			"message":   fmt.Sprintf("%.0f%% or more of the text matches earlier submissions", threshold),
+			"type":      "similarity",
+			"detected":  report.Percent,
+			"threshold": threshold,
+			"matches":   report.Matches,
+			"action":    action,
+		})
+	}
+
+	return Result{Details: map[string]interface{}{
+		"similarity_percent": report.Percent,
+		"similarity_matches": report.Matches,
+	}}, nil
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+const StageSizePolicy = "size_policy"
+
+type sizePolicy struct {
+	maxBytes int64
+}
+
+// NewSizePolicy returns a stage that rejects empty files and files larger
+// than maxBytes. A maxBytes of zero disables the upper limit.
+func NewSizePolicy(maxBytes int64) FileProcessor {
+	return &sizePolicy{maxBytes: maxBytes}
+}
+
+func (s *sizePolicy) Name() string {
+	return StageSizePolicy
+}
+
+func (s *sizePolicy) Process(ctx context.Context, f *File) (Result, error) {
+	// The staged copy is authoritative, the multipart header is client supplied
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	f.Size = info.Size()
+
+	if f.Size == 0 {
+		return Result{}, Reject(http.StatusBadRequest, "Empty file", map[string]interface{}{
+			"message": "The uploaded file is empty",
+			"type":    "size",
+		})
+	}
+
+	if s.maxBytes > 0 && f.Size > s.maxBytes {
+		return Result{}, Reject(http.StatusRequestEntityTooLarge, "File too large", map[string]interface{}{
+			"message": fmt.Sprintf("Files may be at most %d MB", s.maxBytes>>20),
+			"type":    "size",
+			"limit":   s.maxBytes,
+			"actual":  f.Size,
+		})
+	}
+
+	return Result{}, nil
+}
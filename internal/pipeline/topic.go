@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/service"
+	"github.com/sohan-reza/capstone-core/internal/topics"
+)
+
+const StageTopicUniqueness = "topic_uniqueness"
+
+type topicStage struct {
+	engine  *service.TopicEngine
+	checked []string
+	warn    float64
+	block   float64
+}
+
+// NewTopicStage returns a stage that compares the topic of documents of the
+// checked types, such as proposals, with earlier projects. It must run after
+// pdf_metadata. Files are rejected when a match reaches block and pass with a
+// warning when it reaches warn; a threshold of 0 is disabled. Files of the
+// engine's corpus types are indexed for later checks whether or not they are
+// checked themselves.
+func NewTopicStage(engine *service.TopicEngine, checked []string, warn float64, block float64) FileProcessor {
+	return &topicStage{engine: engine, checked: checked, warn: warn, block: block}
+}
+
+func (s *topicStage) Name() string {
+	return StageTopicUniqueness
+}
+
+func (s *topicStage) Process(ctx context.Context, f *File) (Result, error) {
+	check := slices.Contains(s.checked, f.DocType)
+	if f.Document == nil || f.Document.Text == "" || (!check && !s.engine.Indexes(f.DocType)) {
+		return Result{Status: StatusSkipped}, nil
+	}
+	terms := topics.Terms(f.Document.Text)
+	if len(terms) == 0 {
+		return Result{Status: StatusSkipped}, nil
+	}
+	if s.engine.Indexes(f.DocType) {
+		f.TopicVector = &model.TopicVector{Terms: terms}
+	}
+	if !check {
+		return Result{}, nil
+	}
+
+	report, err := s.engine.Check(terms, f.TeamID, f.Intake)
+	if err != nil {
+		return Result{}, Unavailable("Topic check unavailable", err)
+	}
+	report.Apply(s.warn, s.block)
+	f.Topic = report
+
+	if report.Action == service.TopicBlocked {
+		return Result{}, Reject(http.StatusBadRequest, "Topic is not unique", map[string]interface{}{
+			"message":   fmt.Sprintf("The topic is %g%% or more similar to an earlier project", s.block),
+			"type":      "topic",
+			"detected":  report.Similarity,
+			"threshold": s.block,
+			"keywords":  report.Keywords,
+			"matches":   report.Matches,
+		})
+	}
+
+	details := map[string]interface{}{
+		"topic_similarity": report.Similarity,
+		"topic_keywords":   report.Keywords,
+		"topic_matches":    report.Matches,
+	}
+	if report.Action == service.TopicWarn {
+		details["topic_warning"] = fmt.Sprintf("The topic is %g%% or more similar to an earlier project", s.warn)
+	}
+	return Result{Details: details}, nil
+}
@@ -0,0 +1,231 @@
+package progress
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Event types published for an upload.
+const (
+	EventTransfer    = "transfer"
+	EventQueued      = "queued"
+	EventStage       = "stage"
+	EventStageResult = "stage_result"
+	EventComplete    = "complete"
+	EventFailed      = "failed"
+)
+
+// subscriberBuffer is how far a subscriber may fall behind before it is
+// dropped. Dropped clients reconnect with Last-Event-ID and are replayed
+// what they missed.
+const subscriberBuffer = 64
+
+var uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,32}$`)
+
+// ValidID reports whether id can be used as an upload ID.
+func ValidID(id string) bool {
+	return uploadIDPattern.MatchString(id)
+}
+
+// UploadID returns the client supplied upload ID from the upload_id query
+// parameter or the X-Upload-ID header.
+func UploadID(r *http.Request) string {
+	if id := r.URL.Query().Get("upload_id"); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Upload-ID")
+}
+
+type Event struct {
+	ID   int64       `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Terminal reports whether no further events follow this one.
+func (e Event) Terminal() bool {
+	return e.Type == EventComplete || e.Type == EventFailed
+}
+
+type stream struct {
+	events       []Event
+	lastID       int64
+	subscribers  map[chan Event]struct{}
+	done         bool
+	lastActivity time.Time
+}
+
+// Broker fans out progress events per upload ID and keeps a bounded history
+// so reconnecting clients can resume from the last event they saw.
+type Broker struct {
+	mu          sync.Mutex
+	streams     map[string]*stream
+	historySize int
+	retention   time.Duration
+}
+
+func NewBroker(historySize int, retention time.Duration) *Broker {
+	return &Broker{
+		streams:     make(map[string]*stream),
+		historySize: historySize,
+		retention:   retention,
+	}
+}
+
+func (b *Broker) stream(uploadID string) (*stream, bool) {
+	s, ok := b.streams[uploadID]
+	if !ok {
+		s = &stream{
+			subscribers: make(map[chan Event]struct{}),
+		}
+		b.streams[uploadID] = s
+	}
+	s.lastActivity = time.Now()
+	return s, !ok
+}
+
+// Publish sends an event to every subscriber of uploadID. Complete and
+// failed events end the stream.
+func (b *Broker) Publish(uploadID string, eventType string, data interface{}) {
+	if uploadID == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, _ := b.stream(uploadID)
+	if s.done {
+		// A job that is retried after a restart starts a fresh stream
+		s.done = false
+	}
+
+	now := time.Now()
+	event := Event{ID: s.nextEventID(now), Type: eventType, Time: now, Data: data}
+
+	s.events = append(s.events, event)
+	if b.historySize > 0 && len(s.events) > b.historySize {
+		s.events = s.events[len(s.events)-b.historySize:]
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	if event.Terminal() {
+		s.done = true
+		for ch := range s.subscribers {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// EventIDAt is the lowest ID of the events published at or after t.
+func EventIDAt(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+// nextEventID returns an ID above every earlier one of the stream. IDs
+// follow the clock rather than counting from 1, so they keep increasing
+// across restarts and after idle streams are forgotten, and a client
+// resuming from an earlier run's event is never taken to be ahead.
+func (s *stream) nextEventID(now time.Time) int64 {
+	id := EventIDAt(now)
+	if id <= s.lastID {
+		id = s.lastID + 1
+	}
+	s.lastID = id
+	return id
+}
+
+// Subscription is a client's view of one upload's events.
+type Subscription struct {
+	// Replay holds the retained events after the requested ID
+	Replay []Event
+	// Events is closed when the stream ends or the subscriber falls behind
+	Events <-chan Event
+	// New is set when the broker had no events for the upload yet
+	New bool
+	// Done is set when the stream has already ended
+	Done bool
+
+	cancel func()
+}
+
+func (s *Subscription) Cancel() {
+	s.cancel()
+}
+
+// Subscribe returns the events after lastEventID and a channel for the ones
+// that follow. Subscribing to an unknown upload is allowed so clients can
+// connect before they start sending the file.
+func (b *Broker) Subscribe(uploadID string, lastEventID int64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, created := b.stream(uploadID)
+	sub := &Subscription{New: created || len(s.events) == 0, Done: s.done}
+	for _, event := range s.events {
+		if event.ID > lastEventID {
+			sub.Replay = append(sub.Replay, event)
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	sub.Events = ch
+	if s.done {
+		close(ch)
+		sub.cancel = func() {}
+		return sub
+	}
+
+	s.subscribers[ch] = struct{}{}
+	sub.cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+	return sub
+}
+
+// StartCleanup forgets streams without subscribers that have been idle for
+// longer than the retention period.
+func (b *Broker) StartCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.removeIdle(time.Now().Add(-b.retention))
+			}
+		}
+	}()
+}
+
+func (b *Broker) removeIdle(cutoff time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, s := range b.streams {
+		if len(s.subscribers) == 0 && s.lastActivity.Before(cutoff) {
+			delete(b.streams, id)
+		}
+	}
+}
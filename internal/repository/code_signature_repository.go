@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hashesPerQuery keeps the IN list of a fingerprint lookup well below the
+// bind parameter limit.
+const hashesPerQuery = 5000
+
+// CodeFingerprintMatch is a stored fingerprint sharing a hash with an
+// upload, along with the source file and archive it belongs to.
+type CodeFingerprintMatch struct {
+	FileID       uint   `json:"file_id"`
+	OriginalName string `json:"original_name"`
+	TeamID       string `json:"team_id"`
+	Intake       string `json:"intake"`
+	AcademicYear string `json:"academic_year"`
+	Path         string `json:"path"`
+	Hash         int64  `json:"-"`
+	StartLine    int    `json:"-"`
+	EndLine      int    `json:"-"`
+}
+
+// CodeArchiveMatch is a stored archive sharing fingerprint hashes with an
+// upload.
+type CodeArchiveMatch struct {
+	FileID       uint   `json:"file_id"`
+	OriginalName string `json:"original_name"`
+	TeamID       string `json:"team_id"`
+	Intake       string `json:"intake"`
+	AcademicYear string `json:"academic_year"`
+	// Shared is the number of distinct hashes found in the archive
+	Shared int `json:"shared"`
+}
+
+type CodeSignatureRepository interface {
+	Create(signature *model.CodeSignature) error
+	// CommonHashes returns the hashes found in more than maxArchives of the
+	// archives an upload is compared with: those of the same intake or
+	// earlier academic years, leaving out the files of teamID in intake. An
+	// empty academicYear matches every year.
+	CommonHashes(hashes []int64, teamID string, intake string, academicYear string, maxArchives int) (map[int64]bool, error)
+	// Archives returns the archives sharing at least one hash with hashes,
+	// from the same archives as CommonHashes. Most shared hashes come first,
+	// then archives of intake, then the newest. At most limit archives are
+	// returned.
+	Archives(hashes []int64, teamID string, intake string, academicYear string, limit int) ([]CodeArchiveMatch, error)
+	// Matches returns the fingerprints of the archives fileIDs sharing a
+	// hash with hashes, archives of intake first, then the newest. At most
+	// limit rows are returned.
+	Matches(hashes []int64, fileIDs []uint, intake string, limit int) ([]CodeFingerprintMatch, error)
+	// Hashes returns the distinct fingerprint hashes of each file by file ID.
+	Hashes(fileIDs []uint) (map[uint][]int64, error)
+}
+
+type codeSignatureRepository struct {
+	db *gorm.DB
+}
+
+func NewCodeSignatureRepository(db *gorm.DB) CodeSignatureRepository {
+	return &codeSignatureRepository{db: db}
+}
+
+func (r *codeSignatureRepository) Create(signature *model.CodeSignature) error {
+	return r.db.Create(signature).Error
+}
+
+// fingerprints selects the stored fingerprints with a hash in chunk, joined
+// with their source file and archive.
+func (r *codeSignatureRepository) fingerprints(chunk []int64) *gorm.DB {
+	return r.db.Table("code_fingerprints AS fp").
+		Joins("JOIN code_sources src ON src.id = fp.source_id").
+		Joins("JOIN code_signatures s ON s.id = src.signature_id").
+		Joins("JOIN files f ON f.id = s.file_id").
+		Where("fp.hash IN ?", chunk)
+}
+
+// compared narrows query to the archives an upload of teamID to intake is
+// compared with.
+func compared(query *gorm.DB, teamID string, intake string, academicYear string) *gorm.DB {
+	query = query.Where("NOT (f.team_id = ? AND f.intake = ?)", teamID, intake)
+	if academicYear != "" {
+		query = query.Where("(f.intake = ? OR f.academic_year <= ?)", intake, academicYear)
+	}
+	return query
+}
+
+func (r *codeSignatureRepository) CommonHashes(hashes []int64, teamID string, intake string, academicYear string, maxArchives int) (map[int64]bool, error) {
+	common := make(map[int64]bool)
+	for start := 0; start < len(hashes); start += hashesPerQuery {
+		chunk := hashes[start:min(start+hashesPerQuery, len(hashes))]
+
+		var rows []int64
+		err := compared(r.fingerprints(chunk), teamID, intake, academicYear).
+			Group("fp.hash").
+			Having("COUNT(DISTINCT f.id) > ?", maxArchives).
+			Pluck("fp.hash", &rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range rows {
+			common[hash] = true
+		}
+	}
+	return common, nil
+}
+
+func (r *codeSignatureRepository) Archives(hashes []int64, teamID string, intake string, academicYear string, limit int) ([]CodeArchiveMatch, error) {
+	// Each hash is in one chunk only, so the counts of the chunks add up
+	archives := make(map[uint]*CodeArchiveMatch)
+	for start := 0; start < len(hashes); start += hashesPerQuery {
+		chunk := hashes[start:min(start+hashesPerQuery, len(hashes))]
+
+		var rows []CodeArchiveMatch
+		err := compared(r.fingerprints(chunk), teamID, intake, academicYear).
+			Select("f.id AS file_id, f.original_name, f.team_id, f.intake, f.academic_year, " +
+				"COUNT(DISTINCT fp.hash) AS shared").
+			Group("f.id, f.original_name, f.team_id, f.intake, f.academic_year").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			if a, ok := archives[rows[i].FileID]; ok {
+				a.Shared += rows[i].Shared
+				continue
+			}
+			archives[rows[i].FileID] = &rows[i]
+		}
+	}
+
+	matches := make([]CodeArchiveMatch, 0, len(archives))
+	for _, a := range archives {
+		matches = append(matches, *a)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		mi, mj := matches[i], matches[j]
+		if mi.Shared != mj.Shared {
+			return mi.Shared > mj.Shared
+		}
+		if (mi.Intake == intake) != (mj.Intake == intake) {
+			return mi.Intake == intake
+		}
+		return mi.FileID > mj.FileID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (r *codeSignatureRepository) Matches(hashes []int64, fileIDs []uint, intake string, limit int) ([]CodeFingerprintMatch, error) {
+	var matches []CodeFingerprintMatch
+	if len(fileIDs) == 0 {
+		return matches, nil
+	}
+	for start := 0; start < len(hashes) && len(matches) < limit; start += hashesPerQuery {
+		chunk := hashes[start:min(start+hashesPerQuery, len(hashes))]
+
+		var rows []CodeFingerprintMatch
+		err := r.fingerprints(chunk).
+			Select("f.id AS file_id, f.original_name, f.team_id, f.intake, f.academic_year, "+
+				"src.path, fp.hash, fp.start_line, fp.end_line").
+			Where("f.id IN ?", fileIDs).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "CASE WHEN f.intake = ? THEN 0 ELSE 1 END, f.id DESC, src.id, fp.id",
+				Vars: []interface{}{intake},
+			}}).
+			Limit(limit - len(matches)).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, rows...)
+	}
+	return matches, nil
+}
+
+func (r *codeSignatureRepository) Hashes(fileIDs []uint) (map[uint][]int64, error) {
+	hashes := make(map[uint][]int64, len(fileIDs))
+	if len(fileIDs) == 0 {
+		return hashes, nil
+	}
+
+	var rows []struct {
+		FileID uint
+		Hash   int64
+	}
+	err := r.db.Table("code_fingerprints AS fp").
+		Select("DISTINCT s.file_id, fp.hash").
+		Joins("JOIN code_sources src ON src.id = fp.source_id").
+		Joins("JOIN code_signatures s ON s.id = src.signature_id").
+		Where("s.file_id IN ?", fileIDs).
+		Scan(&rows).Error
+	for _, row := range rows {
+		hashes[row.FileID] = append(hashes[row.FileID], row.Hash)
+	}
+	return hashes, err
+}
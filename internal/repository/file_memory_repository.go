@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// memoryFileRepository keeps files in memory, for unit tests. It behaves
+// like the database repository, down to its errors: a missing file is
+// gorm.ErrRecordNotFound. Files keep their document, as FindByID preloads
+// it; their other associations are not stored.
+type memoryFileRepository struct {
+	mu      sync.RWMutex
+	files   map[uint]*model.File
+	reports []model.PlagiarismReport
+	lastID  uint
+}
+
+// NewMemoryFileRepository returns an empty FileRepository that keeps files
+// in memory.
+func NewMemoryFileRepository() FileRepository {
+	return &memoryFileRepository{files: make(map[uint]*model.File)}
+}
+
+func (r *memoryFileRepository) Create(file *model.File) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if file.ID == 0 {
+		file.ID = r.lastID + 1
+	} else if _, ok := r.files[file.ID]; ok {
+		return fmt.Errorf("file %d already exists", file.ID)
+	}
+	if file.Version == 0 {
+		file.Version = 1
+	}
+	for _, f := range r.files {
+		if f.TeamID == file.TeamID && f.Intake == file.Intake && f.DocType == file.DocType && f.Version == file.Version {
+			return ErrVersionTaken
+		}
+	}
+	r.lastID = max(r.lastID, file.ID)
+	if file.CreatedAt.IsZero() {
+		file.CreatedAt = time.Now()
+	}
+
+	stored := *file
+	stored.Signature, stored.TopicVector, stored.CodeSignature, stored.PlagiarismReports = nil, nil, nil, nil
+	if file.Document != nil {
+		file.Document.ID = file.ID
+		file.Document.FileID = file.ID
+		if file.Document.CreatedAt.IsZero() {
+			file.Document.CreatedAt = file.CreatedAt
+		}
+		doc := *file.Document
+		stored.Document = &doc
+	}
+	r.files[file.ID] = &stored
+	return nil
+}
+
+func (r *memoryFileRepository) FindByID(id uint) (*model.File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.files[id]
+	if !ok {
+		return &model.File{}, gorm.ErrRecordNotFound
+	}
+	file := *stored
+	if stored.Document != nil {
+		doc := *stored.Document
+		file.Document = &doc
+	}
+	return &file, nil
+}
+
+func (r *memoryFileRepository) DeleteByKey(key string) error {
+	if key == "" {
+		return errors.New("empty file key provided")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for id, f := range r.files {
+		if f.StorageKey == key {
+			delete(r.files, id)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return fmt.Errorf("no record found with key: %s", key)
+	}
+	return nil
+}
+
+func (r *memoryFileRepository) List(query FileQuery) (*FilePage, error) {
+	if _, ok := fileSortColumns[query.Sort.Column]; !ok {
+		return nil, fmt.Errorf("files cannot be sorted by %q", query.Sort.Column)
+	}
+	if query.Limit < 1 {
+		return nil, errors.New("listing limit must be positive")
+	}
+	compare := compareFiles(query.Sort)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var after *model.File
+	if query.After != nil {
+		var err error
+		if after, err = cursorFile(query.Sort.Column, query.After); err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+
+	page := &FilePage{}
+	var matched []model.File
+	for _, f := range r.files {
+		if !query.matches(f) {
+			continue
+		}
+		page.Total++
+		if after == nil || compare(f, after) > 0 {
+			matched = append(matched, listed(f))
+		}
+	}
+	if !query.WithTotal {
+		page.Total = 0
+	}
+
+	slices.SortFunc(matched, func(a, b model.File) int { return compare(&a, &b) })
+	if len(matched) > query.Limit {
+		matched = matched[:query.Limit]
+		last := &matched[len(matched)-1]
+		page.Next = &FileCursor{Value: fileSortColumns[query.Sort.Column].format(last), ID: last.ID}
+	}
+	page.Files = matched
+	if page.Files == nil {
+		page.Files = []model.File{}
+	}
+	return page, nil
+}
+
+// matches reports whether f passes the filters of q.
+func (q FileQuery) matches(f *model.File) bool {
+	filters := []struct{ value, want string }{
+		{f.TeamID, q.TeamID},
+		{f.Intake, q.Intake},
+		{f.AcademicYear, q.AcademicYear},
+		{f.FileType, q.FileType},
+		{f.DocType, q.DocType},
+		{f.UploadedBy, q.UploadedBy},
+		{f.ScanStatus, q.ScanStatus},
+		{f.PlagiarismStatus, q.PlagiarismStatus},
+		{f.IntegrityStatus, q.IntegrityStatus},
+	}
+	for _, filter := range filters {
+		if filter.want != "" && filter.value != filter.want {
+			return false
+		}
+	}
+	if q.CreatedFrom != nil && f.CreatedAt.Before(*q.CreatedFrom) {
+		return false
+	}
+	if q.CreatedUntil != nil && !f.CreatedAt.Before(*q.CreatedUntil) {
+		return false
+	}
+	return true
+}
+
+// compareFiles orders files as a listing sorted by sort does.
+func compareFiles(sort FileSort) func(a, b *model.File) int {
+	return func(a, b *model.File) int {
+		var c int
+		switch sort.Column {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "size":
+			c = cmp.Compare(a.Size, b.Size)
+		case "version":
+			c = cmp.Compare(a.Version, b.Version)
+		case "original_name":
+			c = cmp.Compare(a.OriginalName, b.OriginalName)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if sort.Desc {
+			return -c
+		}
+		return c
+	}
+}
+
+// cursorFile is a file with the sort column value and ID of a cursor, to
+// compare listed files with.
+func cursorFile(column string, cursor *FileCursor) (*model.File, error) {
+	f := &model.File{ID: cursor.ID}
+	var err error
+	switch column {
+	case "created_at":
+		f.CreatedAt, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case "size":
+		f.Size, err = strconv.ParseInt(cursor.Value, 10, 64)
+	case "version":
+		f.Version, err = strconv.Atoi(cursor.Value)
+	case "original_name":
+		f.OriginalName = cursor.Value
+	}
+	return f, err
+}
+
+// listed is f as listings return it, without associations.
+func listed(f *model.File) model.File {
+	file := *f
+	file.Document = nil
+	return file
+}
+
+func (r *memoryFileRepository) NextVersion(teamID string, intake string, docType string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest := 0
+	for _, f := range r.files {
+		if f.TeamID == teamID && f.Intake == intake && f.DocType == docType {
+			latest = max(latest, f.Version)
+		}
+	}
+	return latest + 1, nil
+}
+
+func (r *memoryFileRepository) FindDueForVerification(verifiedBefore time.Time, limit int) ([]model.File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	files := []model.File{}
+	for _, f := range r.files {
+		if f.ChecksumSHA256 == "" && f.ChecksumMD5 == "" {
+			continue
+		}
+		if f.VerifiedAt == nil || f.VerifiedAt.Before(verifiedBefore) {
+			files = append(files, listed(f))
+		}
+	}
+	slices.SortFunc(files, func(a, b model.File) int {
+		switch {
+		case a.VerifiedAt == nil && b.VerifiedAt != nil:
+			return -1
+		case a.VerifiedAt != nil && b.VerifiedAt == nil:
+			return 1
+		case a.VerifiedAt != nil && !a.VerifiedAt.Equal(*b.VerifiedAt):
+			return a.VerifiedAt.Compare(*b.VerifiedAt)
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return files[:min(limit, len(files))], nil
+}
+
+func (r *memoryFileRepository) UpdateIntegrity(id uint, status string, verifiedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.files[id]; ok {
+		f.IntegrityStatus = status
+		f.VerifiedAt = &verifiedAt
+	}
+	return nil
+}
+
+func (r *memoryFileRepository) FindPendingPlagiarism(afterID uint, limit int) ([]model.File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	files := []model.File{}
+	for _, f := range r.files {
+		if f.PlagiarismStatus == model.PlagiarismPending && f.ID > afterID {
+			files = append(files, listed(f))
+		}
+	}
+	slices.SortFunc(files, func(a, b model.File) int { return cmp.Compare(a.ID, b.ID) })
+	return files[:min(limit, len(files))], nil
+}
+
+func (r *memoryFileRepository) RecordPlagiarism(report *model.PlagiarismReport) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if report.FileID == nil {
+		return false, nil
+	}
+	f, ok := r.files[*report.FileID]
+	if !ok || f.PlagiarismStatus != model.PlagiarismPending {
+		return false, nil
+	}
+
+	checkedAt := report.CheckedAt
+	f.PlagiarismStatus = report.Status
+	f.PlagiarismPercent = report.Score
+	f.PlagiarismProvider = report.Provider
+	f.PlagiarismCheckedAt = &checkedAt
+
+	report.ID = uint(len(r.reports) + 1)
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	r.reports = append(r.reports, *report)
+	return true, nil
+}
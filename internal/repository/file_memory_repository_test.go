@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/repository/repotest"
+)
+
+func TestMemoryFileRepository(t *testing.T) {
+	repotest.TestFileRepository(t, func(t *testing.T) repository.FileRepository {
+		return repository.NewMemoryFileRepository()
+	})
+}
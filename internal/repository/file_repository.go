@@ -3,6 +3,8 @@ package repository
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/sohan-reza/capstone-core/internal/model"
 
@@ -10,13 +12,149 @@ import (
 )
 
 type FileRepository interface {
+	// Create inserts file, or returns ErrVersionTaken when another file of
+	// the team's doc_type already has its version.
 	Create(file *model.File) error
 	FindByID(id uint) (*model.File, error)
 	DeleteByKey(key string) error
-	GetFilesByTeamID(teamID string) ([]struct {
-		DownloadURL string `json:"download_url"`
-		FileType    string `json:"file_type"`
-	}, error)
+	// List returns a page of the files matching query.
+	List(query FileQuery) (*FilePage, error)
+	// NextVersion returns the version for a team's next file of docType. The
+	// version is only taken once the file is created.
+	NextVersion(teamID string, intake string, docType string) (int, error)
+	FindDueForVerification(verifiedBefore time.Time, limit int) ([]model.File, error)
+	UpdateIntegrity(id uint, status string, verifiedAt time.Time) error
+	// FindPendingPlagiarism returns files after afterID that were stored
+	// without a plagiarism check, in ID order.
+	FindPendingPlagiarism(afterID uint, limit int) ([]model.File, error)
+	// RecordPlagiarism stores the result of a late check on its file and
+	// keeps the report. It returns false without recording anything when
+	// the file is no longer pending.
+	RecordPlagiarism(report *model.PlagiarismReport) (bool, error)
+}
+
+// ErrVersionTaken is returned when a file is created with a version another
+// file of the same team and doc_type got first. The caller picks the next
+// version and tries again.
+var ErrVersionTaken = errors.New("file version already taken")
+
+// versionTaken turns a violation of the unique version index into
+// ErrVersionTaken.
+func versionTaken(db *gorm.DB, err error) error {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return ErrVersionTaken
+	}
+	return err
+}
+
+// FileQuery selects a page of files. Empty filters match every file.
+type FileQuery struct {
+	TeamID           string
+	Intake           string
+	AcademicYear     string
+	FileType         string
+	DocType          string
+	UploadedBy       string
+	ScanStatus       string
+	PlagiarismStatus string
+	IntegrityStatus  string
+	// CreatedFrom is inclusive, CreatedUntil exclusive
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+
+	Sort FileSort
+	// After continues a listing after the file the cursor points at
+	After *FileCursor
+	// Limit is the page size; it must be positive
+	Limit int
+	// WithTotal counts every file matching the filters
+	WithTotal bool
+}
+
+func (q FileQuery) filter(db *gorm.DB) *gorm.DB {
+	filters := []struct{ column, value string }{
+		{"team_id", q.TeamID},
+		{"intake", q.Intake},
+		{"academic_year", q.AcademicYear},
+		{"file_type", q.FileType},
+		{"doc_type", q.DocType},
+		{"uploaded_by", q.UploadedBy},
+		{"scan_status", q.ScanStatus},
+		{"plagiarism_status", q.PlagiarismStatus},
+		{"integrity_status", q.IntegrityStatus},
+	}
+	for _, f := range filters {
+		if f.value != "" {
+			db = db.Where(f.column+" = ?", f.value)
+		}
+	}
+	if q.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedUntil != nil {
+		db = db.Where("created_at < ?", *q.CreatedUntil)
+	}
+	return db
+}
+
+// FileSort orders a listing by one column, ties broken by ID in the same
+// direction.
+type FileSort struct {
+	Column string
+	Desc   bool
+}
+
+// FileCursor is the position of a file in a sorted listing: its value in
+// the sort column, as text, and its ID.
+type FileCursor struct {
+	Value string
+	ID    uint
+}
+
+// FilePage is one page of a listing. Next is nil on the last page; Total
+// is only set when asked for.
+type FilePage struct {
+	Files []model.File
+	Total int64
+	Next  *FileCursor
+}
+
+// fileSortColumns formats a file's value in each sort column as cursor
+// text and parses it back. Cursors of the ID column only need the ID.
+var fileSortColumns = map[string]struct {
+	format func(*model.File) string
+	parse  func(string) (interface{}, error)
+}{
+	"created_at": {
+		format: func(f *model.File) string { return f.CreatedAt.UTC().Format(time.RFC3339Nano) },
+		parse: func(value string) (interface{}, error) {
+			return time.Parse(time.RFC3339Nano, value)
+		},
+	},
+	"size": {
+		format: func(f *model.File) string { return strconv.FormatInt(f.Size, 10) },
+		parse:  parseInt,
+	},
+	"version": {
+		format: func(f *model.File) string { return strconv.Itoa(f.Version) },
+		parse:  parseInt,
+	},
+	"original_name": {
+		format: func(f *model.File) string { return f.OriginalName },
+		parse:  func(value string) (interface{}, error) { return value, nil },
+	},
+	"id": {
+		format: func(f *model.File) string { return "" },
+	},
+}
+
+// FileSortColumns lists the columns files can be sorted by.
+func FileSortColumns() []string {
+	return []string{"created_at", "size", "version", "original_name", "id"}
+}
+
+func parseInt(value string) (interface{}, error) {
+	return strconv.ParseInt(value, 10, 64)
 }
 
 type fileRepository struct {
@@ -28,12 +166,12 @@ func NewFileRepository(db *gorm.DB) FileRepository {
 }
 
 func (r *fileRepository) Create(file *model.File) error {
-	return r.db.Create(file).Error
+	return versionTaken(r.db, r.db.Create(file).Error)
 }
 
 func (r *fileRepository) FindByID(id uint) (*model.File, error) {
 	var file model.File
-	err := r.db.First(&file, id).Error
+	err := r.db.Preload("Document").First(&file, id).Error
 	return &file, err
 }
 
@@ -54,21 +192,118 @@ func (r *fileRepository) DeleteByKey(key string) error {
 	return nil
 }
 
-func (r *fileRepository) GetFilesByTeamID(teamID string) ([]struct {
-	DownloadURL string `json:"download_url"`
-	FileType    string `json:"file_type"`
-}, error) {
-	var results []struct {
-		DownloadURL string `json:"download_url"`
-		FileType    string `json:"file_type"`
+func (r *fileRepository) List(query FileQuery) (*FilePage, error) {
+	column, ok := fileSortColumns[query.Sort.Column]
+	if !ok {
+		return nil, fmt.Errorf("files cannot be sorted by %q", query.Sort.Column)
+	}
+	if query.Limit < 1 {
+		return nil, errors.New("listing limit must be positive")
 	}
 
+	page := &FilePage{}
+	if query.WithTotal {
+		err := r.db.Model(&model.File{}).Scopes(query.filter).Count(&page.Total).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	op, dir := ">", "ASC"
+	if query.Sort.Desc {
+		op, dir = "<", "DESC"
+	}
+	db := r.db.Scopes(query.filter)
+	name := query.Sort.Column
+	switch {
+	case query.After == nil:
+	case column.parse == nil:
+		db = db.Where("id "+op+" ?", query.After.ID)
+	default:
+		value, err := column.parse(query.After.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		db = db.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", name, op),
+			value, value, query.After.ID)
+	}
+
+	// One file more than asked for tells whether there is a next page
+	err := db.Order(fmt.Sprintf("%s %s, id %s", name, dir, dir)).
+		Limit(query.Limit + 1).
+		Find(&page.Files).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Files) > query.Limit {
+		page.Files = page.Files[:query.Limit]
+		last := &page.Files[len(page.Files)-1]
+		page.Next = &FileCursor{Value: column.format(last), ID: last.ID}
+	}
+	return page, nil
+}
+
+// NextVersion returns the version number for a team's next file of docType,
+// one more than the highest stored so far.
+func (r *fileRepository) NextVersion(teamID string, intake string, docType string) (int, error) {
+	var latest int
 	err := r.db.Model(&model.File{}).
-		Where("team_id = ?", teamID).
-		Select("download_url, file_type").
-		Find(&results).Error
+		Where("team_id = ? AND intake = ? AND doc_type = ?", teamID, intake, docType).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error
+	return latest + 1, err
+}
+
+// FindDueForVerification returns files with a recorded digest that have never
+// been verified or were last verified before verifiedBefore, oldest first.
+func (r *fileRepository) FindDueForVerification(verifiedBefore time.Time, limit int) ([]model.File, error) {
+	var files []model.File
+	err := r.db.
+		Where("checksum_sha256 <> '' OR checksum_md5 <> ''").
+		Where("verified_at IS NULL OR verified_at < ?", verifiedBefore).
+		Order("verified_at NULLS FIRST, id").
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
+
+func (r *fileRepository) UpdateIntegrity(id uint, status string, verifiedAt time.Time) error {
+	return r.db.Model(&model.File{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"integrity_status": status,
+			"verified_at":      verifiedAt,
+		}).Error
+}
+
+func (r *fileRepository) FindPendingPlagiarism(afterID uint, limit int) ([]model.File, error) {
+	var files []model.File
+	err := r.db.
+		Where("plagiarism_status = ? AND id > ?", model.PlagiarismPending, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
 
-	return results, err
+func (r *fileRepository) RecordPlagiarism(report *model.PlagiarismReport) (bool, error) {
+	recorded := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.File{}).
+			Where("id = ? AND plagiarism_status = ?", report.FileID, model.PlagiarismPending).
+			Updates(map[string]interface{}{
+				"plagiarism_status":     report.Status,
+				"plagiarism_percent":    report.Score,
+				"plagiarism_provider":   report.Provider,
+				"plagiarism_checked_at": report.CheckedAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		recorded = true
+		return tx.Create(report).Error
+	})
+	return recorded, err
 }
 
 // func (r *fileRepository) GetURLWithRefresh(id uint) (string, error) {
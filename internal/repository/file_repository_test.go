@@ -0,0 +1,29 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sohan-reza/capstone-core/internal/database"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/repository/repotest"
+)
+
+func TestFileRepository(t *testing.T) {
+	repotest.TestFileRepository(t, func(t *testing.T) repository.FileRepository {
+		db, err := database.OpenSQLite(database.Memory)
+		if err != nil {
+			t.Fatalf("OpenSQLite: %v", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+
+		if err := database.Prepare(context.Background(), db); err != nil {
+			t.Fatalf("Prepare: %v", err)
+		}
+		return repository.NewFileRepository(db)
+	})
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	// Acquire stores record as in flight. If a live record already exists for
+	// the same client and key, it is returned instead and acquired is false,
+	// unless it is an in-flight record of the same request whose lock has
+	// expired, which is taken over.
+	Acquire(record *model.IdempotencyRecord) (existing *model.IdempotencyRecord, acquired bool, err error)
+	Find(clientID string, key string) (*model.IdempotencyRecord, error)
+	// Renew extends the lock of an in-flight record.
+	Renew(id uint, lockedUntil time.Time) error
+	Complete(id uint, code int, headers map[string][]string, body []byte) error
+	Release(id uint) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+func (r *idempotencyRepository) Acquire(record *model.IdempotencyRecord) (*model.IdempotencyRecord, bool, error) {
+	// Two attempts: the second one runs after an expired record was cleared
+	for attempt := 0; attempt < 2; attempt++ {
+		result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil, true, nil
+		}
+
+		existing, err := r.Find(record.ClientID, record.Key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if existing.ExpiresAt.After(time.Now()) {
+			taken, err := r.takeOver(existing, record)
+			if err != nil || taken {
+				return nil, taken, err
+			}
+			return existing, false, nil
+		}
+
+		if err := r.db.Delete(&model.IdempotencyRecord{}, existing.ID).Error; err != nil {
+			return nil, false, err
+		}
+		record.ID = 0
+	}
+
+	return nil, false, errors.New("failed to acquire idempotency key")
+}
+
+// takeOver hands an in-flight record whose owner stopped renewing its lock,
+// most likely because the process died, to a retry of the same request.
+func (r *idempotencyRepository) takeOver(existing *model.IdempotencyRecord, record *model.IdempotencyRecord) (bool, error) {
+	if existing.Status != model.IdempotencyInFlight || existing.RequestHash != record.RequestHash {
+		return false, nil
+	}
+
+	now := time.Now()
+	result := r.db.Model(&model.IdempotencyRecord{}).
+		Where("id = ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", existing.ID, model.IdempotencyInFlight, now).
+		Updates(map[string]interface{}{
+			"locked_until": record.LockedUntil,
+			"expires_at":   record.ExpiresAt,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	record.ID = existing.ID
+	return true, nil
+}
+
+func (r *idempotencyRepository) Find(clientID string, key string) (*model.IdempotencyRecord, error) {
+	var record model.IdempotencyRecord
+	err := r.db.Where("client_id = ? AND key = ?", clientID, key).First(&record).Error
+	return &record, err
+}
+
+func (r *idempotencyRepository) Renew(id uint, lockedUntil time.Time) error {
+	return r.db.Model(&model.IdempotencyRecord{}).
+		Where("id = ? AND status = ?", id, model.IdempotencyInFlight).
+		Update("locked_until", lockedUntil).Error
+}
+
+func (r *idempotencyRepository) Complete(id uint, code int, headers map[string][]string, body []byte) error {
+	return r.db.Model(&model.IdempotencyRecord{ID: id}).Updates(&model.IdempotencyRecord{
+		Status:          model.IdempotencyCompleted,
+		ResponseCode:    code,
+		ResponseHeaders: headers,
+		ResponseBody:    body,
+	}).Error
+}
+
+// Release drops an in-flight record so the request can be retried, e.g.
+// after the handler failed with a server error.
+func (r *idempotencyRepository) Release(id uint) error {
+	return r.db.Delete(&model.IdempotencyRecord{}, id).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", now).Delete(&model.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IntakeRepository interface {
+	Create(intake *model.Intake) error
+	Update(intake *model.Intake) error
+	Delete(id uint) error
+	// FindByName looks an intake up by name, ignoring case.
+	FindByName(name string) (*model.Intake, error)
+	// List returns every intake, newest academic year first.
+	List() ([]model.Intake, error)
+	HasTeams(id uint) (bool, error)
+}
+
+type intakeRepository struct {
+	db *gorm.DB
+}
+
+func NewIntakeRepository(db *gorm.DB) IntakeRepository {
+	return &intakeRepository{db: db}
+}
+
+func (r *intakeRepository) Create(intake *model.Intake) error {
+	return r.db.Omit(clause.Associations).Create(intake).Error
+}
+
+func (r *intakeRepository) Update(intake *model.Intake) error {
+	return r.db.Omit(clause.Associations).Save(intake).Error
+}
+
+func (r *intakeRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Intake{}, id).Error
+}
+
+func (r *intakeRepository) FindByName(name string) (*model.Intake, error) {
+	var intake model.Intake
+	err := r.db.Where("LOWER(name) = LOWER(?)", name).First(&intake).Error
+	return &intake, err
+}
+
+func (r *intakeRepository) List() ([]model.Intake, error) {
+	var intakes []model.Intake
+	err := r.db.Order("academic_year DESC, name").Find(&intakes).Error
+	return intakes, err
+}
+
+func (r *intakeRepository) HasTeams(id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Team{}).Where("intake_id = ?", id).Count(&count).Error
+	return count > 0, err
+}
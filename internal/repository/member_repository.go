@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type MemberRepository interface {
+	Create(member *model.Member) error
+	Update(member *model.Member) error
+	Delete(id uint) error
+	// FindByID returns a member of the team, so members cannot be reached
+	// through another team's URL.
+	FindByID(teamID uint, id uint) (*model.Member, error)
+	FindByStudentID(teamID uint, studentID string) (*model.Member, error)
+	FindByTeamID(teamID uint) ([]model.Member, error)
+}
+
+type memberRepository struct {
+	db *gorm.DB
+}
+
+func NewMemberRepository(db *gorm.DB) MemberRepository {
+	return &memberRepository{db: db}
+}
+
+func (r *memberRepository) Create(member *model.Member) error {
+	return r.db.Create(member).Error
+}
+
+func (r *memberRepository) Update(member *model.Member) error {
+	return r.db.Save(member).Error
+}
+
+func (r *memberRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Member{}, id).Error
+}
+
+func (r *memberRepository) FindByID(teamID uint, id uint) (*model.Member, error) {
+	var member model.Member
+	err := r.db.Where("team_id = ?", teamID).First(&member, id).Error
+	return &member, err
+}
+
+func (r *memberRepository) FindByStudentID(teamID uint, studentID string) (*model.Member, error) {
+	var member model.Member
+	err := r.db.Where("team_id = ? AND student_id = ?", teamID, studentID).First(&member).Error
+	return &member, err
+}
+
+func (r *memberRepository) FindByTeamID(teamID uint) ([]model.Member, error) {
+	var members []model.Member
+	err := r.db.Where("team_id = ?", teamID).Order("id").Find(&members).Error
+	return members, err
+}
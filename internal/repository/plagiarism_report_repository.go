@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type PlagiarismReportRepository interface {
+	Create(reports []model.PlagiarismReport) error
+	// FindByFileID returns the reports of a stored file, newest first.
+	FindByFileID(fileID uint) ([]model.PlagiarismReport, error)
+	// FindFlagged returns the flagged reports of an intake, newest first,
+	// optionally narrowed to one academic year.
+	FindFlagged(intake string, academicYear string, limit int) ([]model.PlagiarismReport, error)
+}
+
+type plagiarismReportRepository struct {
+	db *gorm.DB
+}
+
+func NewPlagiarismReportRepository(db *gorm.DB) PlagiarismReportRepository {
+	return &plagiarismReportRepository{db: db}
+}
+
+func (r *plagiarismReportRepository) Create(reports []model.PlagiarismReport) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	return r.db.Create(&reports).Error
+}
+
+func (r *plagiarismReportRepository) FindByFileID(fileID uint) ([]model.PlagiarismReport, error) {
+	var reports []model.PlagiarismReport
+	err := r.db.Where("file_id = ?", fileID).
+		Order("checked_at DESC, id DESC").
+		Find(&reports).Error
+	return reports, err
+}
+
+func (r *plagiarismReportRepository) FindFlagged(intake string, academicYear string, limit int) ([]model.PlagiarismReport, error) {
+	var reports []model.PlagiarismReport
+	query := r.db.Where("intake = ? AND flagged", intake)
+	if academicYear != "" {
+		query = query.Where("academic_year = ?", academicYear)
+	}
+	err := query.Order("checked_at DESC, id DESC").
+		Limit(limit).
+		Find(&reports).Error
+	return reports, err
+}
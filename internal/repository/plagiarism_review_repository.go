@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrReviewClosed is returned when a review was already overridden.
+var ErrReviewClosed = errors.New("review is no longer held")
+
+// ErrFileOverridden is returned when a file's check was already overridden.
+var ErrFileOverridden = errors.New("file check was already overridden")
+
+type PlagiarismReviewRepository interface {
+	// Create stores a review together with its reports.
+	Create(review *model.PlagiarismReview) error
+	FindByID(id uint) (*model.PlagiarismReview, error)
+	// FindHeld returns the reviews of an intake still awaiting a decision,
+	// oldest first.
+	FindHeld(intake string, limit int) ([]model.PlagiarismReview, error)
+	// Release records the stored file for a held review, attaches the
+	// review's reports to it and writes the override, all or nothing. It
+	// returns ErrVersionTaken when the file's version was taken.
+	Release(review *model.PlagiarismReview, file *model.File, override *model.PlagiarismOverride) error
+	// OverrideFile marks a stored file's failed check as overridden and
+	// writes the override, or returns ErrFileOverridden when another
+	// override got there first.
+	OverrideFile(fileID uint, override *model.PlagiarismOverride) error
+	// FindOverrides returns the overrides of an intake, newest first.
+	FindOverrides(intake string, limit int) ([]model.PlagiarismOverride, error)
+
+	// Clear overrides a held review of a batch submission file and writes
+	// the override. The file is accepted when the batch is submitted again,
+	// until expiresAt. It returns ErrReviewClosed when the review is no
+	// longer held.
+	Clear(review *model.PlagiarismReview, override *model.PlagiarismOverride, expiresAt time.Time) error
+	// FindClearance returns the unexpired cleared review of a team's file
+	// of docType with the given SHA-256, or gorm.ErrRecordNotFound.
+	FindClearance(intake string, teamID string, docType string, sha256 string) (*model.PlagiarismReview, error)
+	// UseClearance marks a cleared review as overridden by the file stored
+	// with it and attaches the review's reports and overrides to the file.
+	UseClearance(reviewID uint, fileID uint) error
+	// FindExpired returns batch submission reviews that were neither
+	// resubmitted nor overridden before they expired.
+	FindExpired(now time.Time, limit int) ([]model.PlagiarismReview, error)
+	// Expire closes an expired review. It returns false when the review was
+	// used or is not expired.
+	Expire(id uint, now time.Time) (bool, error)
+}
+
+type plagiarismReviewRepository struct {
+	db *gorm.DB
+}
+
+func NewPlagiarismReviewRepository(db *gorm.DB) PlagiarismReviewRepository {
+	return &plagiarismReviewRepository{db: db}
+}
+
+func (r *plagiarismReviewRepository) Create(review *model.PlagiarismReview) error {
+	return r.db.Create(review).Error
+}
+
+func (r *plagiarismReviewRepository) FindByID(id uint) (*model.PlagiarismReview, error) {
+	var review model.PlagiarismReview
+	err := r.db.
+		Preload("Reports", func(db *gorm.DB) *gorm.DB { return db.Order("checked_at DESC, id DESC") }).
+		Preload("Overrides").
+		First(&review, id).Error
+	return &review, err
+}
+
+func (r *plagiarismReviewRepository) FindHeld(intake string, limit int) ([]model.PlagiarismReview, error) {
+	var reviews []model.PlagiarismReview
+	err := r.db.Where("intake = ? AND status = ?", intake, model.ReviewHeld).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&reviews).Error
+	return reviews, err
+}
+
+func (r *plagiarismReviewRepository) Release(review *model.PlagiarismReview, file *model.File, override *model.PlagiarismOverride) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the review first so two supervisors cannot both store it
+		result := tx.Model(&model.PlagiarismReview{}).
+			Where("id = ? AND status = ?", review.ID, model.ReviewHeld).
+			Update("status", model.ReviewOverridden)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReviewClosed
+		}
+
+		if err := tx.Create(file).Error; err != nil {
+			return versionTaken(tx, err)
+		}
+		if err := tx.Model(&model.PlagiarismReview{}).
+			Where("id = ?", review.ID).
+			Update("file_id", file.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.PlagiarismReport{}).
+			Where("review_id = ?", review.ID).
+			Update("file_id", file.ID).Error; err != nil {
+			return err
+		}
+
+		override.ReviewID = &review.ID
+		override.FileID = &file.ID
+		if err := tx.Create(override).Error; err != nil {
+			return err
+		}
+
+		review.Status = model.ReviewOverridden
+		review.FileID = &file.ID
+		return nil
+	})
+}
+
+func (r *plagiarismReviewRepository) OverrideFile(fileID uint, override *model.PlagiarismOverride) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the file first so two supervisors cannot both override it
+		result := tx.Model(&model.File{}).
+			Where("id = ? AND (plagiarism_status IS NULL OR plagiarism_status <> ?)", fileID, model.PlagiarismOverridden).
+			Update("plagiarism_status", model.PlagiarismOverridden)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFileOverridden
+		}
+
+		override.FileID = &fileID
+		return tx.Create(override).Error
+	})
+}
+
+func (r *plagiarismReviewRepository) FindOverrides(intake string, limit int) ([]model.PlagiarismOverride, error) {
+	var overrides []model.PlagiarismOverride
+	err := r.db.Where("intake = ?", intake).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&overrides).Error
+	return overrides, err
+}
+
+func (r *plagiarismReviewRepository) Clear(review *model.PlagiarismReview, override *model.PlagiarismOverride, expiresAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.PlagiarismReview{}).
+			Where("id = ? AND status = ?", review.ID, model.ReviewHeld).
+			Updates(map[string]interface{}{"status": model.ReviewCleared, "expires_at": expiresAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReviewClosed
+		}
+
+		override.ReviewID = &review.ID
+		if err := tx.Create(override).Error; err != nil {
+			return err
+		}
+
+		review.Status = model.ReviewCleared
+		review.ExpiresAt = &expiresAt
+		return nil
+	})
+}
+
+func (r *plagiarismReviewRepository) FindClearance(intake string, teamID string, docType string, sha256 string) (*model.PlagiarismReview, error) {
+	var review model.PlagiarismReview
+	err := r.db.
+		Where("intake = ? AND team_id = ? AND doc_type = ? AND checksum_sha256 = ?", intake, teamID, docType, sha256).
+		Where("status = ? AND expires_at > ?", model.ReviewCleared, time.Now()).
+		Order("id DESC").
+		First(&review).Error
+	return &review, err
+}
+
+func (r *plagiarismReviewRepository) UseClearance(reviewID uint, fileID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.PlagiarismReview{}).
+			Where("id = ? AND status = ?", reviewID, model.ReviewCleared).
+			Updates(map[string]interface{}{"status": model.ReviewOverridden, "file_id": fileID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Model(&model.PlagiarismReport{}).
+			Where("review_id = ?", reviewID).
+			Update("file_id", fileID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.PlagiarismOverride{}).
+			Where("review_id = ?", reviewID).
+			Update("file_id", fileID).Error
+	})
+}
+
+func (r *plagiarismReviewRepository) FindExpired(now time.Time, limit int) ([]model.PlagiarismReview, error) {
+	var reviews []model.PlagiarismReview
+	err := r.db.
+		Where("from_submission AND status IN ? AND expires_at < ?", []string{model.ReviewHeld, model.ReviewCleared}, now).
+		Order("expires_at, id").
+		Limit(limit).
+		Find(&reviews).Error
+	return reviews, err
+}
+
+func (r *plagiarismReviewRepository) Expire(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&model.PlagiarismReview{}).
+		Where("id = ? AND status IN ? AND expires_at < ?", id, []string{model.ReviewHeld, model.ReviewCleared}, now).
+		Update("status", model.ReviewExpired)
+	return result.RowsAffected == 1, result.Error
+}
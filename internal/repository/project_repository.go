@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectRepository interface {
+	// Save creates the team's project or replaces its title and abstract.
+	Save(project *model.Project) error
+	FindByTeamID(teamID uint) (*model.Project, error)
+	DeleteByTeamID(teamID uint) error
+}
+
+type projectRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectRepository(db *gorm.DB) ProjectRepository {
+	return &projectRepository{db: db}
+}
+
+func (r *projectRepository) Save(project *model.Project) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "abstract", "updated_at"}),
+	}).Create(project).Error
+}
+
+func (r *projectRepository) FindByTeamID(teamID uint) (*model.Project, error) {
+	var project model.Project
+	err := r.db.Where("team_id = ?", teamID).First(&project).Error
+	return &project, err
+}
+
+func (r *projectRepository) DeleteByTeamID(teamID uint) error {
+	return r.db.Where("team_id = ?", teamID).Delete(&model.Project{}).Error
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type RekeyRepository interface {
+	// FilesToPlan returns files after afterID that have no move for layout
+	// yet, in ID order.
+	FilesToPlan(layout string, afterID uint, limit int) ([]model.File, error)
+	CreateMoves(moves []model.RekeyMove) error
+	// Unfinished returns moves for layout that are not done or skipped,
+	// after the move with ID afterID.
+	Unfinished(layout string, afterID uint, limit int) ([]model.RekeyMove, error)
+	UpdateStatus(move *model.RekeyMove, status string, reason string) error
+	// SwitchKey points the file at the move's new key and marks the move
+	// switched in one transaction. It reports false when the file no longer
+	// has the old key.
+	SwitchKey(move *model.RekeyMove, downloadURL string) (bool, error)
+	Counts(layout string) (map[string]int64, error)
+}
+
+type rekeyRepository struct {
+	db *gorm.DB
+}
+
+func NewRekeyRepository(db *gorm.DB) RekeyRepository {
+	return &rekeyRepository{db: db}
+}
+
+func (r *rekeyRepository) FilesToPlan(layout string, afterID uint, limit int) ([]model.File, error) {
+	var files []model.File
+	err := r.db.
+		Where("id > ?", afterID).
+		Where("NOT EXISTS (SELECT 1 FROM rekey_moves m WHERE m.file_id = files.id AND m.layout = ?)", layout).
+		Order("id").
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
+
+func (r *rekeyRepository) CreateMoves(moves []model.RekeyMove) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	return r.db.Create(&moves).Error
+}
+
+func (r *rekeyRepository) Unfinished(layout string, afterID uint, limit int) ([]model.RekeyMove, error) {
+	var moves []model.RekeyMove
+	err := r.db.
+		Where("layout = ? AND id > ?", layout, afterID).
+		Where("status NOT IN ?", []string{model.RekeyDone, model.RekeySkipped}).
+		Order("id").
+		Limit(limit).
+		Find(&moves).Error
+	return moves, err
+}
+
+func (r *rekeyRepository) UpdateStatus(move *model.RekeyMove, status string, reason string) error {
+	err := r.db.Model(move).Updates(map[string]interface{}{
+		"status": status,
+		"error":  reason,
+	}).Error
+	if err == nil {
+		move.Status = status
+		move.Error = reason
+	}
+	return err
+}
+
+func (r *rekeyRepository) SwitchKey(move *model.RekeyMove, downloadURL string) (bool, error) {
+	switched := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.File{}).
+			Where("id = ? AND storage_key = ?", move.FileID, move.OldKey).
+			Updates(map[string]interface{}{
+				"storage_key":  move.NewKey,
+				"download_url": downloadURL,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		switched = result.RowsAffected == 1
+
+		status := model.RekeySwitched
+		if !switched {
+			status = model.RekeySkipped
+		}
+		return tx.Model(move).Update("status", status).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	move.Status = model.RekeySwitched
+	if !switched {
+		move.Status = model.RekeySkipped
+	}
+	return switched, nil
+}
+
+func (r *rekeyRepository) Counts(layout string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&model.RekeyMove{}).
+		Select("status, COUNT(*) AS count").
+		Where("layout = ?", layout).
+		Group("status").
+		Scan(&rows).Error
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, err
+}
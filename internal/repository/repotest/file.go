@@ -0,0 +1,449 @@
+// Package repotest holds the tests every repository implementation must
+// pass, so that implementations can stand in for each other. They are
+// called from the tests of each implementation:
+//
+//	func TestMemoryFileRepository(t *testing.T) {
+//		repotest.TestFileRepository(t, func(t *testing.T) repository.FileRepository {
+//			return repository.NewMemoryFileRepository()
+//		})
+//	}
+//
+// The database implementation runs them against SQLite, opened with
+// database.OpenSQLite(database.Memory) and set up with database.Prepare.
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// TestFileRepository tests a FileRepository implementation. newRepo must
+// return an empty repository each time it is called.
+func TestFileRepository(t *testing.T, newRepo func(t *testing.T) repository.FileRepository) {
+	tests := []struct {
+		name string
+		test func(*testing.T, repository.FileRepository)
+	}{
+		{"CreateAndFind", testCreateAndFind},
+		{"FindMissing", testFindMissing},
+		{"DeleteByKey", testDeleteByKey},
+		{"List", testList},
+		{"ListPages", testListPages},
+		{"ListErrors", testListErrors},
+		{"NextVersion", testNextVersion},
+		{"VersionTaken", testVersionTaken},
+		{"Verification", testVerification},
+		{"Plagiarism", testPlagiarism},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// base is the time files are created at, in whole microseconds as Postgres
+// keeps them.
+var base = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func create(t *testing.T, repo repository.FileRepository, file model.File) model.File {
+	t.Helper()
+	if file.StorageKey == "" {
+		file.StorageKey = fmt.Sprintf("uploads/%s/%s", file.TeamID, file.OriginalName)
+	}
+	if file.Version == 0 {
+		// Most tests store several files of the same team and doc_type
+		version, err := repo.NextVersion(file.TeamID, file.Intake, file.DocType)
+		if err != nil {
+			t.Fatalf("NextVersion: %v", err)
+		}
+		file.Version = version
+	}
+	if err := repo.Create(&file); err != nil {
+		t.Fatalf("Create(%q): %v", file.OriginalName, err)
+	}
+	if file.ID == 0 {
+		t.Fatalf("Create(%q) left the ID empty", file.OriginalName)
+	}
+	return file
+}
+
+func testCreateAndFind(t *testing.T, repo repository.FileRepository) {
+	file := create(t, repo, model.File{
+		OriginalName: "proposal.pdf",
+		Size:         2048,
+		TeamID:       "team-1",
+		Intake:       "45",
+		FileType:     "pdf",
+		DocType:      "proposal",
+		CreatedAt:    base,
+		Document:     &model.FileDocument{PageCount: 12, Title: "Proposal", Text: "a study"},
+	})
+	if file.Version != 1 {
+		t.Errorf("Version = %d, want the default 1", file.Version)
+	}
+	other := create(t, repo, model.File{OriginalName: "report.pdf", TeamID: "team-1", CreatedAt: base})
+	if other.ID == file.ID {
+		t.Fatalf("files share ID %d", file.ID)
+	}
+
+	found, err := repo.FindByID(file.ID)
+	if err != nil {
+		t.Fatalf("FindByID(%d): %v", file.ID, err)
+	}
+	if found.OriginalName != "proposal.pdf" || found.Size != 2048 || found.Version != 1 || !found.CreatedAt.Equal(base) {
+		t.Errorf("FindByID(%d) = %+v, want the created file", file.ID, found)
+	}
+	if found.Document == nil {
+		t.Fatalf("FindByID(%d) did not load the document", file.ID)
+	}
+	if found.Document.FileID != file.ID || found.Document.PageCount != 12 || found.Document.Text != "a study" {
+		t.Errorf("Document = %+v, want the created document", found.Document)
+	}
+
+	// Changing what was found must not change what is stored
+	found.OriginalName = "changed.pdf"
+	again, err := repo.FindByID(file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.OriginalName != "proposal.pdf" {
+		t.Errorf("OriginalName = %q after changing a found copy", again.OriginalName)
+	}
+}
+
+func testFindMissing(t *testing.T, repo repository.FileRepository) {
+	if _, err := repo.FindByID(404); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID(404) error = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func testDeleteByKey(t *testing.T, repo repository.FileRepository) {
+	file := create(t, repo, model.File{OriginalName: "a.pdf", StorageKey: "uploads/a", CreatedAt: base})
+	kept := create(t, repo, model.File{OriginalName: "b.pdf", StorageKey: "uploads/b", CreatedAt: base})
+
+	if err := repo.DeleteByKey(""); err == nil || err.Error() != "empty file key provided" {
+		t.Errorf(`DeleteByKey("") error = %v, want "empty file key provided"`, err)
+	}
+	if err := repo.DeleteByKey("uploads/a"); err != nil {
+		t.Fatalf("DeleteByKey: %v", err)
+	}
+	if _, err := repo.FindByID(file.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID after delete error = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if _, err := repo.FindByID(kept.ID); err != nil {
+		t.Errorf("FindByID of another file after delete: %v", err)
+	}
+
+	want := "no record found with key: uploads/a"
+	if err := repo.DeleteByKey("uploads/a"); err == nil || err.Error() != want {
+		t.Errorf("second DeleteByKey error = %v, want %q", err, want)
+	}
+}
+
+// listFixture stores files that differ in every sorted column, with ties.
+func listFixture(t *testing.T, repo repository.FileRepository) []model.File {
+	t.Helper()
+	files := []model.File{
+		{OriginalName: "c.pdf", Size: 300, TeamID: "team-1", Intake: "45", DocType: "proposal", FileType: "pdf", CreatedAt: base},
+		{OriginalName: "a.pdf", Size: 100, TeamID: "team-1", Intake: "45", DocType: "report", FileType: "pdf", CreatedAt: base.Add(time.Hour)},
+		{OriginalName: "b.zip", Size: 300, TeamID: "team-2", Intake: "45", DocType: "code", FileType: "zip", CreatedAt: base.Add(time.Hour)},
+		{OriginalName: "a.pdf", Size: 200, TeamID: "team-2", Intake: "46", DocType: "proposal", FileType: "pdf", CreatedAt: base.Add(2 * time.Hour), ScanStatus: "clean"},
+		{OriginalName: "d.pdf", Size: 50, TeamID: "team-3", Intake: "46", DocType: "report", FileType: "pdf", CreatedAt: base.Add(3 * time.Hour), Version: 2},
+	}
+	for i := range files {
+		files[i] = create(t, repo, files[i])
+	}
+	return files
+}
+
+func names(files []model.File) []string {
+	list := make([]string, len(files))
+	for i, f := range files {
+		list[i] = fmt.Sprintf("%s#%d", f.OriginalName, f.Size)
+	}
+	return list
+}
+
+func testList(t *testing.T, repo repository.FileRepository) {
+	listFixture(t, repo)
+	from, until := base.Add(time.Hour), base.Add(3*time.Hour)
+
+	tests := []struct {
+		name  string
+		query repository.FileQuery
+		want  string
+	}{
+		{"all by ID", repository.FileQuery{Sort: repository.FileSort{Column: "id"}},
+			"[c.pdf#300 a.pdf#100 b.zip#300 a.pdf#200 d.pdf#50]"},
+		{"newest first", repository.FileQuery{Sort: repository.FileSort{Column: "created_at", Desc: true}},
+			"[d.pdf#50 a.pdf#200 b.zip#300 a.pdf#100 c.pdf#300]"},
+		{"size ties by ID", repository.FileQuery{Sort: repository.FileSort{Column: "size"}},
+			"[d.pdf#50 a.pdf#100 a.pdf#200 c.pdf#300 b.zip#300]"},
+		{"size descending ties by ID", repository.FileQuery{Sort: repository.FileSort{Column: "size", Desc: true}},
+			"[b.zip#300 c.pdf#300 a.pdf#200 a.pdf#100 d.pdf#50]"},
+		{"name", repository.FileQuery{Sort: repository.FileSort{Column: "original_name"}},
+			"[a.pdf#100 a.pdf#200 b.zip#300 c.pdf#300 d.pdf#50]"},
+		{"version", repository.FileQuery{Sort: repository.FileSort{Column: "version", Desc: true}},
+			"[d.pdf#50 a.pdf#200 b.zip#300 a.pdf#100 c.pdf#300]"},
+		{"team", repository.FileQuery{TeamID: "team-2", Sort: repository.FileSort{Column: "id"}},
+			"[b.zip#300 a.pdf#200]"},
+		{"intake and type", repository.FileQuery{Intake: "45", FileType: "pdf", Sort: repository.FileSort{Column: "id"}},
+			"[c.pdf#300 a.pdf#100]"},
+		{"doc type", repository.FileQuery{DocType: "proposal", Sort: repository.FileSort{Column: "id"}},
+			"[c.pdf#300 a.pdf#200]"},
+		{"scan status", repository.FileQuery{ScanStatus: "clean", Sort: repository.FileSort{Column: "id"}},
+			"[a.pdf#200]"},
+		{"created range", repository.FileQuery{CreatedFrom: &from, CreatedUntil: &until, Sort: repository.FileSort{Column: "id"}},
+			"[a.pdf#100 b.zip#300 a.pdf#200]"},
+		{"no match", repository.FileQuery{TeamID: "team-9", Sort: repository.FileSort{Column: "id"}},
+			"[]"},
+	}
+	for _, tt := range tests {
+		tt.query.Limit = 10
+		tt.query.WithTotal = true
+		page, err := repo.List(tt.query)
+		if err != nil {
+			t.Errorf("%s: List: %v", tt.name, err)
+			continue
+		}
+		if got := fmt.Sprint(names(page.Files)); got != tt.want {
+			t.Errorf("%s: List = %s, want %s", tt.name, got, tt.want)
+		}
+		if page.Total != int64(len(page.Files)) {
+			t.Errorf("%s: Total = %d, want %d", tt.name, page.Total, len(page.Files))
+		}
+		if page.Next != nil {
+			t.Errorf("%s: Next = %+v on the only page", tt.name, page.Next)
+		}
+		for _, f := range page.Files {
+			if f.Document != nil {
+				t.Errorf("%s: listed file %d has its document", tt.name, f.ID)
+			}
+		}
+	}
+}
+
+func testListPages(t *testing.T, repo repository.FileRepository) {
+	listFixture(t, repo)
+
+	for _, sort := range repository.FileSortColumns() {
+		for _, desc := range []bool{false, true} {
+			query := repository.FileQuery{Sort: repository.FileSort{Column: sort, Desc: desc}, Limit: 5}
+			whole, err := repo.List(query)
+			if err != nil {
+				t.Fatalf("List sorted by %s: %v", sort, err)
+			}
+
+			var paged []model.File
+			query.Limit, query.WithTotal = 2, true
+			for pages := 1; ; pages++ {
+				page, err := repo.List(query)
+				if err != nil {
+					t.Fatalf("List sorted by %s, page %d: %v", sort, pages, err)
+				}
+				if page.Total != 5 {
+					t.Errorf("sorted by %s: Total = %d on page %d, want 5", sort, page.Total, pages)
+				}
+				paged = append(paged, page.Files...)
+				if page.Next == nil {
+					if pages != 3 {
+						t.Errorf("sorted by %s: %d pages of 2 files, want 3", sort, pages)
+					}
+					break
+				}
+				if pages == 3 {
+					t.Fatalf("sorted by %s: a next page after the last file", sort)
+				}
+				query.After = page.Next
+			}
+
+			if got, want := fmt.Sprint(ids(paged)), fmt.Sprint(ids(whole.Files)); got != want {
+				t.Errorf("sorted by %s (desc %t): pages hold %s, want %s", sort, desc, got, want)
+			}
+		}
+	}
+
+	// Without WithTotal nothing is counted
+	page, err := repo.List(repository.FileQuery{Sort: repository.FileSort{Column: "id"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Errorf("Total = %d when not asked for", page.Total)
+	}
+}
+
+func ids(files []model.File) []uint {
+	list := make([]uint, len(files))
+	for i, f := range files {
+		list[i] = f.ID
+	}
+	return list
+}
+
+func testListErrors(t *testing.T, repo repository.FileRepository) {
+	listFixture(t, repo)
+
+	queries := map[string]repository.FileQuery{
+		"unknown sort":   {Sort: repository.FileSort{Column: "storage_key"}, Limit: 10},
+		"no limit":       {Sort: repository.FileSort{Column: "id"}},
+		"invalid cursor": {Sort: repository.FileSort{Column: "size"}, Limit: 10, After: &repository.FileCursor{Value: "big", ID: 1}},
+		"invalid time":   {Sort: repository.FileSort{Column: "created_at"}, Limit: 10, After: &repository.FileCursor{Value: "yesterday", ID: 1}},
+	}
+	for name, query := range queries {
+		if _, err := repo.List(query); err == nil {
+			t.Errorf("%s: List succeeded", name)
+		}
+	}
+}
+
+func testNextVersion(t *testing.T, repo repository.FileRepository) {
+	next := func(team, intake, docType string) int {
+		t.Helper()
+		version, err := repo.NextVersion(team, intake, docType)
+		if err != nil {
+			t.Fatalf("NextVersion: %v", err)
+		}
+		return version
+	}
+
+	if v := next("team-1", "45", "proposal"); v != 1 {
+		t.Errorf("NextVersion with no files = %d, want 1", v)
+	}
+	create(t, repo, model.File{OriginalName: "v1.pdf", TeamID: "team-1", Intake: "45", DocType: "proposal", CreatedAt: base})
+	create(t, repo, model.File{OriginalName: "v3.pdf", TeamID: "team-1", Intake: "45", DocType: "proposal", Version: 3, CreatedAt: base})
+	create(t, repo, model.File{OriginalName: "r.pdf", TeamID: "team-1", Intake: "45", DocType: "report", Version: 7, CreatedAt: base})
+
+	if v := next("team-1", "45", "proposal"); v != 4 {
+		t.Errorf("NextVersion = %d, want 4", v)
+	}
+	if v := next("team-1", "46", "proposal"); v != 1 {
+		t.Errorf("NextVersion of another intake = %d, want 1", v)
+	}
+}
+
+func testVersionTaken(t *testing.T, repo repository.FileRepository) {
+	create(t, repo, model.File{OriginalName: "v1.pdf", TeamID: "team-1", Intake: "45", DocType: "proposal", CreatedAt: base})
+
+	again := model.File{OriginalName: "again.pdf", TeamID: "team-1", Intake: "45", DocType: "proposal", Version: 1, CreatedAt: base}
+	if err := repo.Create(&again); !errors.Is(err, repository.ErrVersionTaken) {
+		t.Errorf("Create with a taken version error = %v, want ErrVersionTaken", err)
+	}
+	create(t, repo, model.File{OriginalName: "other.pdf", TeamID: "team-2", Intake: "45", DocType: "proposal", Version: 1, CreatedAt: base})
+	if v, _ := repo.NextVersion("team-1", "45", "proposal"); v != 2 {
+		t.Errorf("NextVersion after a rejected file = %d, want 2", v)
+	}
+}
+
+func testVerification(t *testing.T, repo repository.FileRepository) {
+	verified := func(at time.Time) *time.Time { return &at }
+	never := create(t, repo, model.File{OriginalName: "never.pdf", ChecksumSHA256: "ab", CreatedAt: base})
+	stale := create(t, repo, model.File{OriginalName: "stale.pdf", ChecksumMD5: "cd", VerifiedAt: verified(base), CreatedAt: base})
+	create(t, repo, model.File{OriginalName: "fresh.pdf", ChecksumSHA256: "ef", VerifiedAt: verified(base.Add(48 * time.Hour)), CreatedAt: base})
+	create(t, repo, model.File{OriginalName: "unsummed.pdf", CreatedAt: base})
+	older := create(t, repo, model.File{OriginalName: "older.pdf", ChecksumSHA256: "gh", VerifiedAt: verified(base.Add(-time.Hour)), CreatedAt: base})
+
+	cutoff := base.Add(24 * time.Hour)
+	due, err := repo.FindDueForVerification(cutoff, 10)
+	if err != nil {
+		t.Fatalf("FindDueForVerification: %v", err)
+	}
+	if got, want := fmt.Sprint(ids(due)), fmt.Sprint([]uint{never.ID, older.ID, stale.ID}); got != want {
+		t.Errorf("FindDueForVerification = %s, want %s", got, want)
+	}
+	if due, _ := repo.FindDueForVerification(cutoff, 1); len(due) != 1 {
+		t.Errorf("FindDueForVerification with limit 1 returned %d files", len(due))
+	}
+
+	if err := repo.UpdateIntegrity(never.ID, model.IntegrityOK, base.Add(30*time.Hour)); err != nil {
+		t.Fatalf("UpdateIntegrity: %v", err)
+	}
+	if err := repo.UpdateIntegrity(404, model.IntegrityOK, base); err != nil {
+		t.Errorf("UpdateIntegrity of a missing file: %v", err)
+	}
+	found, err := repo.FindByID(never.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.IntegrityStatus != model.IntegrityOK || found.VerifiedAt == nil || !found.VerifiedAt.Equal(base.Add(30*time.Hour)) {
+		t.Errorf("after UpdateIntegrity: status %q, verified at %v", found.IntegrityStatus, found.VerifiedAt)
+	}
+	due, err = repo.FindDueForVerification(cutoff, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(ids(due)), fmt.Sprint([]uint{older.ID, stale.ID}); got != want {
+		t.Errorf("FindDueForVerification after verifying = %s, want %s", got, want)
+	}
+}
+
+func testPlagiarism(t *testing.T, repo repository.FileRepository) {
+	first := create(t, repo, model.File{OriginalName: "one.pdf", PlagiarismStatus: model.PlagiarismPending, CreatedAt: base})
+	create(t, repo, model.File{OriginalName: "checked.pdf", PlagiarismStatus: model.PlagiarismChecked, CreatedAt: base})
+	second := create(t, repo, model.File{OriginalName: "two.pdf", PlagiarismStatus: model.PlagiarismPending, CreatedAt: base})
+	third := create(t, repo, model.File{OriginalName: "three.pdf", PlagiarismStatus: model.PlagiarismPending, CreatedAt: base})
+
+	pending, err := repo.FindPendingPlagiarism(0, 2)
+	if err != nil {
+		t.Fatalf("FindPendingPlagiarism: %v", err)
+	}
+	if got, want := fmt.Sprint(ids(pending)), fmt.Sprint([]uint{first.ID, second.ID}); got != want {
+		t.Errorf("FindPendingPlagiarism(0, 2) = %s, want %s", got, want)
+	}
+	pending, err = repo.FindPendingPlagiarism(second.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(ids(pending)), fmt.Sprint([]uint{third.ID}); got != want {
+		t.Errorf("FindPendingPlagiarism after %d = %s, want %s", second.ID, got, want)
+	}
+
+	score := 12.5
+	checkedAt := base.Add(time.Hour)
+	report := &model.PlagiarismReport{
+		FileID:    &first.ID,
+		Status:    model.PlagiarismChecked,
+		Provider:  "copyleaks",
+		Score:     &score,
+		CheckedAt: checkedAt,
+	}
+	recorded, err := repo.RecordPlagiarism(report)
+	if err != nil || !recorded {
+		t.Fatalf("RecordPlagiarism = %t, %v; want true", recorded, err)
+	}
+	if report.ID == 0 {
+		t.Error("RecordPlagiarism did not store the report")
+	}
+	found, err := repo.FindByID(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.PlagiarismStatus != model.PlagiarismChecked || found.PlagiarismProvider != "copyleaks" ||
+		found.PlagiarismPercent == nil || *found.PlagiarismPercent != score ||
+		found.PlagiarismCheckedAt == nil || !found.PlagiarismCheckedAt.Equal(checkedAt) {
+		t.Errorf("after RecordPlagiarism: %+v", found)
+	}
+
+	// A file is recorded once; missing files and reports without one are not
+	for name, fileID := range map[string]*uint{"again": &first.ID, "missing": ptr(uint(404)), "no file": nil} {
+		recorded, err := repo.RecordPlagiarism(&model.PlagiarismReport{
+			FileID:    fileID,
+			Status:    model.PlagiarismChecked,
+			Score:     &score,
+			CheckedAt: checkedAt,
+		})
+		if err != nil || recorded {
+			t.Errorf("RecordPlagiarism %s = %t, %v; want false", name, recorded, err)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
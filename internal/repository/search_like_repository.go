@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// snippetBytes is how much text around the first match a plain search
+// snippet shows.
+const snippetBytes = 240
+
+// likeSearchRepository searches by substring, for databases without
+// Postgres' full-text search. Words are matched anywhere in a file's name,
+// team, project title and text, case-insensitively for ASCII. Quotes and
+// "or" are ignored, -word still excludes. It reads the tables directly, so
+// there is nothing to index.
+type likeSearchRepository struct {
+	db *gorm.DB
+}
+
+// haystack is the text a plain search looks in.
+const haystack = `(COALESCE(f.original_name, '') || ' ' || COALESCE(f.team_id, '') || ' ' ||
+	COALESCE(t.name, '') || ' ' || COALESCE(p.title, '') || ' ' || COALESCE(d.title, '') || ' ' ||
+	COALESCE(f.intake, '') || ' ' || COALESCE(f.academic_year, '') || ' ' || COALESCE(f.doc_type, '') || ' ' ||
+	COALESCE(d.text, ''))`
+
+func (r *likeSearchRepository) Search(query SearchQuery) (*SearchResults, error) {
+	words, excluded := likeWords(query.Text)
+	matches := []string{MatchAll}
+	if len(words) > 1 {
+		matches = append(matches, MatchAny)
+	}
+
+	for _, match := range matches {
+		matching := func(skip string) *gorm.DB {
+			return r.matching(query, words, excluded, match, skip)
+		}
+		results := &SearchResults{Match: match}
+		if err := matching("").Count(&results.Total).Error; err != nil {
+			return nil, err
+		}
+		if results.Total == 0 {
+			continue
+		}
+
+		hits, err := r.hits(matching(""), words, query)
+		if err != nil {
+			return nil, err
+		}
+		results.Hits = hits
+		results.Facets, err = countFacets(matching)
+		if err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	return &SearchResults{Match: MatchAll, Hits: []SearchHit{}, Facets: map[string][]FacetCount{}}, nil
+}
+
+func (r *likeSearchRepository) matching(query SearchQuery, words, excluded []string, match string, skip string) *gorm.DB {
+	db := r.db.Table("files AS f").
+		Joins("LEFT JOIN file_documents d ON d.file_id = f.id").
+		Joins("LEFT JOIN teams t ON t.id = f.team_ref_id").
+		Joins("LEFT JOIN projects p ON p.team_id = f.team_ref_id")
+
+	var anyOf []string
+	var args []interface{}
+	for _, w := range words {
+		if match == MatchAll {
+			db = db.Where(haystack+` LIKE ? ESCAPE '\'`, likePattern(w))
+			continue
+		}
+		anyOf = append(anyOf, haystack+` LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(w))
+	}
+	if len(anyOf) > 0 {
+		db = db.Where(strings.Join(anyOf, " OR "), args...)
+	}
+	for _, w := range excluded {
+		db = db.Where(haystack+` NOT LIKE ? ESCAPE '\'`, likePattern(w))
+	}
+
+	for _, f := range query.filters() {
+		if f.value != "" && f.column != skip {
+			db = db.Where("f."+f.column+" = ?", f.value)
+		}
+	}
+	return db
+}
+
+// hits ranks files by the share of words they contain, newest first among
+// equals.
+func (r *likeSearchRepository) hits(matching *gorm.DB, words []string, query SearchQuery) ([]SearchHit, error) {
+	rank := "0"
+	var args []interface{}
+	if len(words) > 0 {
+		found := make([]string, len(words))
+		for i, w := range words {
+			found[i] = `(CASE WHEN ` + haystack + ` LIKE ? ESCAPE '\' THEN 1.0 ELSE 0 END)`
+			args = append(args, likePattern(w))
+		}
+		rank = "(" + strings.Join(found, " + ") + ") / ?"
+		args = append(args, float64(len(words)))
+	}
+
+	var rows []struct {
+		FileID       uint
+		ProjectTitle string
+		Rank         float64
+		Text         string
+		Label        string
+	}
+	err := matching.
+		Select(`f.id AS file_id, COALESCE(p.title, '') AS project_title,
+			COALESCE(d.text, '') AS text, COALESCE(f.original_name, '') AS label,
+			`+rank+` AS rank`, args...).
+		Order("rank DESC, f.created_at DESC, f.id DESC").
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return []SearchHit{}, err
+	}
+
+	ranked := make([]rankedHit, len(rows))
+	for i, row := range rows {
+		text := row.Text
+		if text == "" {
+			text = row.Label
+		}
+		ranked[i] = rankedHit{
+			FileID:       row.FileID,
+			ProjectTitle: row.ProjectTitle,
+			Rank:         row.Rank,
+			Snippet:      likeSnippet(text, words),
+		}
+	}
+	return withFiles(r.db, ranked)
+}
+
+func (r *likeSearchRepository) Index(fileIDs ...uint) error           { return nil }
+func (r *likeSearchRepository) IndexTeam(intake, teamID string) error { return nil }
+func (r *likeSearchRepository) IndexMissing(int) (int64, error)       { return 0, nil }
+
+// likeWords splits a search into the words to find and the words to
+// exclude.
+func likeWords(text string) (words, excluded []string) {
+	for _, w := range strings.Fields(strings.ReplaceAll(text, `"`, " ")) {
+		switch {
+		case strings.EqualFold(w, "or"):
+		case strings.HasPrefix(w, "-"):
+			if w = strings.TrimPrefix(w, "-"); w != "" {
+				excluded = append(excluded, w)
+			}
+		default:
+			words = append(words, w)
+		}
+	}
+	return words, excluded
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func likePattern(word string) string {
+	return "%" + likeEscaper.Replace(word) + "%"
+}
+
+// likeSnippet cuts text around the first word found in it and marks every
+// word in the cut.
+func likeSnippet(text string, words []string) string {
+	lower := asciiLower(text)
+	first := -1
+	for _, w := range words {
+		if i := strings.Index(lower, asciiLower(w)); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	first = max(first, 0)
+
+	// Start a little before the match, after a space when there is one
+	start := max(0, first-snippetBytes/2)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	if space := strings.IndexByte(text[start:first], ' '); start > 0 && space >= 0 {
+		start += space + 1
+	}
+	end := min(len(text), start+snippetBytes)
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	var marked strings.Builder
+	cut, lowerCut := text[start:end], lower[start:end]
+	for i := 0; i < len(cut); {
+		matched := 0
+		for _, w := range words {
+			if strings.HasPrefix(lowerCut[i:], asciiLower(w)) {
+				matched = max(matched, len(w))
+			}
+		}
+		if matched == 0 {
+			marked.WriteByte(cut[i])
+			i++
+			continue
+		}
+		marked.WriteString(SnippetStart + cut[i:i+matched] + SnippetStop)
+		i += matched
+	}
+	return marked.String()
+}
+
+// asciiLower lowers ASCII letters only, as LIKE does, keeping byte offsets.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
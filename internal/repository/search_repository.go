@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Matches say how a search found its results. Every word has to match
+// first; when nothing does, any word may, and when still nothing does,
+// names, teams and project titles are compared by trigram similarity.
+const (
+	MatchAll   = "all"
+	MatchAny   = "any"
+	MatchFuzzy = "fuzzy"
+)
+
+// SearchFacets are the fields search results are counted by.
+var SearchFacets = []string{"academic_year", "intake", "doc_type", "file_type"}
+
+// maxIndexedText caps the extracted text indexed per file, which keeps the
+// tsvector well under Postgres' 1 MB limit.
+const maxIndexedText = 256 << 10
+
+// Snippets mark matched words with these characters, which do not occur in
+// extracted text, for the caller to replace.
+const (
+	SnippetStart = "\uE000"
+	SnippetStop  = "\uE001"
+)
+
+// SearchQuery is a web-style search, as in `"voting system" blockchain
+// -survey`, with filters on the files it looks at. Empty filters match
+// every file.
+type SearchQuery struct {
+	Text         string
+	AcademicYear string
+	Intake       string
+	DocType      string
+	FileType     string
+	TeamID       string
+	Limit        int
+	Offset       int
+}
+
+func (q SearchQuery) filters() []struct{ column, value string } {
+	return []struct{ column, value string }{
+		{"academic_year", q.AcademicYear},
+		{"intake", q.Intake},
+		{"doc_type", q.DocType},
+		{"file_type", q.FileType},
+		{"team_id", q.TeamID},
+	}
+}
+
+// SearchHit is a file a search found, with its rank and a snippet of its
+// text around the matched words.
+type SearchHit struct {
+	File         model.File
+	ProjectTitle string
+	Rank         float64
+	Snippet      string
+}
+
+// FacetCount is how many results have a value of a facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SearchResults is one page of a search. Facets count every result,
+// leaving out the facet's own filter so the other values stay visible.
+type SearchResults struct {
+	Match  string
+	Total  int64
+	Hits   []SearchHit
+	Facets map[string][]FacetCount
+}
+
+type SearchRepository interface {
+	Search(query SearchQuery) (*SearchResults, error)
+	// Index adds files to the search index or refreshes their entries.
+	Index(fileIDs ...uint) error
+	// IndexTeam refreshes the entries of a team's files, as after the team
+	// or its project is renamed.
+	IndexTeam(intake string, teamID string) error
+	// IndexMissing indexes the files that are not in the index yet,
+	// batchSize at a time, and returns how many it added.
+	IndexMissing(batchSize int) (int64, error)
+}
+
+type searchRepository struct {
+	db *gorm.DB
+}
+
+// NewSearchRepository returns a search over Postgres' full-text index, or
+// a plain substring search without an index on SQLite.
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	if db.Dialector.Name() == "sqlite" {
+		return &likeSearchRepository{db: db}
+	}
+	return &searchRepository{db: db}
+}
+
+// indexFiles writes the search entries of the files matching the WHERE
+// clause it is formatted with.
+const indexFiles = `
+INSERT INTO file_search (file_id, original_name, title, team, project_title, placement, body, indexed_at)
+SELECT f.id,
+	COALESCE(f.original_name, ''),
+	COALESCE(d.title, ''),
+	CONCAT_WS(' ', f.team_id, t.name),
+	COALESCE(p.title, ''),
+	CONCAT_WS(' ', f.intake, REPLACE(f.academic_year, '-', ' '), f.session, REPLACE(f.doc_type, '_', ' ')),
+	LEFT(COALESCE(d.text, ''), ?),
+	?
+FROM files f
+LEFT JOIN file_documents d ON d.file_id = f.id
+LEFT JOIN teams t ON t.id = f.team_ref_id
+LEFT JOIN projects p ON p.team_id = f.team_ref_id
+WHERE %s
+ON CONFLICT (file_id) DO UPDATE SET
+	original_name = EXCLUDED.original_name,
+	title = EXCLUDED.title,
+	team = EXCLUDED.team,
+	project_title = EXCLUDED.project_title,
+	placement = EXCLUDED.placement,
+	body = EXCLUDED.body,
+	indexed_at = EXCLUDED.indexed_at`
+
+func (r *searchRepository) index(where string, args ...interface{}) *gorm.DB {
+	return r.db.Exec(fmt.Sprintf(indexFiles, where),
+		append([]interface{}{maxIndexedText, time.Now()}, args...)...)
+}
+
+func (r *searchRepository) Index(fileIDs ...uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return r.index("f.id IN ?", fileIDs).Error
+}
+
+func (r *searchRepository) IndexTeam(intake string, teamID string) error {
+	return r.index("LOWER(f.intake) = LOWER(?) AND LOWER(f.team_id) = LOWER(?)", intake, teamID).Error
+}
+
+func (r *searchRepository) IndexMissing(batchSize int) (int64, error) {
+	var indexed int64
+	for {
+		result := r.index(`f.id IN (
+			SELECT m.id FROM files m
+			WHERE NOT EXISTS (SELECT 1 FROM file_search s WHERE s.file_id = m.id)
+			ORDER BY m.id LIMIT ?)`, batchSize)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return indexed, result.Error
+		}
+		indexed += result.RowsAffected
+	}
+}
+
+// searchMatch is how one kind of match selects and ranks entries.
+type searchMatch struct {
+	name  string
+	where string
+	rank  string
+	arg   string
+}
+
+func (r *searchRepository) Search(query SearchQuery) (*SearchResults, error) {
+	matches := []searchMatch{{
+		name:  MatchAll,
+		where: "s.document @@ websearch_to_tsquery('english', ?)",
+		rank:  "ts_rank_cd(s.document, websearch_to_tsquery('english', ?), 32)",
+		arg:   query.Text,
+	}}
+	anyWord := anyWords(query.Text)
+	if anyWord != "" {
+		matches = append(matches, searchMatch{
+			name:  MatchAny,
+			where: "s.document @@ websearch_to_tsquery('english', ?)",
+			rank:  "ts_rank_cd(s.document, websearch_to_tsquery('english', ?), 32)",
+			arg:   anyWord,
+		})
+	}
+	matches = append(matches, searchMatch{
+		name:  MatchFuzzy,
+		where: "? <% s.label",
+		rank:  "word_similarity(?, s.label)",
+		arg:   query.Text,
+	})
+
+	for _, match := range matches {
+		results := &SearchResults{Match: match.name}
+		err := r.matching(query, match, "").Count(&results.Total).Error
+		if err != nil {
+			return nil, err
+		}
+		if results.Total == 0 {
+			continue
+		}
+
+		// Snippets highlight any of the words, whichever way the file matched
+		highlight := query.Text
+		if anyWord != "" {
+			highlight = anyWord
+		}
+		results.Hits, err = r.hits(query, match, highlight)
+		if err != nil {
+			return nil, err
+		}
+		results.Facets, err = countFacets(func(skip string) *gorm.DB {
+			return r.matching(query, match, skip)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	return &SearchResults{Match: MatchAll, Hits: []SearchHit{}, Facets: map[string][]FacetCount{}}, nil
+}
+
+// matching selects the entries found by match, filtered by every filter of
+// query except the one on skip.
+func (r *searchRepository) matching(query SearchQuery, match searchMatch, skip string) *gorm.DB {
+	db := r.db.Table("file_search AS s").
+		Joins("JOIN files f ON f.id = s.file_id").
+		Where(match.where, match.arg)
+	for _, f := range query.filters() {
+		if f.value != "" && f.column != skip {
+			db = db.Where("f."+f.column+" = ?", f.value)
+		}
+	}
+	return db
+}
+
+func (r *searchRepository) hits(query SearchQuery, match searchMatch, highlight string) ([]SearchHit, error) {
+	var ranked []rankedHit
+	// Snippets are only made for the page, they take long on large texts
+	page := r.matching(query, match, "").
+		Select("s.file_id, s.project_title, s.body, s.label, "+match.rank+" AS rank", match.arg).
+		Order("rank DESC, s.file_id DESC").
+		Limit(query.Limit).
+		Offset(query.Offset)
+	options := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`,
+		SnippetStart, SnippetStop)
+	err := r.db.Table("(?) AS hits", page).
+		Select(`file_id, project_title, rank,
+			ts_headline('english', CASE WHEN body <> '' THEN body ELSE label END,
+				websearch_to_tsquery('english', ?), ?) AS snippet`, highlight, options).
+		Order("rank DESC, file_id DESC").
+		Scan(&ranked).Error
+	if err != nil || len(ranked) == 0 {
+		return []SearchHit{}, err
+	}
+
+	return withFiles(r.db, ranked)
+}
+
+// rankedHit is a search hit before its file is loaded.
+type rankedHit struct {
+	FileID       uint
+	ProjectTitle string
+	Rank         float64
+	Snippet      string
+}
+
+// withFiles loads the files of ranked hits, keeping their order.
+func withFiles(db *gorm.DB, ranked []rankedHit) ([]SearchHit, error) {
+	ids := make([]uint, len(ranked))
+	for i, h := range ranked {
+		ids[i] = h.FileID
+	}
+	var files []model.File
+	if err := db.Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+
+	hits := make([]SearchHit, 0, len(ranked))
+	for _, h := range ranked {
+		file, ok := byID[h.FileID]
+		if !ok {
+			// Deleted since the search ran
+			continue
+		}
+		hits = append(hits, SearchHit{File: file, ProjectTitle: h.ProjectTitle, Rank: h.Rank, Snippet: h.Snippet})
+	}
+	return hits, nil
+}
+
+// countFacets counts the results of a search by each facet. matching
+// selects the results, leaving out the filter on the facet it is given.
+func countFacets(matching func(skip string) *gorm.DB) (map[string][]FacetCount, error) {
+	facets := make(map[string][]FacetCount, len(SearchFacets))
+	for _, facet := range SearchFacets {
+		counts := []FacetCount{}
+		err := matching(facet).
+			Select("COALESCE(f." + facet + ", '') AS value, COUNT(*) AS count").
+			Group("value").
+			Order("count DESC, value").
+			Scan(&counts).Error
+		if err != nil {
+			return nil, err
+		}
+		facets[facet] = counts
+	}
+	return facets, nil
+}
+
+// anyWords rewrites a search of plain words into one matching any of them.
+// It returns "" when there is nothing to rewrite: a single word, or a
+// search with quotes, exclusions or its own "or".
+func anyWords(text string) string {
+	words := strings.Fields(text)
+	if len(words) < 2 || strings.Contains(text, `"`) {
+		return ""
+	}
+	for _, w := range words {
+		if strings.HasPrefix(w, "-") || strings.EqualFold(w, "or") {
+			return ""
+		}
+	}
+	return strings.Join(words, " or ")
+}
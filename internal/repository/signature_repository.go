@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// SimilarityCandidate is a stored signature along with the file it
+// belongs to.
+type SimilarityCandidate struct {
+	FileID       uint   `json:"file_id"`
+	OriginalName string `json:"original_name"`
+	TeamID       string `json:"team_id"`
+	Intake       string `json:"intake"`
+	AcademicYear string `json:"academic_year"`
+	DocType      string `json:"doc_type"`
+	ShingleCount int    `json:"-"`
+	MinHash      []byte `json:"-"`
+	SharedBands  int    `json:"-"`
+}
+
+type SignatureRepository interface {
+	Create(signature *model.DocumentSignature) error
+	// Candidates returns signatures sharing at least one band key, most
+	// shared bands first, leaving out the files of teamID in intake.
+	Candidates(keys []int64, teamID string, intake string, limit int) ([]SimilarityCandidate, error)
+	// Texts returns the extracted text of each file by file ID.
+	Texts(fileIDs []uint) (map[uint]string, error)
+	// Unsigned returns documents after afterID that have text but no
+	// signature, in ID order.
+	Unsigned(afterID uint, limit int) ([]model.FileDocument, error)
+}
+
+type signatureRepository struct {
+	db *gorm.DB
+}
+
+func NewSignatureRepository(db *gorm.DB) SignatureRepository {
+	return &signatureRepository{db: db}
+}
+
+func (r *signatureRepository) Create(signature *model.DocumentSignature) error {
+	return r.db.Create(signature).Error
+}
+
+func (r *signatureRepository) Candidates(keys []int64, teamID string, intake string, limit int) ([]SimilarityCandidate, error) {
+	var candidates []SimilarityCandidate
+	if len(keys) == 0 {
+		return candidates, nil
+	}
+
+	err := r.db.Table("similarity_bands AS b").
+		Select("s.file_id, f.original_name, f.team_id, f.intake, f.academic_year, f.doc_type, "+
+			"s.shingle_count, s.min_hash, COUNT(*) AS shared_bands").
+		Joins("JOIN document_signatures s ON s.id = b.signature_id").
+		Joins("JOIN files f ON f.id = s.file_id").
+		Where("b.key IN ?", keys).
+		Where("NOT (f.team_id = ? AND f.intake = ?)", teamID, intake).
+		Group("s.id, f.id").
+		Order("shared_bands DESC, s.file_id").
+		Limit(limit).
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+func (r *signatureRepository) Texts(fileIDs []uint) (map[uint]string, error) {
+	texts := make(map[uint]string, len(fileIDs))
+	if len(fileIDs) == 0 {
+		return texts, nil
+	}
+
+	var docs []model.FileDocument
+	err := r.db.Select("file_id, text").Where("file_id IN ?", fileIDs).Find(&docs).Error
+	for _, doc := range docs {
+		texts[doc.FileID] = doc.Text
+	}
+	return texts, err
+}
+
+func (r *signatureRepository) Unsigned(afterID uint, limit int) ([]model.FileDocument, error) {
+	var docs []model.FileDocument
+	err := r.db.
+		Where("id > ? AND text <> ''", afterID).
+		Where("NOT EXISTS (SELECT 1 FROM document_signatures s WHERE s.file_id = file_documents.file_id)").
+		Order("id").
+		Limit(limit).
+		Find(&docs).Error
+	return docs, err
+}
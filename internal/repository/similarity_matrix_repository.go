@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type SimilarityMatrixRepository interface {
+	Create(matrix *model.SimilarityMatrix) error
+	Update(matrix *model.SimilarityMatrix) error
+	FindByID(id uint) (*model.SimilarityMatrix, error)
+	// FindByIntake returns the matrices of an intake, newest first, without
+	// their results.
+	FindByIntake(intake string, limit int) ([]model.SimilarityMatrix, error)
+	// FindAbandoned returns the unfinished matrices whose lease has expired.
+	FindAbandoned(now time.Time) ([]model.SimilarityMatrix, error)
+	// Claim, RenewLeases and ReleaseLeases lease matrices to the instance
+	// computing them, as for upload jobs.
+	Claim(id uint, worker string, until time.Time) (bool, error)
+	RenewLeases(worker string, ids []uint, until time.Time) error
+	ReleaseLeases(worker string) error
+	// LatestFiles returns the newest version of each team's files of the
+	// given document types in an intake.
+	LatestFiles(intake string, docTypes []string) ([]model.File, error)
+}
+
+type similarityMatrixRepository struct {
+	db *gorm.DB
+}
+
+func NewSimilarityMatrixRepository(db *gorm.DB) SimilarityMatrixRepository {
+	return &similarityMatrixRepository{db: db}
+}
+
+func (r *similarityMatrixRepository) Create(matrix *model.SimilarityMatrix) error {
+	return r.db.Create(matrix).Error
+}
+
+func (r *similarityMatrixRepository) Update(matrix *model.SimilarityMatrix) error {
+	return r.db.Omit("WorkerID", "LeaseExpiresAt").Save(matrix).Error
+}
+
+func (r *similarityMatrixRepository) FindByID(id uint) (*model.SimilarityMatrix, error) {
+	var matrix model.SimilarityMatrix
+	err := r.db.First(&matrix, id).Error
+	return &matrix, err
+}
+
+func (r *similarityMatrixRepository) FindByIntake(intake string, limit int) ([]model.SimilarityMatrix, error) {
+	var matrices []model.SimilarityMatrix
+	err := r.db.Omit("result").
+		Where("intake = ?", intake).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&matrices).Error
+	return matrices, err
+}
+
+func (r *similarityMatrixRepository) FindAbandoned(now time.Time) ([]model.SimilarityMatrix, error) {
+	var matrices []model.SimilarityMatrix
+	err := r.db.Where("status IN ?", unfinishedJobs).
+		Where("lease_expires_at IS NULL OR lease_expires_at < ?", now).
+		Order("id").
+		Find(&matrices).Error
+	return matrices, err
+}
+
+func (r *similarityMatrixRepository) Claim(id uint, worker string, until time.Time) (bool, error) {
+	result := r.db.Model(&model.SimilarityMatrix{}).
+		Where("id = ? AND status IN ?", id, unfinishedJobs).
+		Where("worker_id = ? OR lease_expires_at IS NULL OR lease_expires_at < ?", worker, time.Now()).
+		Updates(map[string]interface{}{"worker_id": worker, "lease_expires_at": until})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *similarityMatrixRepository) RenewLeases(worker string, ids []uint, until time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.SimilarityMatrix{}).
+		Where("id IN ? AND worker_id = ?", ids, worker).
+		Update("lease_expires_at", until).Error
+}
+
+func (r *similarityMatrixRepository) ReleaseLeases(worker string) error {
+	return r.db.Model(&model.SimilarityMatrix{}).
+		Where("worker_id = ? AND status IN ?", worker, unfinishedJobs).
+		Update("lease_expires_at", nil).Error
+}
+
+func (r *similarityMatrixRepository) LatestFiles(intake string, docTypes []string) ([]model.File, error) {
+	var files []model.File
+	err := r.db.Where("intake = ? AND doc_type IN ?", intake, docTypes).
+		Where("version = (SELECT MAX(g.version) FROM files g " +
+			"WHERE g.team_id = files.team_id AND g.intake = files.intake AND g.doc_type = files.doc_type)").
+		Order("team_id, doc_type, id").
+		Find(&files).Error
+	return files, err
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type SubmissionRepository interface {
+	Create(submission *model.Submission) error
+	FindByID(id uint) (*model.Submission, error)
+}
+
+type submissionRepository struct {
+	db *gorm.DB
+}
+
+func NewSubmissionRepository(db *gorm.DB) SubmissionRepository {
+	return &submissionRepository{db: db}
+}
+
+// Create inserts the submission together with its files and their extracted
+// documents in a single transaction, so either all of them are saved or none.
+// It returns ErrVersionTaken when any file's version was taken.
+func (r *submissionRepository) Create(submission *model.Submission) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(submission).Error
+	})
+	return versionTaken(r.db, err)
+}
+
+func (r *submissionRepository) FindByID(id uint) (*model.Submission, error) {
+	var submission model.Submission
+	err := r.db.Preload("Files").First(&submission, id).Error
+	return &submission, err
+}
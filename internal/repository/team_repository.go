@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TeamRepository interface {
+	// Create inserts the team with its project, if it has one, and links
+	// the files already uploaded for it.
+	Create(team *model.Team) error
+	Update(team *model.Team) error
+	// Delete removes the team with its members and project. Its files stay,
+	// without the link to the team.
+	Delete(id uint) error
+	// Find looks a team up by its intake's name and its code, ignoring case,
+	// without its project and members.
+	Find(intake string, code string) (*model.Team, error)
+	// FindByIntake returns the teams of an intake with their projects, by
+	// code.
+	FindByIntake(intakeID uint) ([]model.Team, error)
+}
+
+type teamRepository struct {
+	db *gorm.DB
+}
+
+func NewTeamRepository(db *gorm.DB) TeamRepository {
+	return &teamRepository{db: db}
+}
+
+func (r *teamRepository) Create(team *model.Team) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Members").Create(team).Error; err != nil {
+			return err
+		}
+
+		var intake model.Intake
+		if err := tx.Select("id", "name").First(&intake, team.IntakeID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.File{}).
+			Where("LOWER(intake) = LOWER(?) AND LOWER(team_id) = LOWER(?) AND team_ref_id IS NULL", intake.Name, team.Code).
+			Updates(map[string]interface{}{"intake_ref_id": intake.ID, "team_ref_id": team.ID}).Error
+	})
+}
+
+func (r *teamRepository) Update(team *model.Team) error {
+	return r.db.Omit(clause.Associations).Save(team).Error
+}
+
+func (r *teamRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Team{}, id).Error
+}
+
+func (r *teamRepository) Find(intake string, code string) (*model.Team, error) {
+	var team model.Team
+	err := r.db.Joins("JOIN intakes ON intakes.id = teams.intake_id").
+		Where("LOWER(intakes.name) = LOWER(?) AND LOWER(teams.code) = LOWER(?)", intake, code).
+		First(&team).Error
+	return &team, err
+}
+
+func (r *teamRepository) FindByIntake(intakeID uint) ([]model.Team, error) {
+	var teams []model.Team
+	err := r.db.Preload("Project").
+		Where("intake_id = ?", intakeID).
+		Order("code").
+		Find(&teams).Error
+	return teams, err
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// TopicDocument is a stored topic vector along with the file it belongs to.
+type TopicDocument struct {
+	FileID       uint           `json:"file_id"`
+	OriginalName string         `json:"original_name"`
+	Title        string         `json:"title,omitempty"`
+	TeamID       string         `json:"team_id"`
+	Intake       string         `json:"intake"`
+	AcademicYear string         `json:"academic_year"`
+	DocType      string         `json:"doc_type"`
+	Terms        map[string]int `json:"-" gorm:"serializer:json"`
+}
+
+type TopicRepository interface {
+	Create(vector *model.TopicVector) error
+	// Corpus returns the topic vectors of files of docTypes, leaving out the
+	// files of teamID in intake.
+	Corpus(docTypes []string, teamID string, intake string) ([]TopicDocument, error)
+	// Unindexed returns documents of docTypes after afterID that have text
+	// but no topic vector, in ID order.
+	Unindexed(docTypes []string, afterID uint, limit int) ([]model.FileDocument, error)
+}
+
+type topicRepository struct {
+	db *gorm.DB
+}
+
+func NewTopicRepository(db *gorm.DB) TopicRepository {
+	return &topicRepository{db: db}
+}
+
+func (r *topicRepository) Create(vector *model.TopicVector) error {
+	return r.db.Create(vector).Error
+}
+
+func (r *topicRepository) Corpus(docTypes []string, teamID string, intake string) ([]TopicDocument, error) {
+	var docs []TopicDocument
+	err := r.db.Table("topic_vectors AS tv").
+		Select("f.id AS file_id, f.original_name, d.title, f.team_id, f.intake, f.academic_year, f.doc_type, tv.terms").
+		Joins("JOIN files f ON f.id = tv.file_id").
+		Joins("LEFT JOIN file_documents d ON d.file_id = f.id").
+		Where("f.doc_type IN ?", docTypes).
+		Where("NOT (f.team_id = ? AND f.intake = ?)", teamID, intake).
+		Order("f.id").
+		Scan(&docs).Error
+	return docs, err
+}
+
+func (r *topicRepository) Unindexed(docTypes []string, afterID uint, limit int) ([]model.FileDocument, error) {
+	var docs []model.FileDocument
+	err := r.db.
+		Joins("JOIN files f ON f.id = file_documents.file_id").
+		Where("file_documents.id > ? AND file_documents.text <> ''", afterID).
+		Where("f.doc_type IN ?", docTypes).
+		Where("NOT EXISTS (SELECT 1 FROM topic_vectors tv WHERE tv.file_id = file_documents.file_id)").
+		Order("file_documents.id").
+		Limit(limit).
+		Find(&docs).Error
+	return docs, err
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type UploadJobRepository interface {
+	Create(job *model.UploadJob) error
+	// Update saves the job's progress; its lease is left alone.
+	Update(job *model.UploadJob) error
+	FindByID(id string) (*model.UploadJob, error)
+	// FindAbandoned returns the unfinished jobs whose lease has expired.
+	FindAbandoned(now time.Time) ([]model.UploadJob, error)
+	// Claim leases an unfinished job to worker until the given time. It
+	// fails when another worker holds an unexpired lease on the job.
+	Claim(id string, worker string, until time.Time) (bool, error)
+	// RenewLeases extends worker's leases on the given jobs.
+	RenewLeases(worker string, ids []string, until time.Time) error
+	// ReleaseLeases expires the leases of worker's unfinished jobs, left by
+	// an earlier run of the same worker.
+	ReleaseLeases(worker string) error
+}
+
+type uploadJobRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadJobRepository(db *gorm.DB) UploadJobRepository {
+	return &uploadJobRepository{db: db}
+}
+
+func (r *uploadJobRepository) Create(job *model.UploadJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *uploadJobRepository) Update(job *model.UploadJob) error {
+	return r.db.Omit("File", "WorkerID", "LeaseExpiresAt").Save(job).Error
+}
+
+func (r *uploadJobRepository) FindByID(id string) (*model.UploadJob, error) {
+	var job model.UploadJob
+	err := r.db.Preload("File").First(&job, "id = ?", id).Error
+	return &job, err
+}
+
+func (r *uploadJobRepository) FindAbandoned(now time.Time) ([]model.UploadJob, error) {
+	var jobs []model.UploadJob
+	err := r.db.Where("status IN ?", unfinishedJobs).
+		Where("lease_expires_at IS NULL OR lease_expires_at < ?", now).
+		Order("created_at").
+		Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *uploadJobRepository) Claim(id string, worker string, until time.Time) (bool, error) {
+	result := r.db.Model(&model.UploadJob{}).
+		Where("id = ? AND status IN ?", id, unfinishedJobs).
+		Where("worker_id = ? OR lease_expires_at IS NULL OR lease_expires_at < ?", worker, time.Now()).
+		Updates(map[string]interface{}{"worker_id": worker, "lease_expires_at": until})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *uploadJobRepository) RenewLeases(worker string, ids []string, until time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.UploadJob{}).
+		Where("id IN ? AND worker_id = ?", ids, worker).
+		Update("lease_expires_at", until).Error
+}
+
+func (r *uploadJobRepository) ReleaseLeases(worker string) error {
+	return r.db.Model(&model.UploadJob{}).
+		Where("worker_id = ? AND status IN ?", worker, unfinishedJobs).
+		Update("lease_expires_at", nil).Error
+}
+
+// unfinishedJobs are the statuses of jobs and matrices that still have to
+// run.
+var unfinishedJobs = []string{model.JobQueued, model.JobProcessing}
@@ -3,10 +3,14 @@ package service
 import (
 	"archive/zip"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -15,18 +19,27 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/sohan-reza/capstone-core/internal/utils"
 )
 
 type AWSService interface {
-	UploadFile(filePath string, fileName string, year string, intake string, teamID string) (string, string, error)
+	UploadFile(filePath string, object ObjectKey, digest utils.FileDigest) (string, string, error)
+	DownloadFile(key string) (io.ReadCloser, error)
+	CopyFile(srcKey string, dstKey string) error
 	GeneratePresignedURL(key string) (string, error)
 	DeleteFile(key string) error
 	DownloadBucketAsZip(w io.Writer) error
 }
 
+// ErrObjectNotFound is returned by DownloadFile and CopyFile when the key
+// does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
 type awsService struct {
 	bucketName string
 	client     *s3.Client
+	keys       *KeyBuilder
 }
 
 // func NewAWSService(bucketName string) (AWSService, error) {
@@ -44,7 +57,7 @@ type awsService struct {
 // 	}, nil
 // }
 
-func NewAWSService(bucketName string, region string, accessKeyId string, secretKey string) (AWSService, error) {
+func NewAWSService(bucketName string, region string, accessKeyId string, secretKey string, keys *KeyBuilder) (AWSService, error) {
 	// 1. Validate required AWS config
 	if region == "" || accessKeyId == "" || secretKey == "" {
 		return nil, fmt.Errorf("missing AWS configuration - check Region, AccessKeyID and SecretAccessKey")
@@ -82,35 +95,123 @@ func NewAWSService(bucketName string, region string, accessKeyId string, secretK
 	return &awsService{
 		bucketName: bucketName,
 		client:     s3.NewFromConfig(awsCfg),
+		keys:       keys,
 	}, nil
 }
 
-func (s *awsService) UploadFile(filePath string, fileName string, year string, intake string, teamID string) (string, string, error) {
+// keyAttempts bounds how often UploadFile picks a new key when the one it
+// generated is already taken.
+const keyAttempts = 3
+
+// UploadFile stores the file under a new key built from the configured
+// layout and returns the key and the display name. object.FileName is the
+// name the client sent; the key uses a sanitized copy with a random suffix,
+// and the write is conditional so an existing object is never overwritten.
+// Digests that are set are sent along so S3 rejects the object if it does
+// not arrive intact.
+func (s *awsService) UploadFile(filePath string, object ObjectKey, digest utils.FileDigest) (string, string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to open file %s: %v", filePath, err)
 	}
 	defer file.Close()
 
-	key := fmt.Sprintf("projects/%s/%s/%s/%s",
-		year,
-		intake,
-		teamID,
-		fileName)
-
-	_, err = s.client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucketName),
-		Key:         aws.String(key),
-		Body:        file,
-		ContentType: aws.String("application/octet-stream"),
-		ACL:         types.ObjectCannedACLPrivate,
+	for attempt := 1; ; attempt++ {
+		named := object
+		named.FileName = utils.UniqueFilename(object.FileName)
+		named.UUID = newUUID()
+		if named.Hash == "" {
+			named.Hash = digest.SHA256Hex()
+		}
+		key := s.keys.Build(named)
+
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(s.bucketName),
+			Key:         aws.String(key),
+			Body:        file,
+			ContentType: aws.String("application/octet-stream"),
+			ACL:         types.ObjectCannedACLPrivate,
+			IfNoneMatch: aws.String("*"),
+		}
+		if len(digest.MD5) > 0 {
+			input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(digest.MD5))
+		}
+		if len(digest.SHA256) > 0 {
+			input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+			input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(digest.SHA256))
+		}
+
+		_, err = s.client.PutObject(context.TODO(), input)
+		if err == nil {
+			return key, utils.DisplayFilename(object.FileName), nil
+		}
+		if !isKeyTaken(err) || attempt == keyAttempts {
+			return "", "", fmt.Errorf("failed to upload file to S3: %v", err)
+		}
+
+		log.Printf("Object key %s already exists, retrying with a new key", key)
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", "", fmt.Errorf("failed to rewind file %s: %v", filePath, err)
+		}
+	}
+}
+
+// isKeyTaken reports whether a conditional PutObject failed because the key
+// exists or another write to it was in progress.
+func isKeyTaken(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
+
+func (s *awsService) DownloadFile(key string) (io.ReadCloser, error) {
+	result, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
 	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
 
+	return result.Body, nil
+}
+
+// CopyFile copies an object within the bucket without downloading it.
+func (s *awsService) CopyFile(srcKey string, dstKey string) error {
+	_, err := s.client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucketName),
+		CopySource: aws.String(s.bucketName + "/" + escapeKey(srcKey)),
+		Key:        aws.String(dstKey),
+		ACL:        types.ObjectCannedACLPrivate,
+	})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to upload file to S3: %v", err)
+		// CopyObject has no modelled NoSuchKey error, only the code
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+			return fmt.Errorf("%w: %s", ErrObjectNotFound, srcKey)
+		}
+		return fmt.Errorf("failed to copy object %s to %s: %w", srcKey, dstKey, err)
 	}
+	return nil
+}
 
-	return key, fileName, nil
+// escapeKey URL-encodes each segment of a key for use in a copy source.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
 }
 
 func (s *awsService) GeneratePresignedURL(key string) (string, error) {
@@ -165,8 +266,9 @@ func (s *awsService) DownloadBucketAsZip(w io.Writer) error {
 		}
 
 		for _, obj := range page.Contents {
-			// Create a zip entry for each file
-			entry, err := zipWriter.Create(*obj.Key)
+			// Create a zip entry for each file. Keys written before names
+			// were sanitized may still contain "../" or control characters.
+			entry, err := zipWriter.Create(utils.SanitizeKeyPath(*obj.Key))
 			if err != nil {
 				return fmt.Errorf("failed to create zip entry: %w", err)
 			}
@@ -0,0 +1,202 @@
+// Package clamdtest provides an in-process clamd stand-in that speaks enough
+// of the clamd protocol (PING, INSTREAM) for the scanner to be exercised
+// without a real ClamAV installation.
+package clamdtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/sohan-reza/capstone-core/internal/service"
+)
+
+// maxStreamLength mirrors clamd's default StreamMaxLength of 25M.
+const maxStreamLength = 25 << 20
+
+// errDropped ends a stream the server hangs up on without a reply.
+var errDropped = errors.New("connection dropped")
+
+type Server struct {
+	listener   net.Listener
+	signatures map[string][]byte
+
+	mu              sync.Mutex
+	scanned         int
+	streamMaxLength int
+	dropAfter       int
+	wg              sync.WaitGroup
+}
+
+// NewServer starts a fake clamd on a random loopback TCP port. It flags the
+// EICAR test file plus any extra signatures, keyed by the name reported back.
+func NewServer(signatures map[string][]byte) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start fake clamd: %w", err)
+	}
+	return newServer(listener, signatures), nil
+}
+
+// NewUnixServer starts a fake clamd listening on the given Unix socket path.
+func NewUnixServer(socketPath string, signatures map[string][]byte) (*Server, error) {
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start fake clamd: %w", err)
+	}
+	return newServer(listener, signatures), nil
+}
+
+func newServer(listener net.Listener, signatures map[string][]byte) *Server {
+	s := &Server{
+		listener: listener,
+		signatures: map[string][]byte{
+			"Eicar-Signature": service.EICARTestSignature,
+		},
+		streamMaxLength: maxStreamLength,
+	}
+	for name, sig := range signatures {
+		s.signatures[name] = sig
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Address returns the server address in the form accepted by
+// service.NewClamdScanner.
+func (s *Server) Address() string {
+	addr := s.listener.Addr()
+	return addr.Network() + "://" + addr.String()
+}
+
+// Scanned reports how many streams the server has received.
+func (s *Server) Scanned() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scanned
+}
+
+// SetStreamMaxLength changes the stream length past which the server
+// answers with clamd's size limit error.
+func (s *Server) SetStreamMaxLength(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamMaxLength = n
+}
+
+// DropAfter makes the server close the connection without a reply once a
+// stream passes n bytes, as a clamd that crashed would. Zero never drops.
+func (s *Server) DropAfter(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropAfter = n
+}
+
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	// Commands are prefixed with "z" (null terminated) or "n" (newline
+	// terminated); the reply uses the same terminator.
+	prefix, err := reader.ReadByte()
+	if err != nil {
+		return
+	}
+	delim := byte('\n')
+	if prefix == 'z' {
+		delim = 0
+	}
+	command, err := reader.ReadString(delim)
+	if err != nil {
+		return
+	}
+	command = strings.TrimSuffix(command, string(delim))
+
+	reply := func(msg string) {
+		conn.Write(append([]byte(msg), delim))
+	}
+
+	switch command {
+	case "PING":
+		reply("PONG")
+	case "INSTREAM":
+		data, err := s.readStream(reader)
+		if errors.Is(err, errDropped) {
+			return
+		}
+		if err != nil {
+			reply(err.Error() + " ERROR")
+			return
+		}
+
+		s.mu.Lock()
+		s.scanned++
+		s.mu.Unlock()
+
+		for name, sig := range s.signatures {
+			if len(sig) > 0 && bytes.Contains(data, sig) {
+				reply("stream: " + name + " FOUND")
+				return
+			}
+		}
+		reply("stream: OK")
+	default:
+		reply("UNKNOWN COMMAND")
+	}
+}
+
+func (s *Server) readStream(r io.Reader) ([]byte, error) {
+	s.mu.Lock()
+	maxLength, dropAfter := s.streamMaxLength, s.dropAfter
+	s.mu.Unlock()
+
+	var data bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			return nil, fmt.Errorf("failed to read chunk size")
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			return data.Bytes(), nil
+		}
+		if dropAfter > 0 && data.Len()+int(n) > dropAfter {
+			return nil, errDropped
+		}
+		if data.Len()+int(n) > maxLength {
+			return nil, fmt.Errorf("INSTREAM size limit exceeded.")
+		}
+		if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+			return nil, fmt.Errorf("failed to read chunk")
+		}
+	}
+}
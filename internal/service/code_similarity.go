@@ -0,0 +1,278 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/codesim"
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+)
+
+const (
+	// maxFingerprintMatches bounds the stored fingerprints loaded for the
+	// archives an upload is compared with in detail.
+	maxFingerprintMatches = 50000
+	// commonHashArchives drops fingerprints found in more archives than
+	// this.
+	commonHashArchives = 10
+	maxLineRanges      = 20
+)
+
+// CodeSimilarityMatch is another team's archive that shares code with the
+// upload.
+type CodeSimilarityMatch struct {
+	FileID       uint   `json:"file_id"`
+	OriginalName string `json:"original_name"`
+	TeamID       string `json:"team_id"`
+	Intake       string `json:"intake"`
+	AcademicYear string `json:"academic_year"`
+	// Percent is the share of the upload's fingerprints found in this
+	// archive
+	Percent float64               `json:"percent"`
+	Files   []model.CodeFileMatch `json:"files"`
+}
+
+type CodeSimilarityReport struct {
+	// Percent is the highest match percentage
+	Percent   float64               `json:"percent"`
+	Sources   int                   `json:"sources"`
+	Matches   []CodeSimilarityMatch `json:"matches"`
+	CheckedAt time.Time             `json:"checked_at"`
+
+	// Threshold and Flagged are filled in by the stage that applied the
+	// threshold
+	Threshold float64 `json:"threshold"`
+	Flagged   bool    `json:"flagged"`
+}
+
+// Report converts the code similarity report into the record kept for the
+// check.
+func (r *CodeSimilarityReport) Report() model.PlagiarismReport {
+	report := model.PlagiarismReport{
+		Checker:   model.CheckerCode,
+		Status:    string(PlagiarismChecked),
+		Threshold: r.Threshold,
+		Flagged:   r.Flagged,
+		CheckedAt: r.CheckedAt,
+	}
+	score := r.Percent
+	report.Score = &score
+
+	for _, match := range r.Matches {
+		fileID := match.FileID
+		report.Sources = append(report.Sources, model.PlagiarismSource{
+			FileID:       &fileID,
+			Title:        match.OriginalName,
+			TeamID:       match.TeamID,
+			Intake:       match.Intake,
+			AcademicYear: match.AcademicYear,
+			Percent:      match.Percent,
+			Files:        match.Files,
+		})
+	}
+	if raw, err := json.Marshal(r); err == nil {
+		report.RawPayload = raw
+	}
+	return report
+}
+
+// CodeSimilarityEngine compares the source code in archives with the
+// archives of other teams, in the same intake and earlier academic years.
+type CodeSimilarityEngine struct {
+	signatures repository.CodeSignatureRepository
+	limits     codesim.Limits
+	topMatches int
+}
+
+func NewCodeSimilarityEngine(signatures repository.CodeSignatureRepository, topMatches int) *CodeSimilarityEngine {
+	return &CodeSimilarityEngine{
+		signatures: signatures,
+		limits:     codesim.DefaultLimits,
+		topMatches: topMatches,
+	}
+}
+
+// Check fingerprints the source files of the archive at path and compares
+// them with the stored archives. It returns the report and the signature to
+// store with the file, or nils when the archive holds no source code.
+func (e *CodeSimilarityEngine) Check(path string, teamID string, intake string, academicYear string) (*CodeSimilarityReport, *model.CodeSignature, error) {
+	sources, err := codesim.Extract(path, e.limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sources) == 0 {
+		return nil, nil, nil
+	}
+
+	type position struct{ source, fingerprint int }
+	local := make(map[int64][]position)
+	total := 0
+	for i, source := range sources {
+		for j, fp := range source.Fingerprints {
+			local[fp.Hash] = append(local[fp.Hash], position{i, j})
+		}
+		total += len(source.Fingerprints)
+	}
+	hashes := make([]int64, 0, len(local))
+	for hash := range local {
+		hashes = append(hashes, hash)
+	}
+
+	// Fingerprints found in many archives come from starter code or
+	// generated files, not copying
+	common, err := e.signatures.CommonHashes(hashes, teamID, intake, academicYear, commonHashArchives)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared := hashes[:0]
+	for _, hash := range hashes {
+		if !common[hash] {
+			shared = append(shared, hash)
+		}
+	}
+
+	// Rank the archives by the hashes they share, then load the
+	// fingerprints of the best ones for exact figures and line ranges
+	candidates, err := e.signatures.Archives(shared, teamID, intake, academicYear, e.topMatches*2)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]uint, 0, len(candidates))
+	for _, c := range candidates {
+		ids = append(ids, c.FileID)
+	}
+	rows, err := e.signatures.Matches(shared, ids, intake, maxFingerprintMatches)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Collect, per archive and per pair of files, which of the upload's
+	// fingerprints were found and the lines they cover on both sides
+	type filePair struct {
+		source      int
+		matchedPath string
+	}
+	type archive struct {
+		match   CodeSimilarityMatch
+		matched map[position]bool
+		pairs   map[filePair]map[int]bool
+		ranges  map[filePair][]codesim.LineRange
+	}
+	archives := make(map[uint]*archive)
+	var order []uint
+	for _, row := range rows {
+		a, ok := archives[row.FileID]
+		if !ok {
+			a = &archive{
+				match: CodeSimilarityMatch{
+					FileID:       row.FileID,
+					OriginalName: row.OriginalName,
+					TeamID:       row.TeamID,
+					Intake:       row.Intake,
+					AcademicYear: row.AcademicYear,
+				},
+				matched: make(map[position]bool),
+				pairs:   make(map[filePair]map[int]bool),
+				ranges:  make(map[filePair][]codesim.LineRange),
+			}
+			archives[row.FileID] = a
+			order = append(order, row.FileID)
+		}
+
+		for _, pos := range local[row.Hash] {
+			a.matched[pos] = true
+			pair := filePair{pos.source, row.Path}
+			if a.pairs[pair] == nil {
+				a.pairs[pair] = make(map[int]bool)
+			}
+			a.pairs[pair][pos.fingerprint] = true
+
+			fp := sources[pos.source].Fingerprints[pos.fingerprint]
+			a.ranges[pair] = append(a.ranges[pair], codesim.LineRange{
+				Start:        fp.StartLine,
+				End:          fp.EndLine,
+				MatchedStart: row.StartLine,
+				MatchedEnd:   row.EndLine,
+			})
+		}
+	}
+
+	report := &CodeSimilarityReport{Sources: len(sources), Matches: []CodeSimilarityMatch{}, CheckedAt: time.Now()}
+	for _, id := range order {
+		a := archives[id]
+		a.match.Percent = percentOf(len(a.matched), total)
+		if a.match.Percent < minMatchPercent {
+			continue
+		}
+
+		for pair, fingerprints := range a.pairs {
+			source := sources[pair.source]
+			file := model.CodeFileMatch{
+				Path:        source.Path,
+				MatchedPath: pair.matchedPath,
+				Percent:     percentOf(len(fingerprints), len(source.Fingerprints)),
+			}
+			for i, r := range codesim.MergeRanges(a.ranges[pair]) {
+				if i == maxLineRanges {
+					break
+				}
+				file.Lines = append(file.Lines, model.CodeLineRange{
+					Start:        r.Start,
+					End:          r.End,
+					MatchedStart: r.MatchedStart,
+					MatchedEnd:   r.MatchedEnd,
+				})
+			}
+			a.match.Files = append(a.match.Files, file)
+		}
+		sort.Slice(a.match.Files, func(i, j int) bool {
+			fi, fj := a.match.Files[i], a.match.Files[j]
+			if fi.Percent != fj.Percent {
+				return fi.Percent > fj.Percent
+			}
+			if fi.Path != fj.Path {
+				return fi.Path < fj.Path
+			}
+			return fi.MatchedPath < fj.MatchedPath
+		})
+		report.Matches = append(report.Matches, a.match)
+	}
+	sort.SliceStable(report.Matches, func(i, j int) bool { return report.Matches[i].Percent > report.Matches[j].Percent })
+	if len(report.Matches) > e.topMatches {
+		report.Matches = report.Matches[:e.topMatches]
+	}
+	if len(report.Matches) > 0 {
+		report.Percent = report.Matches[0].Percent
+	}
+
+	return report, codeSignature(sources), nil
+}
+
+func codeSignature(sources []codesim.Source) *model.CodeSignature {
+	signature := &model.CodeSignature{}
+	for _, source := range sources {
+		s := model.CodeSource{
+			Path:     source.Path,
+			Language: string(source.Language),
+			Lines:    source.Lines,
+		}
+		for _, fp := range source.Fingerprints {
+			s.Fingerprints = append(s.Fingerprints, model.CodeFingerprint{
+				Hash:      fp.Hash,
+				StartLine: fp.StartLine,
+				EndLine:   fp.EndLine,
+			})
+		}
+		signature.Sources = append(signature.Sources, s)
+	}
+	return signature
+}
+
+func percentOf(part int, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part*10000/whole) / 100
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/validation"
+	"gorm.io/gorm"
+)
+
+// intakeRegistry validates uploads against the intakes and teams recorded
+// in the database.
+type intakeRegistry struct {
+	intakes repository.IntakeRepository
+	teams   repository.TeamRepository
+}
+
+func NewIntakeRegistry(intakes repository.IntakeRepository, teams repository.TeamRepository) validation.Registry {
+	return &intakeRegistry{intakes: intakes, teams: teams}
+}
+
+func (r *intakeRegistry) FindIntake(name string) (*validation.Intake, error) {
+	intake, err := r.intakes.FindByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, validation.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &validation.Intake{
+		Name:         intake.Name,
+		AcademicYear: intake.AcademicYear,
+		Session:      intake.Session,
+		Deadlines:    intake.Deadlines,
+	}, nil
+}
+
+func (r *intakeRegistry) HasTeam(intake string, teamID string) (bool, error) {
+	_, err := r.teams.Find(intake, teamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
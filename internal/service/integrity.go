@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+)
+
+// IntegrityReport summarises one verification run.
+type IntegrityReport struct {
+	Checked int
+	Corrupt int
+	Missing int
+}
+
+// IntegrityVerifier re-reads stored objects and compares them with the
+// digests recorded when they were uploaded.
+type IntegrityVerifier struct {
+	aws           AWSService
+	files         repository.FileRepository
+	batchSize     int
+	reverifyAfter time.Duration
+}
+
+func NewIntegrityVerifier(aws AWSService, files repository.FileRepository, batchSize int, reverifyAfter time.Duration) *IntegrityVerifier {
+	return &IntegrityVerifier{
+		aws:           aws,
+		files:         files,
+		batchSize:     batchSize,
+		reverifyAfter: reverifyAfter,
+	}
+}
+
+// Start runs a verification pass every interval until ctx is done.
+func (v *IntegrityVerifier) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := v.Run(ctx)
+				if err != nil {
+					log.Printf("Warning: integrity verification stopped early: %v", err)
+				}
+				if report.Checked > 0 {
+					log.Printf("Verified %d stored files: %d corrupt, %d missing", report.Checked, report.Corrupt, report.Missing)
+				}
+			}
+		}
+	}()
+}
+
+// Run verifies every file that is due. It stops at the first error that is
+// not about the file itself, such as S3 or the database being unavailable,
+// and leaves the remaining files for the next run.
+func (v *IntegrityVerifier) Run(ctx context.Context) (IntegrityReport, error) {
+	var report IntegrityReport
+	cutoff := time.Now().Add(-v.reverifyAfter)
+
+	for {
+		files, err := v.files.FindDueForVerification(cutoff, v.batchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(files) == 0 {
+			return report, nil
+		}
+
+		for i := range files {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			file := &files[i]
+			status, err := v.Verify(file)
+			if err != nil {
+				return report, fmt.Errorf("file %d: %w", file.ID, err)
+			}
+
+			report.Checked++
+			switch status {
+			case model.IntegrityCorrupt:
+				report.Corrupt++
+			case model.IntegrityMissing:
+				report.Missing++
+			}
+
+			if err := v.files.UpdateIntegrity(file.ID, status, time.Now()); err != nil {
+				return report, err
+			}
+		}
+	}
+}
+
+// Verify downloads a file's object and returns its integrity status.
+func (v *IntegrityVerifier) Verify(file *model.File) (string, error) {
+	body, err := v.aws.DownloadFile(file.StorageKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		log.Printf("Integrity check: object for file %d (%s) is missing", file.ID, file.StorageKey)
+		return model.IntegrityMissing, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	actual, err := utils.DigestReader(body)
+	if err != nil {
+		return "", err
+	}
+
+	expected := utils.DigestFromHex(file.ChecksumMD5, file.ChecksumSHA256)
+	if algorithm := actual.Mismatch(expected); algorithm != "" {
+		log.Printf("Integrity check: file %d (%s) is corrupt, %s digest does not match", file.ID, file.StorageKey, algorithm)
+		return model.IntegrityCorrupt, nil
+	}
+
+	return model.IntegrityOK, nil
+}
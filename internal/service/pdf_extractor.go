@@ -0,0 +1,287 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/sohan-reza/capstone-core/internal/model"
+)
+
+// Extracted text is capped so a pathological PDF cannot blow up the
+// file_documents table.
+const maxExtractedTextBytes = 8 << 20
+
+type PDFExtractor interface {
+	Extract(filePath string) (*model.FileDocument, error)
+}
+
+type pdfExtractor struct{}
+
+func NewPDFExtractor() PDFExtractor {
+	return &pdfExtractor{}
+}
+
+// Extract parses the PDF at filePath for its page count, document info and
+// plain text. Password protected files are reported as encrypted with no text
+// rather than as an error; files that open without a password but restrict
+// what may be done with them are reported as restricted.
+func (e *pdfExtractor) Extract(filePath string) (doc *model.FileDocument, err error) {
+	// The pdf package panics on malformed input instead of returning errors
+	defer func() {
+		if rec := recover(); rec != nil {
+			doc = nil
+			err = fmt.Errorf("malformed PDF: %v", rec)
+		}
+	}()
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF %s: %v", filePath, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat PDF %s: %v", filePath, err)
+	}
+
+	reader, err := pdf.NewReader(f, info.Size())
+	if errors.Is(err, pdf.ErrInvalidPassword) {
+		return &model.FileDocument{Encrypted: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PDF: %v", err)
+	}
+
+	trailer := reader.Trailer()
+	docInfo := trailer.Key("Info")
+
+	doc = &model.FileDocument{
+		PageCount: reader.NumPage(),
+		Title:     cleanText(docInfo.Key("Title").Text()),
+		Author:    cleanText(docInfo.Key("Author").Text()),
+		Producer:  cleanText(docInfo.Key("Producer").Text()),
+		// Opening without a password means only an owner password is set
+		Restricted: !trailer.Key("Encrypt").IsNull(),
+	}
+
+	if created, ok := parsePDFDate(docInfo.Key("CreationDate").Text()); ok {
+		doc.CreationDate = &created
+	}
+
+	doc.PDFAConformance = pdfaConformance(trailer.Key("Root").Key("Metadata"))
+
+	var text strings.Builder
+	unembedded := make(map[string]bool)
+	for i := 1; i <= doc.PageCount; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		collectUnembeddedFonts(page, unembedded)
+		if text.Len() < maxExtractedTextBytes {
+			writePageText(&text, page)
+		}
+	}
+	for name := range unembedded {
+		doc.UnembeddedFonts = append(doc.UnembeddedFonts, name)
+	}
+	sort.Strings(doc.UnembeddedFonts)
+
+	doc.Text = cleanText(text.String())
+	if len(doc.Text) > maxExtractedTextBytes {
+		doc.Text = strings.ToValidUTF8(doc.Text[:maxExtractedTextBytes], "")
+	}
+	doc.WordCount = len(strings.Fields(doc.Text))
+
+	return doc, nil
+}
+
+// writePageText walks the page content stream and rebuilds word and line
+// breaks from text positioning operators. Many PDF producers (pdfTeX in
+// particular) never emit space characters, so the library's plain text
+// output runs words together.
+func writePageText(sb *strings.Builder, page pdf.Page) {
+	encoders := make(map[string]pdf.TextEncoding)
+	var enc pdf.TextEncoding
+
+	separate := func(sep byte) {
+		if sb.Len() == 0 {
+			return
+		}
+		last := sb.String()[sb.Len()-1]
+		if last == '\n' || (last == ' ' && sep == ' ') {
+			return
+		}
+		sb.WriteByte(sep)
+	}
+	show := func(raw string) {
+		if enc != nil {
+			sb.WriteString(enc.Decode(raw))
+		} else {
+			sb.WriteString(raw)
+		}
+	}
+
+	interpret := func(strm pdf.Value) {
+		pdf.Interpret(strm, func(stk *pdf.Stack, op string) {
+			args := make([]pdf.Value, stk.Len())
+			for i := len(args) - 1; i >= 0; i-- {
+				args[i] = stk.Pop()
+			}
+
+			switch op {
+			case "Tf":
+				if len(args) != 2 {
+					return
+				}
+				name := args[0].Name()
+				if _, ok := encoders[name]; !ok {
+					encoders[name] = page.Font(name).Encoder()
+				}
+				enc = encoders[name]
+			case "Td", "TD":
+				if len(args) != 2 {
+					return
+				}
+				if args[1].Float64() != 0 {
+					separate('\n')
+				} else if args[0].Float64() > 0 {
+					separate(' ')
+				}
+			case "T*", "Tm":
+				separate('\n')
+			case "'", "\"":
+				separate('\n')
+				if len(args) > 0 {
+					show(args[len(args)-1].RawString())
+				}
+			case "Tj":
+				if len(args) == 1 {
+					show(args[0].RawString())
+				}
+			case "TJ":
+				if len(args) != 1 {
+					return
+				}
+				// Kerning adjustments are in thousandths of an em; anything
+				// wider than a thin space is treated as a word break.
+				for i := 0; i < args[0].Len(); i++ {
+					x := args[0].Index(i)
+					if x.Kind() == pdf.String {
+						show(x.RawString())
+					} else if x.Float64() < -200 {
+						separate(' ')
+					}
+				}
+			case "ET":
+				separate(' ')
+			}
+		})
+	}
+
+	contents := page.V.Key("Contents")
+	if contents.Kind() == pdf.Array {
+		for i := 0; i < contents.Len(); i++ {
+			interpret(contents.Index(i))
+		}
+	} else {
+		interpret(contents)
+	}
+	separate('\n')
+}
+
+// collectUnembeddedFonts records the base name of every font on the page
+// that has no font program in the file. Type3 fonts are drawn with PDF
+// operators and always count as embedded.
+func collectUnembeddedFonts(page pdf.Page, unembedded map[string]bool) {
+	for _, name := range page.Fonts() {
+		font := page.Font(name)
+		descriptor := font.V.Key("FontDescriptor")
+
+		switch font.V.Key("Subtype").Name() {
+		case "Type3":
+			continue
+		case "Type0":
+			descriptor = font.V.Key("DescendantFonts").Index(0).Key("FontDescriptor")
+		}
+
+		if descriptor.Key("FontFile").IsNull() &&
+			descriptor.Key("FontFile2").IsNull() &&
+			descriptor.Key("FontFile3").IsNull() {
+			unembedded[font.BaseFont()] = true
+		}
+	}
+}
+
+var (
+	pdfaPartPattern        = regexp.MustCompile(`pdfaid:part(?:>|=["'])\s*(\d)`)
+	pdfaConformancePattern = regexp.MustCompile(`pdfaid:conformance(?:>|=["'])\s*([ABUabu])`)
+)
+
+// pdfaConformance reads the PDF/A identification schema from the XMP metadata
+// stream, returning e.g. "2B", or "" when the file does not claim PDF/A.
+func pdfaConformance(metadata pdf.Value) string {
+	if metadata.Kind() != pdf.Stream {
+		return ""
+	}
+
+	rd := metadata.Reader()
+	defer rd.Close()
+	xmp, err := io.ReadAll(io.LimitReader(rd, 1<<20))
+	if err != nil {
+		return ""
+	}
+
+	part := pdfaPartPattern.FindSubmatch(xmp)
+	if part == nil {
+		return ""
+	}
+	conformance := ""
+	if m := pdfaConformancePattern.FindSubmatch(xmp); m != nil {
+		conformance = strings.ToUpper(string(m[1]))
+	}
+	return string(part[1]) + conformance
+}
+
+// cleanText drops bytes Postgres refuses to store in a text column.
+func cleanText(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	return strings.TrimSpace(strings.ReplaceAll(s, "\x00", ""))
+}
+
+// parsePDFDate parses the PDF date format D:YYYYMMDDHHmmSSOHH'mm', where
+// everything after the year is optional.
+func parsePDFDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	s = strings.ReplaceAll(s, "'", "")
+	if len(s) < 4 {
+		return time.Time{}, false
+	}
+
+	layouts := []string{
+		"20060102150405-0700",
+		"20060102150405Z0700",
+		"20060102150405Z",
+		"20060102150405",
+		"200601021504",
+		"2006010215",
+		"20060102",
+		"200601",
+		"2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
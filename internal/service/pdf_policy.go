@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+)
+
+// PDFPolicy is the set of checks applied to a PDF of one submission type.
+// Zero values disable a limit, so an empty policy only rejects encrypted and
+// unparsable files.
+type PDFPolicy struct {
+	AllowEncrypted       bool `json:"allow_encrypted"`
+	AllowUnparsable      bool `json:"allow_unparsable"`
+	MinPages             int  `json:"min_pages"`
+	MaxPages             int  `json:"max_pages"`
+	MaxWords             int  `json:"max_words"`
+	RequireEmbeddedFonts bool `json:"require_embedded_fonts"`
+	RequirePDFA          bool `json:"require_pdfa"`
+}
+
+type PolicyViolation struct {
+	Rule    string      `json:"rule"`
+	Message string      `json:"message"`
+	Limit   interface{} `json:"limit,omitempty"`
+	Actual  interface{} `json:"actual,omitempty"`
+}
+
+// PDFPolicySet maps submission types (report, proposal, slides, ...) to
+// their policy. Types without an entry use Default.
+type PDFPolicySet struct {
+	Default PDFPolicy            `json:"default"`
+	Types   map[string]PDFPolicy `json:"types"`
+}
+
+// LoadPDFPolicies reads a policy set from a JSON file. An empty path yields
+// the default policy for every type.
+//
+//	{
+//	  "default": {"max_pages": 300},
+//	  "types": {
+//	    "proposal":     {"min_pages": 2, "max_pages": 10, "max_words": 3000},
+//	    "final_report": {"min_pages": 30, "require_embedded_fonts": true, "require_pdfa": true}
+//	  }
+//	}
+func LoadPDFPolicies(path string) (*PDFPolicySet, error) {
+	set := &PDFPolicySet{Types: map[string]PDFPolicy{}}
+	if path == "" {
+		return set, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF policy file %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse PDF policy file %s: %v", path, err)
+	}
+
+	types := make(map[string]PDFPolicy, len(set.Types))
+	for name, policy := range set.Types {
+		types[strings.ToLower(name)] = policy
+	}
+	set.Types = types
+
+	return set, nil
+}
+
+func (s *PDFPolicySet) For(docType string) PDFPolicy {
+	if policy, ok := s.Types[strings.ToLower(docType)]; ok {
+		return policy
+	}
+	return s.Default
+}
+
+// Validate checks a parsed document against the policy. A nil doc means the
+// file could not be parsed. Warnings are PDF/A conformance hints that do not
+// reject the file on their own.
+func (p PDFPolicy) Validate(doc *model.FileDocument) (violations []PolicyViolation, warnings []string) {
+	if doc == nil {
+		if !p.AllowUnparsable {
+			violations = append(violations, PolicyViolation{
+				Rule:    "unparsable",
+				Message: "The file could not be read as a PDF document",
+			})
+		}
+		return violations, nil
+	}
+
+	// Nothing but the encryption is known about a document that could not
+	// be opened, so the other rules are not checked
+	if doc.Encrypted {
+		if p.AllowEncrypted {
+			return nil, []string{"Encrypted PDFs cannot conform to PDF/A"}
+		}
+		return []PolicyViolation{{
+			Rule:    "encrypted",
+			Message: "Password protected PDFs are not accepted",
+		}}, nil
+	}
+
+	if p.MinPages > 0 && doc.PageCount < p.MinPages {
+		violations = append(violations, PolicyViolation{
+			Rule:    "min_pages",
+			Message: fmt.Sprintf("At least %d pages are required", p.MinPages),
+			Limit:   p.MinPages,
+			Actual:  doc.PageCount,
+		})
+	}
+
+	if p.MaxPages > 0 && doc.PageCount > p.MaxPages {
+		violations = append(violations, PolicyViolation{
+			Rule:    "max_pages",
+			Message: fmt.Sprintf("At most %d pages are allowed", p.MaxPages),
+			Limit:   p.MaxPages,
+			Actual:  doc.PageCount,
+		})
+	}
+
+	if p.MaxWords > 0 && doc.WordCount > p.MaxWords {
+		violations = append(violations, PolicyViolation{
+			Rule:    "max_words",
+			Message: fmt.Sprintf("At most %d words are allowed", p.MaxWords),
+			Limit:   p.MaxWords,
+			Actual:  doc.WordCount,
+		})
+	}
+
+	if len(doc.UnembeddedFonts) > 0 {
+		if p.RequireEmbeddedFonts {
+			violations = append(violations, PolicyViolation{
+				Rule:    "embedded_fonts",
+				Message: "All fonts must be embedded in the PDF",
+				Actual:  doc.UnembeddedFonts,
+			})
+		} else {
+			warnings = append(warnings, "Fonts not embedded, the PDF cannot be archived as PDF/A: "+strings.Join(doc.UnembeddedFonts, ", "))
+		}
+	}
+
+	if doc.PDFAConformance == "" {
+		if p.RequirePDFA {
+			violations = append(violations, PolicyViolation{
+				Rule:    "pdfa",
+				Message: "The PDF must be exported as PDF/A",
+			})
+		} else {
+			warnings = append(warnings, "The PDF does not declare PDF/A conformance")
+		}
+	}
+
+	if doc.Restricted {
+		warnings = append(warnings, "PDFs with permission restrictions cannot conform to PDF/A")
+	}
+
+	return violations, warnings
+}
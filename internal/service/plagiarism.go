@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sohan-reza/capstone-core/internal/model"
+)
+
+type PlagiarismStatus string
+
+const (
+	PlagiarismChecked PlagiarismStatus = model.PlagiarismChecked
+	// PlagiarismPending marks files stored without a check because no
+	// provider was available; they are checked later.
+	PlagiarismPending PlagiarismStatus = model.PlagiarismPending
+	// PlagiarismProviderError marks files the providers refused to check,
+	// such as ones too large for them.
+	PlagiarismProviderError PlagiarismStatus = model.PlagiarismProviderError
+)
+
+// PlagiarismRequest describes the file to check.
+type PlagiarismRequest struct {
+	FilePath    string
+	FileName    string
+	ContentType string
+	DocType     string
+	SHA256      string
+}
+
+type PlagiarismResult struct {
+	Status       PlagiarismStatus         `json:"status"`
+	Provider     string                   `json:"provider,omitempty"`
+	MatchPercent float64                  `json:"match_percent"`
+	ReportURL    string                   `json:"report_url,omitempty"`
+	Sources      []model.PlagiarismSource `json:"sources,omitempty"`
+	CheckedAt    time.Time                `json:"checked_at"`
+	// Raw is the provider's response body
+	Raw json.RawMessage `json:"-"`
+
+	// Threshold and Flagged are filled in by the stage that applied the
+	// threshold
+	Threshold float64 `json:"threshold"`
+	Flagged   bool    `json:"flagged"`
+}
+
+// ApplyThreshold records threshold and flags the result when the match
+// reaches it.
+func (r *PlagiarismResult) ApplyThreshold(threshold float64) {
+	r.Threshold = threshold
+	r.Flagged = r.Status == PlagiarismChecked && r.MatchPercent >= threshold
+}
+
+// Report converts the result into the record kept for the check.
+func (r *PlagiarismResult) Report() model.PlagiarismReport {
+	report := model.PlagiarismReport{
+		Checker:    model.CheckerProvider,
+		Provider:   r.Provider,
+		Status:     string(r.Status),
+		Threshold:  r.Threshold,
+		Flagged:    r.Flagged,
+		Sources:    r.Sources,
+		RawPayload: r.Raw,
+		CheckedAt:  r.CheckedAt,
+	}
+	if r.Status == PlagiarismChecked {
+		score := r.MatchPercent
+		report.Score = &score
+	}
+	return report
+}
+
+// PlagiarismChecker submits a file to a plagiarism detection provider.
+type PlagiarismChecker interface {
+	Name() string
+	Check(ctx context.Context, req PlagiarismRequest) (*PlagiarismResult, error)
+}
+
+// ErrNoPlagiarismProvider is returned when every configured provider failed
+// or none is configured.
+var ErrNoPlagiarismProvider = errors.New("no plagiarism provider available")
+
+// Response schemas understood by the HTTP adapter. Each names where the
+// score is found in the JSON response and whether it is a percentage or a
+// fraction.
+var plagiarismSchemas = map[string]PlagiarismProvider{
+	// Our own service: {"matchPercent": 12.5}
+	"match_percent": {ScoreField: "matchPercent", Scale: 1},
+	// {"similarity": 0.125}
+	"similarity": {ScoreField: "similarity", Scale: 100},
+	// {"result": {"score": 12.5, "report_url": "...", "sources": [...]}}
+	"result_score": {ScoreField: "result.score", ReportURLField: "result.report_url", SourcesField: "result.sources", Scale: 1},
+}
+
+// PlagiarismProvider configures an HTTP provider. Schema picks one of the
+// known response formats; ScoreField, ReportURLField and Scale override it
+// or describe a format of their own.
+type PlagiarismProvider struct {
+	Name           string `json:"name"`
+	Endpoint       string `json:"endpoint"`
+	Schema         string `json:"schema"`
+	FileField      string `json:"file_field"`
+	ScoreField     string `json:"score_field"`
+	ReportURLField string `json:"report_url_field"`
+	// SourcesField names an array of matched sources, each an object with
+	// title (or name), url and percent (or score)
+	SourcesField string `json:"sources_field"`
+	// Scale multiplies the score into a percentage, 100 for fractions
+	Scale   float64       `json:"scale"`
+	Timeout time.Duration `json:"-"`
+}
+
+type httpPlagiarismChecker struct {
+	provider PlagiarismProvider
+	client   *resty.Client
+}
+
+// NewHTTPPlagiarismChecker returns a checker that posts the file as
+// multipart form data to the provider's endpoint and reads the score from
+// its JSON response.
+func NewHTTPPlagiarismChecker(p PlagiarismProvider) (PlagiarismChecker, error) {
+	if p.Schema != "" {
+		schema, ok := plagiarismSchemas[p.Schema]
+		if !ok {
+			return nil, fmt.Errorf("plagiarism provider %s: unknown schema %q", p.Name, p.Schema)
+		}
+		if p.ScoreField == "" {
+			p.ScoreField = schema.ScoreField
+		}
+		if p.ReportURLField == "" {
+			p.ReportURLField = schema.ReportURLField
+		}
+		if p.SourcesField == "" {
+			p.SourcesField = schema.SourcesField
+		}
+		if p.Scale == 0 {
+			p.Scale = schema.Scale
+		}
+	}
+	if p.Endpoint == "" {
+		return nil, fmt.Errorf("plagiarism provider %s: missing endpoint", p.Name)
+	}
+	if p.ScoreField == "" {
+		return nil, fmt.Errorf("plagiarism provider %s: missing schema or score_field", p.Name)
+	}
+	if p.FileField == "" {
+		p.FileField = "file"
+	}
+	if p.Scale == 0 {
+		p.Scale = 1
+	}
+
+	client := resty.New()
+	if p.Timeout > 0 {
+		client.SetTimeout(p.Timeout)
+	}
+
+	return &httpPlagiarismChecker{provider: p, client: client}, nil
+}
+
+func (c *httpPlagiarismChecker) Name() string {
+	return c.provider.Name
+}
+
+func (c *httpPlagiarismChecker) Check(ctx context.Context, req PlagiarismRequest) (*PlagiarismResult, error) {
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetFile(c.provider.FileField, req.FilePath).
+		SetFormData(map[string]string{
+			"file_name": req.FileName,
+			"doc_type":  req.DocType,
+			"sha256":    req.SHA256,
+		}).
+		Post(c.provider.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, &ProviderError{StatusCode: resp.StatusCode()}
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(resp.Body(), &body); err != nil {
+		return nil, &invalidResponseError{fmt.Errorf("provider returned invalid JSON: %w", err)}
+	}
+
+	score, ok := lookupNumber(body, c.provider.ScoreField)
+	if !ok {
+		return nil, &invalidResponseError{fmt.Errorf("provider response has no numeric %s", c.provider.ScoreField)}
+	}
+
+	result := &PlagiarismResult{
+		Status:       PlagiarismChecked,
+		Provider:     c.provider.Name,
+		MatchPercent: score * c.provider.Scale,
+		CheckedAt:    time.Now(),
+		Raw:          json.RawMessage(resp.Body()),
+	}
+	if c.provider.ReportURLField != "" {
+		result.ReportURL, _ = lookup(body, c.provider.ReportURLField).(string)
+	}
+	if c.provider.SourcesField != "" {
+		result.Sources = c.sources(body)
+	}
+	return result, nil
+}
+
+// sources reads the matched sources a provider reported, accepting the
+// common names for each attribute.
+func (c *httpPlagiarismChecker) sources(body map[string]interface{}) []model.PlagiarismSource {
+	items, _ := lookup(body, c.provider.SourcesField).([]interface{})
+	sources := make([]model.PlagiarismSource, 0, len(items))
+	for _, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		source := model.PlagiarismSource{}
+		source.Title, _ = firstOf(object, "title", "name").(string)
+		source.URL, _ = firstOf(object, "url", "link").(string)
+		for _, key := range []string{"percent", "score", "similarity"} {
+			if score, ok := lookupNumber(object, key); ok {
+				source.Percent = score * c.provider.Scale
+				break
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+func firstOf(object map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if value, ok := object[key]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// lookup follows a dot separated path such as "result.score" into decoded
+// JSON.
+func lookup(body map[string]interface{}, path string) interface{} {
+	var value interface{} = body
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func lookupNumber(body map[string]interface{}, path string) (float64, bool) {
+	switch v := lookup(body, path).(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+type plagiarismChain struct {
+	checkers []PlagiarismChecker
+}
+
+// NewPlagiarismChain returns a checker that tries each checker in order and
+// returns the first result. When all of them fail the error wraps
+// ErrNoPlagiarismProvider and each provider's error.
+func NewPlagiarismChain(checkers ...PlagiarismChecker) PlagiarismChecker {
+	return &plagiarismChain{checkers: checkers}
+}
+
+func (c *plagiarismChain) Name() string {
+	names := make([]string, 0, len(c.checkers))
+	for _, checker := range c.checkers {
+		names = append(names, checker.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *plagiarismChain) Check(ctx context.Context, req PlagiarismRequest) (*PlagiarismResult, error) {
+	var failures []error
+	for _, checker := range c.checkers {
+		result, err := checker.Check(ctx, req)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", checker.Name(), err))
+	}
+	if len(failures) == 0 {
+		return nil, ErrNoPlagiarismProvider
+	}
+	return nil, &chainError{failures: failures}
+}
+
+// chainError is the failure of every provider in a chain.
+type chainError struct {
+	failures []error
+}
+
+func (e *chainError) Error() string {
+	failures := make([]string, len(e.failures))
+	for i, err := range e.failures {
+		failures[i] = err.Error()
+	}
+	return fmt.Sprintf("%v (%s)", ErrNoPlagiarismProvider, strings.Join(failures, "; "))
+}
+
+func (e *chainError) Unwrap() []error {
+	return append([]error{ErrNoPlagiarismProvider}, e.failures...)
+}
+
+// PlagiarismOptions configure how each provider is called. Timeout bounds a
+// single attempt.
+type PlagiarismOptions struct {
+	Timeout         time.Duration
+	Retry           RetryPolicy
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+// LoadPlagiarismCheckers builds the provider chain named by selected, a
+// comma separated list in the order providers are tried. Each provider is
+// retried and guarded by its own circuit breaker, so a provider that is down
+// is passed over quickly in favour of the next one. Providers are read
+// from a JSON file of the form
+//
+//	{"providers": [{"name": "primary", "schema": "match_percent",
+//	  "endpoint": "http://localhost:8081"}]}
+//
+// Without a file there is one provider, "default", using the match_percent
+// schema at defaultEndpoint. An empty selection yields a chain without
+// providers, which always reports ErrNoPlagiarismProvider.
+func LoadPlagiarismCheckers(path string, selected []string, defaultEndpoint string, opts PlagiarismOptions) (PlagiarismChecker, error) {
+	providers := []PlagiarismProvider{{Name: "default", Schema: "match_percent", Endpoint: defaultEndpoint}}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plagiarism provider file %s: %v", path, err)
+		}
+		var file struct {
+			Providers []PlagiarismProvider `json:"providers"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse plagiarism provider file %s: %v", path, err)
+		}
+		providers = file.Providers
+	}
+
+	byName := make(map[string]PlagiarismProvider, len(providers))
+	for _, p := range providers {
+		p.Timeout = opts.Timeout
+		byName[strings.ToLower(p.Name)] = p
+	}
+
+	var checkers []PlagiarismChecker
+	for _, name := range selected {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		p, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown plagiarism provider %q", name)
+		}
+		checker, err := NewHTTPPlagiarismChecker(p)
+		if err != nil {
+			return nil, err
+		}
+		checker = NewRetryingChecker(checker, opts.Retry)
+		checkers = append(checkers, NewCircuitBreaker(checker, opts.BreakerFailures, opts.BreakerCooldown))
+	}
+
+	return NewPlagiarismChain(checkers...), nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+)
+
+// RecheckReport summarises one re-check run.
+type RecheckReport struct {
+	Checked int
+	Flagged int
+	Missing int
+	// Refused counts files the providers would not check
+	Refused int
+}
+
+// PlagiarismRechecker checks files that were stored while no plagiarism
+// provider was available. Files are not rejected after the fact; a match
+// at or above the threshold is flagged in the file's report instead.
+type PlagiarismRechecker struct {
+	aws        AWSService
+	files      repository.FileRepository
+	checker    PlagiarismChecker
+	thresholds *PlagiarismThresholds
+	batchSize  int
+	tempDir    string
+}
+
+func NewPlagiarismRechecker(aws AWSService, files repository.FileRepository, checker PlagiarismChecker, thresholds *PlagiarismThresholds, batchSize int, tempDir string) *PlagiarismRechecker {
+	return &PlagiarismRechecker{
+		aws:        aws,
+		files:      files,
+		checker:    checker,
+		thresholds: thresholds,
+		batchSize:  batchSize,
+		tempDir:    tempDir,
+	}
+}
+
+// Start runs a re-check pass every interval until ctx is done.
+func (c *PlagiarismRechecker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := c.Run(ctx)
+				if errors.Is(err, ErrNoPlagiarismProvider) {
+					err = nil
+				}
+				if err != nil {
+					log.Printf("Warning: plagiarism re-check stopped early: %v", err)
+				}
+				if report.Checked > 0 {
+					log.Printf("Re-checked %d pending files for plagiarism: %d flagged", report.Checked, report.Flagged)
+				}
+				if report.Refused > 0 {
+					log.Printf("Warning: plagiarism providers refused %d pending files", report.Refused)
+				}
+			}
+		}
+	}()
+}
+
+// Run checks every pending file. It stops as soon as no provider is
+// available, leaving the remaining files for the next run. Files the
+// providers refuse, such as ones too large for them, are recorded as
+// provider errors and passed over, so they do not hold up the rest.
+func (c *PlagiarismRechecker) Run(ctx context.Context) (RecheckReport, error) {
+	var report RecheckReport
+	var afterID uint
+
+	for {
+		files, err := c.files.FindPendingPlagiarism(afterID, c.batchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(files) == 0 {
+			return report, nil
+		}
+
+		for i := range files {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			file := &files[i]
+			result, err := c.Check(ctx, file)
+			if errors.Is(err, ErrObjectNotFound) {
+				log.Printf("Plagiarism re-check: object for file %d (%s) is missing", file.ID, file.StorageKey)
+				report.Missing++
+				continue
+			}
+			if refused(err) {
+				log.Printf("Plagiarism re-check: file %d (%s) was refused: %v", file.ID, file.OriginalName, err)
+				result = &PlagiarismResult{
+					Status:    PlagiarismProviderError,
+					CheckedAt: time.Now(),
+					Raw:       refusal(err),
+				}
+			} else if err != nil {
+				return report, fmt.Errorf("file %d: %w", file.ID, err)
+			}
+
+			recorded, err := c.files.RecordPlagiarism(c.reportFor(file, result))
+			if err != nil {
+				return report, err
+			}
+			switch {
+			case !recorded:
+			case result.Status == PlagiarismProviderError:
+				report.Refused++
+			default:
+				report.Checked++
+				if result.Flagged {
+					report.Flagged++
+				}
+			}
+		}
+		afterID = files[len(files)-1].ID
+	}
+}
+
+// Check downloads a stored file and submits it to the checker.
+func (c *PlagiarismRechecker) Check(ctx context.Context, file *model.File) (*PlagiarismResult, error) {
+	path, err := c.download(file)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	result, err := c.checker.Check(ctx, PlagiarismRequest{
+		FilePath:    path,
+		FileName:    file.OriginalName,
+		ContentType: file.ContentType,
+		DocType:     file.DocType,
+		SHA256:      file.ChecksumSHA256,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.ApplyThreshold(c.thresholds.For(file.Intake, file.DocType))
+	return result, nil
+}
+
+func (c *PlagiarismRechecker) download(file *model.File) (string, error) {
+	body, err := c.aws.DownloadFile(file.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(c.tempDir, "recheck-*"+filepath.Ext(file.OriginalName))
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// refusal keeps why a file was refused in its report.
+func refusal(err error) json.RawMessage {
+	raw, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return raw
+}
+
+func (c *PlagiarismRechecker) reportFor(file *model.File, result *PlagiarismResult) *model.PlagiarismReport {
+	report := result.Report()
+	report.FileID = &file.ID
+	report.OriginalName = file.OriginalName
+	report.TeamID = file.TeamID
+	report.Intake = file.Intake
+	report.AcademicYear = file.AcademicYear
+	return &report
+}
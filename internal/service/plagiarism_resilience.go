@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProviderError is a response from a provider that carried no result.
+type ProviderError struct {
+	StatusCode int
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("provider responded with status %d", e.StatusCode)
+}
+
+// Temporary reports whether the same request may succeed later.
+func (e *ProviderError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// ErrCircuitOpen is returned without contacting a provider that failed
+// repeatedly and is being given time to recover.
+var ErrCircuitOpen = errors.New("circuit open after repeated failures")
+
+// RetryPolicy retries a check after connection errors, timeouts and 5xx
+// responses. The wait before retry n is random between zero and
+// BaseDelay * 2^n, capped at MaxDelay, so clients that failed together do
+// not retry together.
+type RetryPolicy struct {
+	// Retries is the number of attempts after the first
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (p RetryPolicy) delay(retry int) time.Duration {
+	ceiling := p.BaseDelay << retry
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+type retryingChecker struct {
+	checker PlagiarismChecker
+	policy  RetryPolicy
+}
+
+// NewRetryingChecker returns a checker that retries checker according to
+// policy. Responses that will not change, such as 4xx or unreadable
+// results, are returned straight away.
+func NewRetryingChecker(checker PlagiarismChecker, policy RetryPolicy) PlagiarismChecker {
+	return &retryingChecker{checker: checker, policy: policy}
+}
+
+func (c *retryingChecker) Name() string {
+	return c.checker.Name()
+}
+
+func (c *retryingChecker) Check(ctx context.Context, req PlagiarismRequest) (*PlagiarismResult, error) {
+	for retry := 0; ; retry++ {
+		result, err := c.checker.Check(ctx, req)
+		if err == nil || retry >= c.policy.Retries || !retryable(ctx, err) {
+			return result, err
+		}
+
+		timer := time.NewTimer(c.policy.delay(retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Temporary()
+	}
+	var invalid *invalidResponseError
+	return !errors.As(err, &invalid)
+}
+
+// refused reports whether a check failed in a way sending the same file
+// again will not change: a 4xx response, or a response that could not be
+// read. A chain has refused a file when every provider in it did.
+func refused(err error) bool {
+	var chain *chainError
+	if errors.As(err, &chain) {
+		for _, failure := range chain.failures {
+			if !refused(failure) {
+				return false
+			}
+		}
+		return true
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return !providerErr.Temporary()
+	}
+	var invalid *invalidResponseError
+	return errors.As(err, &invalid)
+}
+
+// invalidResponseError is a 200 response the checker could not read.
+type invalidResponseError struct {
+	err error
+}
+
+func (e *invalidResponseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidResponseError) Unwrap() error {
+	return e.err
+}
+
+type circuitBreaker struct {
+	checker  PlagiarismChecker
+	failures int
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	failed    int
+	openUntil time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker returns a checker that stops calling checker after
+// failures consecutive failed checks. Once cooldown has passed a single
+// check is let through; its success closes the circuit again and its
+// failure keeps it open for another cooldown. A failures of 0 disables the
+// breaker.
+func NewCircuitBreaker(checker PlagiarismChecker, failures int, cooldown time.Duration) PlagiarismChecker {
+	if failures <= 0 {
+		return checker
+	}
+	return &circuitBreaker{checker: checker, failures: failures, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) Name() string {
+	return b.checker.Name()
+}
+
+func (b *circuitBreaker) Check(ctx context.Context, req PlagiarismRequest) (*PlagiarismResult, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	result, err := b.checker.Check(ctx, req)
+	if ctx.Err() != nil {
+		// The caller gave up; that says nothing about the provider
+		b.release()
+		return result, err
+	}
+	b.record(err == nil)
+	return result, err
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failed < b.failures {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if ok {
+		b.failed = 0
+		return
+	}
+	b.failed++
+	if b.failed >= b.failures {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
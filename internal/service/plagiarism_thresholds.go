@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PlagiarismThresholds holds the highest accepted match percentage per
+// intake and document type.
+type PlagiarismThresholds struct {
+	Default float64                     `json:"default"`
+	Types   map[string]float64          `json:"types"`
+	Intakes map[string]IntakeThresholds `json:"intakes"`
+}
+
+// IntakeThresholds overrides the thresholds for one intake. Types without
+// an entry fall back to the intake's Default, then to the global ones.
+type IntakeThresholds struct {
+	Default *float64           `json:"default"`
+	Types   map[string]float64 `json:"types"`
+}
+
+// LoadPlagiarismThresholds reads thresholds from a JSON file. Without a file,
+// or when the file sets no default, fallback applies to everything.
+//
+//	{
+//	  "default": 15,
+//	  "types": {"literature_review": 30, "final_report": 10},
+//	  "intakes": {
+//	    "fall-25": {"default": 20, "types": {"proposal": 25}}
+//	  }
+//	}
+func LoadPlagiarismThresholds(path string, fallback float64) (*PlagiarismThresholds, error) {
+	t := &PlagiarismThresholds{Default: fallback}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plagiarism threshold file %s: %v", path, err)
+	}
+	var file struct {
+		Default *float64                    `json:"default"`
+		Types   map[string]float64          `json:"types"`
+		Intakes map[string]IntakeThresholds `json:"intakes"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse plagiarism threshold file %s: %v", path, err)
+	}
+
+	if file.Default != nil {
+		t.Default = *file.Default
+	}
+	t.Types = lowerKeys(file.Types)
+	t.Intakes = make(map[string]IntakeThresholds, len(file.Intakes))
+	for name, intake := range file.Intakes {
+		intake.Types = lowerKeys(intake.Types)
+		t.Intakes[strings.ToLower(name)] = intake
+	}
+
+	return t, nil
+}
+
+// For returns the threshold for a file of docType uploaded to intake.
+func (t *PlagiarismThresholds) For(intake string, docType string) float64 {
+	docType = strings.ToLower(docType)
+	if i, ok := t.Intakes[strings.ToLower(intake)]; ok {
+		if threshold, ok := i.Types[docType]; ok {
+			return threshold
+		}
+		if i.Default != nil {
+			return *i.Default
+		}
+	}
+	if threshold, ok := t.Types[docType]; ok {
+		return threshold
+	}
+	return t.Default
+}
+
+func lowerKeys(m map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// QuarantineStore keeps rejected uploads out of the bucket while leaving them
+// available for review.
+type QuarantineStore interface {
+	Quarantine(srcPath string, originalName string, reason string) (string, error)
+	// Discard removes a quarantined file once it is no longer held.
+	Discard(path string) error
+}
+
+type localQuarantine struct {
+	dir string
+}
+
+func NewLocalQuarantine(dir string) (QuarantineStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create quarantine directory %s: %v", dir, err)
+	}
+	return &localQuarantine{dir: dir}, nil
+}
+
+// Quarantine moves srcPath into the quarantine directory and writes a JSON
+// sidecar describing why it was held. It returns the quarantined path.
+func (q *localQuarantine) Quarantine(srcPath string, originalName string, reason string) (string, error) {
+	dstPath := filepath.Join(q.dir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(srcPath)))
+
+	if err := moveFile(srcPath, dstPath); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %v", srcPath, err)
+	}
+
+	meta, _ := json.MarshalIndent(map[string]interface{}{
+		"original_name":  originalName,
+		"reason":         reason,
+		"quarantined_at": time.Now().UTC(),
+	}, "", "  ")
+	if err := os.WriteFile(dstPath+".json", meta, 0600); err != nil {
+		return dstPath, fmt.Errorf("failed to write quarantine metadata: %v", err)
+	}
+
+	return dstPath, nil
+}
+
+func (q *localQuarantine) Discard(path string) error {
+	if filepath.Dir(path) != filepath.Clean(q.dir) {
+		return fmt.Errorf("%s is not in quarantine", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(path + ".json"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// moveFile renames src to dst, falling back to copy and delete when the
+// quarantine directory lives on a different filesystem.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/utils"
+)
+
+// RekeyReport summarises one re-keying run.
+type RekeyReport struct {
+	Planned   int
+	Unchanged int
+	Moved     int
+	Skipped   int
+}
+
+// Rekeyer moves stored objects to the keys given by a new layout. Every
+// move is recorded before it starts and advanced one state at a time, so an
+// interrupted run picks up where it stopped when started again with the
+// same layout.
+type Rekeyer struct {
+	aws       AWSService
+	moves     repository.RekeyRepository
+	keys      *KeyBuilder
+	batchSize int
+	// KeepSource leaves the objects at their old keys in place
+	KeepSource bool
+}
+
+func NewRekeyer(aws AWSService, moves repository.RekeyRepository, keys *KeyBuilder, batchSize int) *Rekeyer {
+	return &Rekeyer{
+		aws:       aws,
+		moves:     moves,
+		keys:      keys,
+		batchSize: batchSize,
+	}
+}
+
+// Run plans moves for files that have none for the layout yet and then
+// carries out every unfinished move.
+func (k *Rekeyer) Run(ctx context.Context) (RekeyReport, error) {
+	var report RekeyReport
+	if err := k.plan(ctx, &report); err != nil {
+		return report, err
+	}
+	err := k.apply(ctx, &report)
+	return report, err
+}
+
+// Preview logs the moves the next run would plan without recording them.
+func (k *Rekeyer) Preview(ctx context.Context) (RekeyReport, error) {
+	var report RekeyReport
+	err := k.eachUnplanned(ctx, func(move model.RekeyMove) {
+		if move.Status == model.RekeyDone {
+			report.Unchanged++
+			return
+		}
+		report.Planned++
+		log.Printf("file %d: %s -> %s", move.FileID, move.OldKey, move.NewKey)
+	}, false)
+	return report, err
+}
+
+func (k *Rekeyer) plan(ctx context.Context, report *RekeyReport) error {
+	return k.eachUnplanned(ctx, func(move model.RekeyMove) {
+		if move.Status == model.RekeyDone {
+			report.Unchanged++
+		} else {
+			report.Planned++
+		}
+	}, true)
+}
+
+// eachUnplanned works out the move for every file without one and passes it
+// to fn, recording each batch when save is set.
+func (k *Rekeyer) eachUnplanned(ctx context.Context, fn func(model.RekeyMove), save bool) error {
+	var afterID uint
+	for {
+		files, err := k.moves.FilesToPlan(k.keys.Layout(), afterID, k.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		moves := make([]model.RekeyMove, 0, len(files))
+		for i := range files {
+			move := k.moveFor(&files[i])
+			moves = append(moves, move)
+			fn(move)
+		}
+		if save {
+			if err := k.moves.CreateMoves(moves); err != nil {
+				return err
+			}
+		}
+		afterID = files[len(files)-1].ID
+	}
+}
+
+// moveFor picks the new key for file. Files whose key already fits the
+// layout are recorded as done without being moved.
+func (k *Rekeyer) moveFor(file *model.File) model.RekeyMove {
+	object := ObjectKey{
+		CreatedAt:    file.CreatedAt,
+		AcademicYear: file.AcademicYear,
+		Intake:       file.Intake,
+		TeamID:       file.TeamID,
+		Session:      file.Session,
+		DocType:      file.DocType,
+		Version:      file.Version,
+		Hash:         file.ChecksumSHA256,
+	}
+
+	move := model.RekeyMove{
+		Layout: k.keys.Layout(),
+		FileID: file.ID,
+		OldKey: file.StorageKey,
+		NewKey: file.StorageKey,
+		Status: model.RekeyDone,
+	}
+	if k.keys.Matches(file.StorageKey, object) {
+		return move
+	}
+
+	object.UUID = newUUID()
+	object.FileName = utils.UniqueFilename(file.OriginalName)
+	move.NewKey = k.keys.Build(object)
+	move.Status = model.RekeyPlanned
+	return move
+}
+
+func (k *Rekeyer) apply(ctx context.Context, report *RekeyReport) error {
+	var afterID uint
+	for {
+		moves, err := k.moves.Unfinished(k.keys.Layout(), afterID, k.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(moves) == 0 {
+			return nil
+		}
+
+		for i := range moves {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			move := &moves[i]
+			if err := k.advance(move); err != nil {
+				return fmt.Errorf("file %d: %w", move.FileID, err)
+			}
+			switch move.Status {
+			case model.RekeyDone:
+				report.Moved++
+			case model.RekeySkipped:
+				report.Skipped++
+			}
+		}
+		afterID = moves[len(moves)-1].ID
+	}
+}
+
+// advance takes move through its remaining states. The file record only
+// changes after the copy exists, and the old object is only removed after
+// the record points at the copy, so stopping between any two steps loses
+// nothing.
+func (k *Rekeyer) advance(move *model.RekeyMove) error {
+	for {
+		switch move.Status {
+		case model.RekeyPlanned:
+			err := k.aws.CopyFile(move.OldKey, move.NewKey)
+			if errors.Is(err, ErrObjectNotFound) {
+				log.Printf("Rekey: object %s for file %d is missing, skipping", move.OldKey, move.FileID)
+				return k.moves.UpdateStatus(move, model.RekeySkipped, "source object missing")
+			}
+			if err != nil {
+				return err
+			}
+			if err := k.moves.UpdateStatus(move, model.RekeyCopied, ""); err != nil {
+				return err
+			}
+
+		case model.RekeyCopied:
+			downloadURL, err := k.aws.GeneratePresignedURL(move.NewKey)
+			if err != nil {
+				return err
+			}
+			switched, err := k.moves.SwitchKey(move, downloadURL)
+			if err != nil {
+				return err
+			}
+			if !switched {
+				// The file was deleted or re-keyed elsewhere; drop the copy
+				log.Printf("Rekey: file %d no longer uses %s, removing copy", move.FileID, move.OldKey)
+				if err := k.aws.DeleteFile(move.NewKey); err != nil {
+					log.Printf("Warning: failed to remove copy %s: %v", move.NewKey, err)
+				}
+				return nil
+			}
+
+		case model.RekeySwitched:
+			if !k.KeepSource {
+				if err := k.aws.DeleteFile(move.OldKey); err != nil {
+					return err
+				}
+			}
+			return k.moves.UpdateStatus(move, model.RekeyDone, "")
+
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/repository"
+)
+
+// ReviewExpirer closes the reviews of batch submission files that were
+// neither overridden nor resubmitted in time, and discards their
+// quarantined copies.
+type ReviewExpirer struct {
+	reviews    repository.PlagiarismReviewRepository
+	quarantine QuarantineStore
+	batchSize  int
+}
+
+func NewReviewExpirer(reviews repository.PlagiarismReviewRepository, quarantine QuarantineStore, batchSize int) *ReviewExpirer {
+	return &ReviewExpirer{
+		reviews:    reviews,
+		quarantine: quarantine,
+		batchSize:  batchSize,
+	}
+}
+
+// Start expires reviews every interval until ctx is done.
+func (e *ReviewExpirer) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				expired, err := e.Run(ctx, time.Now())
+				if err != nil {
+					log.Printf("Warning: plagiarism review expiry stopped early: %v", err)
+				}
+				if expired > 0 {
+					log.Printf("Closed %d expired batch submission reviews", expired)
+				}
+			}
+		}
+	}()
+}
+
+// Run closes the reviews that expired before now and returns how many.
+func (e *ReviewExpirer) Run(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	for ctx.Err() == nil {
+		reviews, err := e.reviews.FindExpired(now, e.batchSize)
+		if err != nil || len(reviews) == 0 {
+			return expired, err
+		}
+		for _, review := range reviews {
+			closed, err := e.reviews.Expire(review.ID, now)
+			if err != nil {
+				return expired, err
+			}
+			if !closed {
+				continue
+			}
+			expired++
+			if err := e.quarantine.Discard(review.QuarantinePath); err != nil {
+				log.Printf("Warning: failed to remove quarantined copy of review %d: %v", review.ID, err)
+			}
+		}
+	}
+	return expired, ctx.Err()
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+type ScanStatus string
+
+const (
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+	ScanSkipped  ScanStatus = "skipped"
+	// ScanTooLarge is a file clamd stopped reading at its StreamMaxLength,
+	// so it was never scanned
+	ScanTooLarge ScanStatus = "too_large"
+)
+
+type ScanResult struct {
+	Status    ScanStatus `json:"status"`
+	Signature string     `json:"signature,omitempty"`
+	Engine    string     `json:"engine"`
+	ScannedAt time.Time  `json:"scanned_at"`
+}
+
+// Scanner checks a byte stream for malware before it is stored.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+}
+
+// clamd closes the stream once it exceeds StreamMaxLength, so chunks stay
+// well below the default limit.
+const clamdChunkSize = 64 * 1024
+
+// clamdSizeLimitReply is clamd's answer to a stream longer than its
+// StreamMaxLength.
+const clamdSizeLimitReply = "INSTREAM size limit exceeded."
+
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a Scanner that talks to clamd using the INSTREAM
+// command. The address is either "tcp://host:port" or "unix:///path/to/socket".
+func NewClamdScanner(address string, timeout time.Duration) (Scanner, error) {
+	network, addr, err := parseClamdAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clamdScanner{
+		network: network,
+		address: addr,
+		timeout: timeout,
+	}, nil
+}
+
+func parseClamdAddress(address string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		return "tcp", strings.TrimPrefix(address, "tcp://"), nil
+	case strings.HasPrefix(address, "unix://"):
+		return "unix", strings.TrimPrefix(address, "unix://"), nil
+	case strings.HasPrefix(address, "/"):
+		return "unix", address, nil
+	case address != "":
+		return "tcp", address, nil
+	default:
+		return "", "", fmt.Errorf("empty clamd address")
+	}
+}
+
+func (s *clamdScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd at %s: %w", s.address, err)
+	}
+	defer conn.Close()
+
+	if s.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send INSTREAM command: %w", err)
+	}
+
+	// Each chunk is prefixed with its length as a 4 byte big-endian integer,
+	// and a zero-length chunk terminates the stream.
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return streamFailed(conn, "failed to stream chunk to clamd", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return streamFailed(conn, "failed to stream chunk to clamd", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read file for scanning: %w", readErr)
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return streamFailed(conn, "failed to terminate clamd stream", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamdReply(reply)
+}
+
+// streamFailed handles a write error while streaming. clamd replies and
+// closes the connection as soon as a stream passes its StreamMaxLength, so
+// the reply is read before the write error is reported.
+func streamFailed(conn net.Conn, message string, err error) (*ScanResult, error) {
+	reply, _ := bufio.NewReader(conn).ReadString(0)
+	if result, replyErr := parseClamdReply(reply); replyErr == nil && result.Status == ScanTooLarge {
+		return result, nil
+	}
+	return nil, fmt.Errorf("%s: %w", message, err)
+}
+
+// parseClamdReply understands the three INSTREAM answers:
+//
+//	stream: OK
+//	stream: Eicar-Signature FOUND
+//	INSTREAM size limit exceeded. ERROR
+func parseClamdReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	result := &ScanResult{
+		Engine:    "clamd",
+		ScannedAt: time.Now(),
+	}
+
+	switch {
+	case reply == "OK":
+		result.Status = ScanClean
+	case strings.HasSuffix(reply, " FOUND"):
+		result.Status = ScanInfected
+		result.Signature = strings.TrimSuffix(reply, " FOUND")
+	case strings.HasPrefix(reply, clamdSizeLimitReply):
+		result.Status = ScanTooLarge
+	case strings.HasSuffix(reply, " ERROR"):
+		return nil, fmt.Errorf("clamd error: %s", strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return nil, fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+
+	return result, nil
+}
+
+type noopScanner struct{}
+
+// NewNoopScanner returns a Scanner that accepts everything. It is used when
+// scanning is disabled so the upload pipeline can still record a result.
+func NewNoopScanner() Scanner {
+	return noopScanner{}
+}
+
+func (noopScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	return &ScanResult{
+		Status:    ScanSkipped,
+		Engine:    "none",
+		ScannedAt: time.Now(),
+	}, nil
+}
+
+// EICARTestSignature is the standard antivirus test file. Every engine,
+// including the fake clamd in clamdtest, reports it as infected.
+var EICARTestSignature = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
@@ -0,0 +1,218 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/service"
+	"github.com/sohan-reza/capstone-core/internal/service/clamdtest"
+)
+
+func startClamd(t *testing.T, signatures map[string][]byte) (*clamdtest.Server, service.Scanner) {
+	t.Helper()
+	server, err := clamdtest.NewServer(signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	scanner, err := service.NewClamdScanner(server.Address(), 10*time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	return server, scanner
+}
+
+func TestClamdScanner(t *testing.T) {
+	// Streams longer than one chunk check the framing across chunks
+	large := bytes.Repeat([]byte("capstone "), 20000)
+	infectedLate := append(append([]byte{}, large...), service.EICARTestSignature...)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		status    service.ScanStatus
+		signature string
+	}{
+		{"clean", []byte("%PDF-1.7 final report"), service.ScanClean, ""},
+		{"empty", nil, service.ScanClean, ""},
+		{"clean over several chunks", large, service.ScanClean, ""},
+		{"EICAR", service.EICARTestSignature, service.ScanInfected, "Eicar-Signature"},
+		{"EICAR in the last chunk", infectedLate, service.ScanInfected, "Eicar-Signature"},
+		{"extra signature", []byte("contains BADBYTES here"), service.ScanInfected, "Test.Bad"},
+	}
+
+	server, scanner := startClamd(t, map[string][]byte{"Test.Bad": []byte("BADBYTES")})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Status != tt.status || result.Signature != tt.signature {
+				t.Errorf("Scan = %s %q, want %s %q", result.Status, result.Signature, tt.status, tt.signature)
+			}
+			if result.Engine != "clamd" {
+				t.Errorf("Engine = %q, want clamd", result.Engine)
+			}
+		})
+	}
+	if got := server.Scanned(); got != len(tests) {
+		t.Errorf("server scanned %d streams, want %d", got, len(tests))
+	}
+}
+
+func TestClamdScannerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	server, err := clamdtest.NewUnixServer(socket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	for _, address := range []string{"unix://" + socket, socket} {
+		scanner, err := service.NewClamdScanner(address, 10*time.Second)
+		if err != nil {
+			t.Fatalf("NewClamdScanner(%q): %v", address, err)
+		}
+		result, err := scanner.Scan(context.Background(), bytes.NewReader(service.EICARTestSignature))
+		if err != nil {
+			t.Fatalf("Scan via %q: %v", address, err)
+		}
+		if result.Status != service.ScanInfected {
+			t.Errorf("Scan via %q = %s, want %s", address, result.Status, service.ScanInfected)
+		}
+	}
+}
+
+func TestClamdScannerSizeLimit(t *testing.T) {
+	server, scanner := startClamd(t, nil)
+	server.SetStreamMaxLength(1 << 20)
+
+	tests := []struct {
+		name   string
+		size   int
+		status service.ScanStatus
+	}{
+		{"at the limit", 1 << 20, service.ScanClean},
+		{"just over the limit", 1<<20 + 1, service.ScanTooLarge},
+		// clamd hangs up while the rest is still being sent
+		{"far over the limit", 16 << 20, service.ScanTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, tt.size)))
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Status != tt.status {
+				t.Errorf("Scan = %s, want %s", result.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestClamdScannerConnectionDropped(t *testing.T) {
+	server, scanner := startClamd(t, nil)
+	server.DropAfter(1 << 20)
+
+	for _, size := range []int{2 << 20, 16 << 20} {
+		result, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, size)))
+		if err == nil {
+			t.Errorf("Scan of %d bytes = %s, want an error", size, result.Status)
+		}
+	}
+}
+
+func TestClamdScannerUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	scanner, err := service.NewClamdScanner(address, time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Error("Scan with clamd down succeeded, want an error")
+	}
+
+	if _, err := service.NewClamdScanner("", time.Second); err == nil {
+		t.Error("NewClamdScanner accepted an empty address")
+	}
+}
+
+// replyWith serves one connection, reads the stream and answers with reply.
+func replyWith(t *testing.T, reply string) service.Scanner {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		var received []byte
+		for !bytes.HasSuffix(received, []byte{0, 0, 0, 0}) {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			received = append(received, buf[:n]...)
+		}
+		conn.Write(append([]byte(reply), 0))
+	}()
+
+	scanner, err := service.NewClamdScanner(listener.Addr().String(), 10*time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	return scanner
+}
+
+func TestClamdReplies(t *testing.T) {
+	tests := []struct {
+		reply     string
+		status    service.ScanStatus
+		signature string
+		wantErr   string
+	}{
+		{"stream: OK", service.ScanClean, "", ""},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", service.ScanInfected, "Win.Test.EICAR_HDB-1", ""},
+		{"INSTREAM size limit exceeded. ERROR", service.ScanTooLarge, "", ""},
+		{"stream: Can't allocate memory ERROR", "", "", "clamd error: Can't allocate memory"},
+		{"UNKNOWN COMMAND", "", "", "unexpected clamd reply"},
+		{"", "", "", "unexpected clamd reply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			result, err := replyWith(t, tt.reply).Scan(context.Background(), strings.NewReader("data"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scan error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Status != tt.status || result.Signature != tt.signature {
+				t.Errorf("Scan = %s %q, want %s %q", result.Status, result.Signature, tt.status, tt.signature)
+			}
+		})
+	}
+}
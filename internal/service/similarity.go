@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/similarity"
+)
+
+const (
+	// similarityCandidates bounds how many indexed documents are scored
+	// against their signatures for one upload.
+	similarityCandidates = 50
+	// minMatchPercent drops matches that share only stock phrases.
+	minMatchPercent = 1.0
+	maxPassages     = 5
+)
+
+// SimilarityMatch is an earlier file that shares text with the upload.
+type SimilarityMatch struct {
+	repository.SimilarityCandidate
+	// Percent is the share of the upload's words found in this file
+	Percent  float64              `json:"percent"`
+	Passages []similarity.Passage `json:"passages"`
+}
+
+type SimilarityReport struct {
+	// Percent is the highest match percentage
+	Percent   float64           `json:"percent"`
+	Matches   []SimilarityMatch `json:"matches"`
+	CheckedAt time.Time         `json:"checked_at"`
+
+	// Threshold and Flagged are filled in by the stage that applied the
+	// threshold
+	Threshold float64 `json:"threshold"`
+	Flagged   bool    `json:"flagged"`
+}
+
+// Report converts the similarity report into the record kept for the check.
+func (r *SimilarityReport) Report() model.PlagiarismReport {
+	report := model.PlagiarismReport{
+		Checker:   model.CheckerSimilarity,
+		Status:    string(PlagiarismChecked),
+		Threshold: r.Threshold,
+		Flagged:   r.Flagged,
+		CheckedAt: r.CheckedAt,
+	}
+	score := r.Percent
+	report.Score = &score
+
+	for _, match := range r.Matches {
+		fileID := match.FileID
+		source := model.PlagiarismSource{
+			FileID:       &fileID,
+			Title:        match.OriginalName,
+			TeamID:       match.TeamID,
+			Intake:       match.Intake,
+			AcademicYear: match.AcademicYear,
+			Percent:      match.Percent,
+		}
+		for _, passage := range match.Passages {
+			source.Passages = append(source.Passages, model.PlagiarismPassage{
+				Text:    passage.Text,
+				Words:   passage.Words,
+				Percent: passage.Percent,
+			})
+		}
+		report.Sources = append(report.Sources, source)
+	}
+	if raw, err := json.Marshal(r); err == nil {
+		report.RawPayload = raw
+	}
+	return report
+}
+
+// SimilarityEngine compares documents against the signatures of every
+// earlier file, across teams and academic years.
+type SimilarityEngine struct {
+	signatures repository.SignatureRepository
+	topMatches int
+}
+
+func NewSimilarityEngine(signatures repository.SignatureRepository, topMatches int) *SimilarityEngine {
+	return &SimilarityEngine{
+		signatures: signatures,
+		topMatches: topMatches,
+	}
+}
+
+// Check compares text with the indexed files of other teams, and of the same
+// team in other intakes. It returns the report and the signature to store
+// with the file, or nils when the text is too short to compare.
+func (e *SimilarityEngine) Check(text string, teamID string, intake string) (*SimilarityReport, *model.DocumentSignature, error) {
+	doc := similarity.NewDocument(text)
+	signature, sig := e.sign(doc)
+	if signature == nil {
+		return nil, nil, nil
+	}
+
+	candidates, err := e.signatures.Candidates(sig.BandKeys(), teamID, intake, similarityCandidates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Rank by the estimate from the signatures, then compare the best
+	// candidates word by word for exact figures and passages
+	type scored struct {
+		candidate repository.SimilarityCandidate
+		estimate  float64
+	}
+	ranked := make([]scored, 0, len(candidates))
+	for _, c := range candidates {
+		jaccard := sig.Jaccard(similarity.SignatureFromBytes(c.MinHash))
+		if jaccard > 0 {
+			ranked = append(ranked, scored{c, similarity.Containment(jaccard, signature.ShingleCount, c.ShingleCount)})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].estimate > ranked[j].estimate })
+	if len(ranked) > e.topMatches*2 {
+		ranked = ranked[:e.topMatches*2]
+	}
+
+	ids := make([]uint, 0, len(ranked))
+	for _, r := range ranked {
+		ids = append(ids, r.candidate.FileID)
+	}
+	texts, err := e.signatures.Texts(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &SimilarityReport{Matches: []SimilarityMatch{}, CheckedAt: time.Now()}
+	for _, r := range ranked {
+		overlap := doc.Compare(similarity.NewDocument(texts[r.candidate.FileID]), maxPassages)
+		if overlap.Percent < minMatchPercent {
+			continue
+		}
+		report.Matches = append(report.Matches, SimilarityMatch{
+			SimilarityCandidate: r.candidate,
+			Percent:             overlap.Percent,
+			Passages:            overlap.Passages,
+		})
+	}
+	sort.SliceStable(report.Matches, func(i, j int) bool { return report.Matches[i].Percent > report.Matches[j].Percent })
+	if len(report.Matches) > e.topMatches {
+		report.Matches = report.Matches[:e.topMatches]
+	}
+	if len(report.Matches) > 0 {
+		report.Percent = report.Matches[0].Percent
+	}
+
+	return report, signature, nil
+}
+
+// Backfill indexes files stored before the engine was enabled and returns
+// how many were added.
+func (e *SimilarityEngine) Backfill(ctx context.Context, batchSize int) (int, error) {
+	indexed := 0
+	var afterID uint
+	for {
+		docs, err := e.signatures.Unsigned(afterID, batchSize)
+		if err != nil || len(docs) == 0 {
+			return indexed, err
+		}
+
+		for _, doc := range docs {
+			if err := ctx.Err(); err != nil {
+				return indexed, err
+			}
+			signature, _ := e.sign(similarity.NewDocument(doc.Text))
+			if signature == nil {
+				continue
+			}
+			signature.FileID = doc.FileID
+			if err := e.signatures.Create(signature); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+		afterID = docs[len(docs)-1].ID
+	}
+}
+
+func (e *SimilarityEngine) sign(doc *similarity.Document) (*model.DocumentSignature, similarity.Signature) {
+	shingles := len(doc.Set())
+	if shingles < similarity.MinShingles {
+		return nil, nil
+	}
+
+	sig := doc.Sign()
+	signature := &model.DocumentSignature{
+		ShingleCount: shingles,
+		MinHash:      sig.Bytes(),
+	}
+	for _, key := range sig.BandKeys() {
+		signature.Bands = append(signature.Bands, model.SimilarityBand{Key: key})
+	}
+	return signature, sig
+}
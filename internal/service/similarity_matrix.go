@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/similarity"
+)
+
+const (
+	BasisText = "text"
+	BasisCode = "code"
+)
+
+// SimilarityMatrixBuilder compares the latest documents of every team in an
+// intake with each other, using the text and code fingerprints stored when
+// the files were uploaded.
+type SimilarityMatrixBuilder struct {
+	matrices   repository.SimilarityMatrixRepository
+	signatures repository.SignatureRepository
+	code       repository.CodeSignatureRepository
+
+	// Matrices are leased to worker while it computes them
+	worker string
+	lease  time.Duration
+	heldMu sync.Mutex
+	held   map[uint]bool
+}
+
+func NewSimilarityMatrixBuilder(matrices repository.SimilarityMatrixRepository, signatures repository.SignatureRepository, code repository.CodeSignatureRepository, worker string, lease time.Duration) *SimilarityMatrixBuilder {
+	return &SimilarityMatrixBuilder{
+		matrices:   matrices,
+		signatures: signatures,
+		code:       code,
+		worker:     worker,
+		lease:      lease,
+		held:       make(map[uint]bool),
+	}
+}
+
+// Start computes a queued matrix in the background.
+func (b *SimilarityMatrixBuilder) Start(ctx context.Context, matrix *model.SimilarityMatrix) {
+	go b.Run(ctx, matrix)
+}
+
+// Resume renews the leases on the matrices this instance computes and takes
+// over those whose lease has expired, left by an instance that stopped,
+// until ctx is done.
+func (b *SimilarityMatrixBuilder) Resume(ctx context.Context) error {
+	// Matrices left by an earlier run of this instance need not wait for
+	// their lease to expire
+	if err := b.matrices.ReleaseLeases(b.worker); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(b.lease / 3)
+		defer ticker.Stop()
+		for {
+			b.renewLeases()
+			b.takeOverAbandoned(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *SimilarityMatrixBuilder) renewLeases() {
+	b.heldMu.Lock()
+	ids := make([]uint, 0, len(b.held))
+	for id := range b.held {
+		ids = append(ids, id)
+	}
+	b.heldMu.Unlock()
+
+	if err := b.matrices.RenewLeases(b.worker, ids, time.Now().Add(b.lease)); err != nil {
+		log.Printf("Warning: failed to renew similarity matrix leases: %v", err)
+	}
+}
+
+func (b *SimilarityMatrixBuilder) takeOverAbandoned(ctx context.Context) {
+	matrices, err := b.matrices.FindAbandoned(time.Now())
+	if err != nil {
+		log.Printf("Warning: failed to load abandoned similarity matrices: %v", err)
+		return
+	}
+	for i := range matrices {
+		b.Start(ctx, &matrices[i])
+	}
+}
+
+// hold claims matrix for this instance, unless it already computes it.
+func (b *SimilarityMatrixBuilder) hold(id uint) bool {
+	b.heldMu.Lock()
+	defer b.heldMu.Unlock()
+	if b.held[id] {
+		return false
+	}
+	claimed, err := b.matrices.Claim(id, b.worker, time.Now().Add(b.lease))
+	if err != nil {
+		log.Printf("Warning: failed to claim similarity matrix %d: %v", id, err)
+		return false
+	}
+	if claimed {
+		b.held[id] = true
+	}
+	return claimed
+}
+
+func (b *SimilarityMatrixBuilder) release(id uint) {
+	b.heldMu.Lock()
+	delete(b.held, id)
+	b.heldMu.Unlock()
+}
+
+// Run computes matrix and records the result or the failure. It does
+// nothing when another instance holds the matrix.
+func (b *SimilarityMatrixBuilder) Run(ctx context.Context, matrix *model.SimilarityMatrix) {
+	if !b.hold(matrix.ID) {
+		return
+	}
+	defer b.release(matrix.ID)
+
+	matrix.Status = model.JobProcessing
+	if err := b.matrices.Update(matrix); err != nil {
+		log.Printf("Warning: failed to update similarity matrix %d: %v", matrix.ID, err)
+	}
+
+	result, err := b.Build(ctx, matrix.Intake, strings.Split(matrix.DocTypes, ","), matrix.Cutoff)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down: the matrix stays queued for the next instance to
+		// take over once the lease expires
+		matrix.Status = model.JobQueued
+		if err := b.matrices.Update(matrix); err != nil {
+			log.Printf("Warning: failed to update similarity matrix %d: %v", matrix.ID, err)
+		}
+		return
+	}
+	now := time.Now()
+	matrix.CompletedAt = &now
+	if err != nil {
+		log.Printf("Similarity matrix %d for %s failed: %v", matrix.ID, matrix.Intake, err)
+		matrix.Status = model.JobFailed
+		matrix.Error = err.Error()
+	} else {
+		matrix.Status = model.JobSucceeded
+		matrix.Result = result
+	}
+	if err := b.matrices.Update(matrix); err != nil {
+		log.Printf("Warning: failed to save similarity matrix %d: %v", matrix.ID, err)
+	}
+}
+
+// matrixDocument is a document and the set of hashes it is compared by:
+// text shingles or code fingerprints.
+type matrixDocument struct {
+	model.MatrixDocument
+	set map[uint64]bool
+}
+
+// Build compares every pair of documents of the same type from different
+// teams. Similarity is the share of the smaller document found in the
+// other, so a short document copied into a longer one still scores high.
+func (b *SimilarityMatrixBuilder) Build(ctx context.Context, intake string, docTypes []string, cutoff float64) (*model.MatrixResult, error) {
+	files, err := b.matrices.LatestFiles(intake, docTypes)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, f.ID)
+	}
+	texts, err := b.signatures.Texts(ids)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := b.code.Hashes(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.MatrixResult{
+		Teams:     []string{},
+		Documents: []model.MatrixDocument{},
+		Pairs:     []model.MatrixPair{},
+		Clusters:  []model.MatrixCluster{},
+	}
+	var docs []matrixDocument
+	teams := make(map[string]bool)
+	for _, f := range files {
+		doc := matrixDocument{MatrixDocument: model.MatrixDocument{
+			FileID:       f.ID,
+			TeamID:       f.TeamID,
+			OriginalName: f.OriginalName,
+			DocType:      f.DocType,
+		}}
+		if set := similarity.NewDocument(texts[f.ID]).Set(); len(set) >= similarity.MinShingles {
+			doc.Basis, doc.set = BasisText, set
+		} else if len(hashes[f.ID]) > 0 {
+			doc.set = make(map[uint64]bool, len(hashes[f.ID]))
+			for _, h := range hashes[f.ID] {
+				doc.set[uint64(h)] = true
+			}
+			doc.Basis = BasisCode
+		} else {
+			continue
+		}
+		docs = append(docs, doc)
+		result.Documents = append(result.Documents, doc.MatrixDocument)
+		if !teams[f.TeamID] {
+			teams[f.TeamID] = true
+			result.Teams = append(result.Teams, f.TeamID)
+		}
+	}
+	sort.Strings(result.Teams)
+
+	best := make(map[[2]string]model.MatrixPair)
+	for i := range docs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for j := i + 1; j < len(docs); j++ {
+			a, c := docs[i], docs[j]
+			if a.TeamID == c.TeamID || a.DocType != c.DocType || a.Basis != c.Basis {
+				continue
+			}
+			if a.TeamID > c.TeamID {
+				a, c = c, a
+			}
+			score := containment(a.set, c.set)
+			key := [2]string{a.TeamID, c.TeamID}
+			if score == 0 || score <= best[key].Similarity {
+				continue
+			}
+			best[key] = model.MatrixPair{
+				TeamA:      a.TeamID,
+				TeamB:      c.TeamID,
+				Similarity: score,
+				Basis:      a.Basis,
+				DocType:    a.DocType,
+				FileA:      a.FileID,
+				FileB:      c.FileID,
+			}
+		}
+	}
+	for _, pair := range best {
+		result.Pairs = append(result.Pairs, pair)
+	}
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		pi, pj := result.Pairs[i], result.Pairs[j]
+		if pi.Similarity != pj.Similarity {
+			return pi.Similarity > pj.Similarity
+		}
+		if pi.TeamA != pj.TeamA {
+			return pi.TeamA < pj.TeamA
+		}
+		return pi.TeamB < pj.TeamB
+	})
+
+	result.Clusters = clusterTeams(result.Pairs, cutoff)
+	return result, nil
+}
+
+// containment returns the percentage of the smaller set found in the
+// larger one.
+func containment(a map[uint64]bool, b map[uint64]bool) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for h := range a {
+		if b[h] {
+			shared++
+		}
+	}
+	return percentOf(shared, len(a))
+}
+
+// clusterTeams groups the teams connected by pairs at or above cutoff. Pairs
+// must be sorted most similar first.
+func clusterTeams(pairs []model.MatrixPair, cutoff float64) []model.MatrixCluster {
+	parent := make(map[string]string)
+	var find func(team string) string
+	find = func(team string) string {
+		if p, ok := parent[team]; ok && p != team {
+			parent[team] = find(p)
+			return parent[team]
+		}
+		parent[team] = team
+		return team
+	}
+
+	var linked []model.MatrixPair
+	for _, pair := range pairs {
+		if pair.Similarity < cutoff {
+			break
+		}
+		linked = append(linked, pair)
+		parent[find(pair.TeamA)] = find(pair.TeamB)
+	}
+
+	byRoot := make(map[string]*model.MatrixCluster)
+	var roots []string
+	for _, pair := range linked {
+		root := find(pair.TeamA)
+		cluster, ok := byRoot[root]
+		if !ok {
+			// The first pair seen is the cluster's most similar
+			cluster = &model.MatrixCluster{MaxSimilarity: pair.Similarity}
+			byRoot[root] = cluster
+			roots = append(roots, root)
+		}
+		cluster.Teams = append(cluster.Teams, pair.TeamA, pair.TeamB)
+	}
+
+	clusters := make([]model.MatrixCluster, 0, len(roots))
+	for _, root := range roots {
+		cluster := byRoot[root]
+		slices.Sort(cluster.Teams)
+		cluster.Teams = slices.Compact(cluster.Teams)
+		clusters = append(clusters, *cluster)
+	}
+	return clusters
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/utils"
+)
+
+// DefaultKeyLayout is the layout objects were stored under before it became
+// configurable.
+const DefaultKeyLayout = "projects/{year}/{intake}/{team}/{file}"
+
+// ObjectKey holds the values a key layout can refer to.
+type ObjectKey struct {
+	// CreatedAt gives {year}, the calendar year the file was stored in
+	CreatedAt    time.Time
+	AcademicYear string
+	Intake       string
+	TeamID       string
+	Session      string
+	DocType      string
+	Version      int
+	// Hash is the hex SHA-256 digest of the content
+	Hash string
+	UUID string
+	// FileName is used as given; callers pass a sanitized, unique name
+	FileName string
+}
+
+// keyPlaceholders maps each placeholder to the value it is replaced with.
+// Empty values are written as "none" so a key never has empty segments.
+var keyPlaceholders = map[string]func(ObjectKey) string{
+	"year": func(k ObjectKey) string {
+		if k.CreatedAt.IsZero() {
+			return ""
+		}
+		return strconv.Itoa(k.CreatedAt.Year())
+	},
+	"academic_year": func(k ObjectKey) string { return k.AcademicYear },
+	"intake":        func(k ObjectKey) string { return k.Intake },
+	"team":          func(k ObjectKey) string { return k.TeamID },
+	"session":       func(k ObjectKey) string { return k.Session },
+	"doc_type":      func(k ObjectKey) string { return k.DocType },
+	"version":       func(k ObjectKey) string { return "v" + strconv.Itoa(max(k.Version, 1)) },
+	"hash":          func(k ObjectKey) string { return k.Hash },
+	"uuid":          func(k ObjectKey) string { return k.UUID },
+	"file":          func(k ObjectKey) string { return k.FileName },
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{([a-z_]*)\}`)
+	layoutTextPattern  = regexp.MustCompile(`^[A-Za-z0-9/_.-]*$`)
+)
+
+// KeyBuilder builds object keys from a layout template such as
+// "projects/{academic_year}/{intake}/{team}/{doc_type}/{version}/{file}".
+//
+// Supported placeholders are {year} (the calendar year), {academic_year},
+// {intake}, {team}, {session}, {doc_type}, {version}, {hash}, {uuid} and
+// {file}. The layout must contain {file}, which carries the extension and
+// keeps keys unique.
+type KeyBuilder struct {
+	layout string
+}
+
+func NewKeyBuilder(layout string) (*KeyBuilder, error) {
+	layout = strings.TrimSpace(layout)
+	if layout == "" {
+		layout = DefaultKeyLayout
+	}
+
+	for _, m := range placeholderPattern.FindAllStringSubmatch(layout, -1) {
+		if _, ok := keyPlaceholders[m[1]]; !ok {
+			return nil, fmt.Errorf("key layout %q: unknown placeholder %s", layout, m[0])
+		}
+	}
+	if !strings.Contains(layout, "{file}") {
+		return nil, fmt.Errorf("key layout %q: must contain {file}", layout)
+	}
+
+	text := placeholderPattern.ReplaceAllString(layout, "x")
+	if !layoutTextPattern.MatchString(text) {
+		return nil, fmt.Errorf("key layout %q: only letters, digits, '/', '_', '.' and '-' are allowed outside placeholders", layout)
+	}
+	if strings.HasPrefix(text, "/") || strings.HasSuffix(text, "/") || strings.Contains(text, "//") {
+		return nil, fmt.Errorf("key layout %q: segments must not be empty", layout)
+	}
+	for _, segment := range strings.Split(text, "/") {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("key layout %q: relative segments are not allowed", layout)
+		}
+	}
+
+	return &KeyBuilder{layout: layout}, nil
+}
+
+// Layout returns the template keys are built from.
+func (b *KeyBuilder) Layout() string {
+	return b.layout
+}
+
+// Build returns the key for k. Every value is sanitized so it stays within
+// its own path segment.
+func (b *KeyBuilder) Build(k ObjectKey) string {
+	return placeholderPattern.ReplaceAllStringFunc(b.layout, func(placeholder string) string {
+		return b.value(strings.Trim(placeholder, "{}"), k)
+	})
+}
+
+// Matches reports whether key could have been built from k with this
+// layout. {uuid} and {file} accept any single segment since they are not
+// derived from the file's attributes. Keys stored before layouts were
+// configurable are recognised too: their values were not sanitized, and
+// values those files have no record of, such as their intake, accept any
+// segment, empty ones included.
+func (b *KeyBuilder) Matches(key string, k ObjectKey) bool {
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringSubmatchIndex(b.layout, -1) {
+		expr.WriteString(regexp.QuoteMeta(b.layout[last:loc[0]]))
+		name := b.layout[loc[2]:loc[3]]
+		raw := keyPlaceholders[name](k)
+		switch {
+		case name == "uuid" || name == "file":
+			expr.WriteString("[^/]+")
+		case raw == "":
+			expr.WriteString("[^/]*")
+		default:
+			expr.WriteString("(?:" + regexp.QuoteMeta(b.value(name, k)) + "|" + regexp.QuoteMeta(raw) + ")")
+		}
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(b.layout[last:]))
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String()).MatchString(key)
+}
+
+func (b *KeyBuilder) value(placeholder string, k ObjectKey) string {
+	value := keyPlaceholders[placeholder](k)
+	if value == "" {
+		return "none"
+	}
+	return utils.SanitizeFilename(value)
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/sohan-reza/capstone-core/internal/model"
+	"github.com/sohan-reza/capstone-core/internal/repository"
+	"github.com/sohan-reza/capstone-core/internal/topics"
+)
+
+const (
+	TopicUnique  = "unique"
+	TopicWarn    = "warn"
+	TopicBlocked = "block"
+
+	topicKeywords  = 10
+	sharedKeywords = 5
+)
+
+// TopicMatch is an earlier project on a similar topic.
+type TopicMatch struct {
+	repository.TopicDocument
+	// Similarity is the cosine similarity of the two documents' TF-IDF
+	// vectors, as a percentage
+	Similarity     float64  `json:"similarity"`
+	SharedKeywords []string `json:"shared_keywords"`
+}
+
+type TopicReport struct {
+	Keywords []string `json:"keywords"`
+	// Similarity is the highest match similarity
+	Similarity float64      `json:"similarity"`
+	Matches    []TopicMatch `json:"matches"`
+	CheckedAt  time.Time    `json:"checked_at"`
+
+	// The thresholds and the resulting action are filled in by Apply
+	WarnThreshold  float64 `json:"warn_threshold"`
+	BlockThreshold float64 `json:"block_threshold"`
+	Action         string  `json:"action"`
+}
+
+// Apply decides whether the topic is unique enough. A threshold of 0 is
+// disabled.
+func (r *TopicReport) Apply(warn float64, block float64) {
+	r.WarnThreshold = warn
+	r.BlockThreshold = block
+	switch {
+	case block > 0 && r.Similarity >= block:
+		r.Action = TopicBlocked
+	case warn > 0 && r.Similarity >= warn:
+		r.Action = TopicWarn
+	default:
+		r.Action = TopicUnique
+	}
+}
+
+// Report converts the topic report into the record kept for the check. It
+// is flagged when the topic warrants a warning or was blocked.
+func (r *TopicReport) Report() model.PlagiarismReport {
+	report := model.PlagiarismReport{
+		Checker:   model.CheckerTopic,
+		Status:    string(PlagiarismChecked),
+		Threshold: r.WarnThreshold,
+		Flagged:   r.Action != TopicUnique,
+		CheckedAt: r.CheckedAt,
+	}
+	if r.Action == TopicBlocked {
+		report.Threshold = r.BlockThreshold
+	}
+	score := r.Similarity
+	report.Score = &score
+
+	for _, match := range r.Matches {
+		fileID := match.FileID
+		title := match.Title
+		if title == "" {
+			title = match.OriginalName
+		}
+		report.Sources = append(report.Sources, model.PlagiarismSource{
+			FileID:       &fileID,
+			Title:        title,
+			TeamID:       match.TeamID,
+			Intake:       match.Intake,
+			AcademicYear: match.AcademicYear,
+			Percent:      match.Similarity,
+		})
+	}
+	if raw, err := json.Marshal(r); err == nil {
+		report.RawPayload = raw
+	}
+	return report
+}
+
+// TopicEngine compares the topic of a document with the earlier projects
+// of other teams, across intakes and academic years.
+type TopicEngine struct {
+	topics      repository.TopicRepository
+	corpusTypes []string
+	topMatches  int
+}
+
+// NewTopicEngine returns an engine that compares against the files of
+// corpusTypes, such as proposals and final reports.
+func NewTopicEngine(topics repository.TopicRepository, corpusTypes []string, topMatches int) *TopicEngine {
+	return &TopicEngine{
+		topics:      topics,
+		corpusTypes: corpusTypes,
+		topMatches:  topMatches,
+	}
+}
+
+// Check ranks the corpus by the cosine similarity of its TF-IDF vectors to
+// terms, leaving out the files of teamID in intake.
+func (e *TopicEngine) Check(terms map[string]int, teamID string, intake string) (*TopicReport, error) {
+	docs, err := e.topics.Corpus(e.corpusTypes, teamID, intake)
+	if err != nil {
+		return nil, err
+	}
+
+	corpus := topics.NewCorpus()
+	corpus.Add(terms)
+	for _, doc := range docs {
+		corpus.Add(doc.Terms)
+	}
+	vector := corpus.Vector(terms)
+
+	report := &TopicReport{
+		Keywords:  vector.Keywords(topicKeywords),
+		Matches:   []TopicMatch{},
+		CheckedAt: time.Now(),
+	}
+	vectors := make([]topics.Vector, len(docs))
+	for i, doc := range docs {
+		vectors[i] = corpus.Vector(doc.Terms)
+		similarity := float64(int(vector.Cosine(vectors[i])*10000)) / 100
+		if similarity <= 0 {
+			continue
+		}
+		report.Matches = append(report.Matches, TopicMatch{TopicDocument: doc, Similarity: similarity})
+	}
+	sort.SliceStable(report.Matches, func(i, j int) bool { return report.Matches[i].Similarity > report.Matches[j].Similarity })
+	if len(report.Matches) > e.topMatches {
+		report.Matches = report.Matches[:e.topMatches]
+	}
+
+	byFile := make(map[uint]topics.Vector, len(docs))
+	for i, doc := range docs {
+		byFile[doc.FileID] = vectors[i]
+	}
+	for i := range report.Matches {
+		match := &report.Matches[i]
+		match.SharedKeywords = vector.Shared(byFile[match.FileID], sharedKeywords)
+	}
+	if len(report.Matches) > 0 {
+		report.Similarity = report.Matches[0].Similarity
+	}
+
+	return report, nil
+}
+
+// Indexes reports whether files of docType are part of the corpus.
+func (e *TopicEngine) Indexes(docType string) bool {
+	for _, t := range e.corpusTypes {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// Backfill indexes the topics of files stored before the engine was enabled
+// and returns how many were added.
+func (e *TopicEngine) Backfill(ctx context.Context, batchSize int) (int, error) {
+	indexed := 0
+	var afterID uint
+	for {
+		docs, err := e.topics.Unindexed(e.corpusTypes, afterID, batchSize)
+		if err != nil || len(docs) == 0 {
+			return indexed, err
+		}
+
+		for _, doc := range docs {
+			if err := ctx.Err(); err != nil {
+				return indexed, err
+			}
+			terms := topics.Terms(doc.Text)
+			if len(terms) == 0 {
+				continue
+			}
+			if err := e.topics.Create(&model.TopicVector{FileID: doc.FileID, Terms: terms}); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+		afterID = docs[len(docs)-1].ID
+	}
+}
@@ -0,0 +1,254 @@
+// Package similarity finds text shared between documents. Documents are
+// split into overlapping word shingles; a MinHash signature of the shingle
+// set estimates how much two documents overlap, and locality sensitive
+// hashing over the signature's bands finds candidate documents without
+// comparing against the whole corpus. Candidates are then compared shingle
+// by shingle to find the passages they share.
+package similarity
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ShingleSize is the number of consecutive words in a shingle.
+	ShingleSize = 5
+	// NumHashes is the length of a MinHash signature.
+	NumHashes = 128
+	// Bands and RowsPerBand split the signature for candidate lookup. Two
+	// documents become candidates when any band matches. With one row per
+	// band even a small copied section in a long report is found, and the
+	// number of shared bands doubles as the Jaccard estimate for ranking.
+	Bands       = NumHashes
+	RowsPerBand = NumHashes / Bands
+
+	// MinShingles is the smallest shingle set worth indexing; shorter texts
+	// match everything and nothing.
+	MinShingles = 20
+)
+
+// Document is a tokenized text and its shingles, in order.
+type Document struct {
+	Words    []string
+	Shingles []uint64
+}
+
+// NewDocument lowercases text, splits it into words of letters and digits
+// and builds its shingles.
+func NewDocument(text string) *Document {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	doc := &Document{Words: words}
+	for i := 0; i+ShingleSize <= len(words); i++ {
+		doc.Shingles = append(doc.Shingles, hashWords(words[i:i+ShingleSize]))
+	}
+	return doc
+}
+
+// Set returns the distinct shingles.
+func (d *Document) Set() map[uint64]bool {
+	set := make(map[uint64]bool, len(d.Shingles))
+	for _, s := range d.Shingles {
+		set[s] = true
+	}
+	return set
+}
+
+// Signature is a MinHash signature: for each of NumHashes hash functions,
+// the smallest hash of any shingle in the set.
+type Signature []uint64
+
+// Sign returns the MinHash signature of the document's shingle set.
+func (d *Document) Sign() Signature {
+	sig := make(Signature, NumHashes)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for shingle := range d.Set() {
+		for i := range sig {
+			if h := mix(shingle ^ seeds[i]); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// Jaccard estimates |A ∩ B| / |A ∪ B| as the share of positions where the
+// signatures agree.
+func (s Signature) Jaccard(other Signature) float64 {
+	if len(s) != len(other) || len(s) == 0 {
+		return 0
+	}
+	equal := 0
+	for i := range s {
+		if s[i] == other[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(s))
+}
+
+// Containment estimates the share of the first set, of size size, that is
+// also in the second set, of size otherSize, from their Jaccard similarity.
+func Containment(jaccard float64, size int, otherSize int) float64 {
+	if size == 0 {
+		return 0
+	}
+	shared := jaccard / (1 + jaccard) * float64(size+otherSize)
+	return min(shared/float64(size), 1)
+}
+
+// BandKeys hashes each band of the signature, including the band number so
+// equal rows in different bands do not collide.
+func (s Signature) BandKeys() []int64 {
+	keys := make([]int64, 0, Bands)
+	buf := make([]byte, 8)
+	for band := 0; band < Bands; band++ {
+		h := fnv.New64a()
+		binary.BigEndian.PutUint64(buf, uint64(band))
+		h.Write(buf)
+		for _, v := range s[band*RowsPerBand : (band+1)*RowsPerBand] {
+			binary.BigEndian.PutUint64(buf, v)
+			h.Write(buf)
+		}
+		keys = append(keys, int64(h.Sum64()))
+	}
+	return keys
+}
+
+// Bytes encodes the signature for storage.
+func (s Signature) Bytes() []byte {
+	b := make([]byte, 8*len(s))
+	for i, v := range s {
+		binary.BigEndian.PutUint64(b[8*i:], v)
+	}
+	return b
+}
+
+// SignatureFromBytes decodes a signature written by Bytes.
+func SignatureFromBytes(b []byte) Signature {
+	sig := make(Signature, len(b)/8)
+	for i := range sig {
+		sig[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	return sig
+}
+
+// Passage is a run of words a document shares with another.
+type Passage struct {
+	Text    string  `json:"text"`
+	Start   int     `json:"start_word"`
+	Words   int     `json:"words"`
+	Percent float64 `json:"percent"`
+}
+
+// Overlap is how much of a document is found in another.
+type Overlap struct {
+	Percent  float64   `json:"percent"`
+	Passages []Passage `json:"passages"`
+}
+
+// maxPassageChars caps the text reported for a single passage.
+const maxPassageChars = 300
+
+// Compare finds the words of d covered by shingles that also occur in other
+// and merges them into passages, longest first. At most maxPassages are
+// returned; Percent counts all of them.
+func (d *Document) Compare(other *Document, maxPassages int) Overlap {
+	if len(d.Words) == 0 {
+		return Overlap{}
+	}
+
+	shared := other.Set()
+	covered := make([]bool, len(d.Words))
+	for i, s := range d.Shingles {
+		if shared[s] {
+			for j := i; j < i+ShingleSize; j++ {
+				covered[j] = true
+			}
+		}
+	}
+
+	var overlap Overlap
+	total := 0
+	for start := 0; start < len(covered); {
+		if !covered[start] {
+			start++
+			continue
+		}
+		end := start
+		for end < len(covered) && covered[end] {
+			end++
+		}
+		total += end - start
+		overlap.Passages = append(overlap.Passages, Passage{
+			Text:    truncate(strings.Join(d.Words[start:end], " "), maxPassageChars),
+			Start:   start,
+			Words:   end - start,
+			Percent: percent(end-start, len(d.Words)),
+		})
+		start = end
+	}
+
+	sort.SliceStable(overlap.Passages, func(i, j int) bool {
+		return overlap.Passages[i].Words > overlap.Passages[j].Words
+	})
+	if len(overlap.Passages) > maxPassages {
+		overlap.Passages = overlap.Passages[:maxPassages]
+	}
+	overlap.Percent = percent(total, len(d.Words))
+	return overlap
+}
+
+func percent(part int, whole int) float64 {
+	return float64(part*10000/whole) / 100
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := strings.LastIndexByte(s[:n], ' ')
+	if cut <= 0 {
+		cut = n
+	}
+	return s[:cut] + " …"
+}
+
+func hashWords(words []string) uint64 {
+	h := fnv.New64a()
+	for _, w := range words {
+		h.Write([]byte(w))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// mix is the splitmix64 finalizer, used to derive the MinHash functions
+// from one shingle hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// seeds are fixed so signatures stay comparable across restarts.
+var seeds = func() [NumHashes]uint64 {
+	var s [NumHashes]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		x += 0x9e3779b97f4a7c15
+		s[i] = mix(x)
+	}
+	return s
+}()
@@ -0,0 +1,173 @@
+// Package topics compares what documents are about. Each document is reduced
+// to counts of its content words; weighting the counts by TF-IDF over a
+// corpus lets words particular to a project dominate, and the cosine of two
+// weighted vectors measures how close their topics are.
+package topics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxTerms is the number of most frequent terms kept per document. The tail
+// of rare words barely moves the cosine and would dominate storage.
+const MaxTerms = 300
+
+// Terms counts the content words of text: lowercased, at least three
+// letters, without stop words and with plurals folded into the singular.
+func Terms(text string) map[string]int {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	counts := make(map[string]int)
+	for _, w := range words {
+		if len([]rune(w)) < 3 || stopWords[w] {
+			continue
+		}
+		counts[singular(w)]++
+	}
+	return top(counts, MaxTerms)
+}
+
+func singular(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is") && len(w) > 3:
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// top keeps the n most frequent terms, breaking ties alphabetically so the
+// result does not depend on map order.
+func top(counts map[string]int, n int) map[string]int {
+	if len(counts) <= n {
+		return counts
+	}
+	terms := make([]string, 0, len(counts))
+	for t := range counts {
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if counts[terms[i]] != counts[terms[j]] {
+			return counts[terms[i]] > counts[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+
+	kept := make(map[string]int, n)
+	for _, t := range terms[:n] {
+		kept[t] = counts[t]
+	}
+	return kept
+}
+
+// Corpus holds the document frequency of every term in a set of documents.
+type Corpus struct {
+	docs int
+	df   map[string]int
+}
+
+func NewCorpus() *Corpus {
+	return &Corpus{df: make(map[string]int)}
+}
+
+// Add counts a document's terms towards the document frequencies.
+func (c *Corpus) Add(terms map[string]int) {
+	c.docs++
+	for t := range terms {
+		c.df[t]++
+	}
+}
+
+// Vector is a document's TF-IDF weights, normalized to unit length.
+type Vector map[string]float64
+
+// Vector weights terms by 1+log(tf) times a smoothed inverse document
+// frequency.
+func (c *Corpus) Vector(terms map[string]int) Vector {
+	v := make(Vector, len(terms))
+	var norm float64
+	for t, n := range terms {
+		idf := math.Log(float64(c.docs+1)/float64(c.df[t]+1)) + 1
+		w := (1 + math.Log(float64(n))) * idf
+		v[t] = w
+		norm += w * w
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for t := range v {
+			v[t] /= norm
+		}
+	}
+	return v
+}
+
+// Cosine returns the cosine similarity of two unit vectors, from 0 to 1.
+func (v Vector) Cosine(other Vector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+	var dot float64
+	for t, w := range v {
+		dot += w * other[t]
+	}
+	return dot
+}
+
+// Keywords returns the n terms with the highest weight.
+func (v Vector) Keywords(n int) []string {
+	return rank(v, n)
+}
+
+// Shared returns the n terms contributing most to the similarity of v and
+// other.
+func (v Vector) Shared(other Vector, n int) []string {
+	products := make(Vector)
+	for t, w := range v {
+		if o, ok := other[t]; ok {
+			products[t] = w * o
+		}
+	}
+	return rank(products, n)
+}
+
+func rank(v Vector, n int) []string {
+	terms := make([]string, 0, len(v))
+	for t := range v {
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+var stopWords = func() map[string]bool {
+	words := strings.Fields(`
+		the and for are but not you all any can had her was one our out has him his how
+		its may new now old see two who did get let put say she too use way also been
+		from have into more most much must only other over same some such than that
+		their them then there these they this those through under very were what when
+		where which while will with would your about above after again against because
+		before being below between both could does doing down during each few further
+		here itself just myself nor off once ours own should themselves until upon via
+		yet within without using used uses based shall able well however therefore
+		thus chapter section figure table page introduction conclusion abstract references
+		project system propose proposed proposal study paper report work result results`)
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// FileDigest holds the digests of a file's content. Either may be empty
+// when it is not known.
+type FileDigest struct {
+	MD5    []byte
+	SHA256 []byte
+}
+
+func (d FileDigest) MD5Hex() string {
+	return hex.EncodeToString(d.MD5)
+}
+
+func (d FileDigest) SHA256Hex() string {
+	return hex.EncodeToString(d.SHA256)
+}
+
+// DigestFromHex is the inverse of MD5Hex and SHA256Hex. Invalid input yields
+// an empty digest.
+func DigestFromHex(md5Hex string, sha256Hex string) FileDigest {
+	var d FileDigest
+	d.MD5, _ = hex.DecodeString(md5Hex)
+	d.SHA256, _ = hex.DecodeString(sha256Hex)
+	return d
+}
+
+// Mismatch compares the digests present in both d and expected and returns
+// the name of the first algorithm that differs, or "" if none does.
+func (d FileDigest) Mismatch(expected FileDigest) string {
+	if len(expected.MD5) > 0 && len(d.MD5) > 0 && !bytes.Equal(d.MD5, expected.MD5) {
+		return "md5"
+	}
+	if len(expected.SHA256) > 0 && len(d.SHA256) > 0 && !bytes.Equal(d.SHA256, expected.SHA256) {
+		return "sha256"
+	}
+	return ""
+}
+
+// DigestWriter computes MD5 and SHA-256 digests of everything written to it,
+// so they can be taken while a file is being copied.
+type DigestWriter struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+func NewDigestWriter() *DigestWriter {
+	return &DigestWriter{md5: md5.New(), sha256: sha256.New()}
+}
+
+func (w *DigestWriter) Write(p []byte) (int, error) {
+	w.md5.Write(p)
+	w.sha256.Write(p)
+	return len(p), nil
+}
+
+func (w *DigestWriter) Digest() FileDigest {
+	return FileDigest{MD5: w.md5.Sum(nil), SHA256: w.sha256.Sum(nil)}
+}
+
+// DigestReader reads r to the end and returns its digests.
+func DigestReader(r io.Reader) (FileDigest, error) {
+	w := NewDigestWriter()
+	if _, err := io.Copy(w, r); err != nil {
+		return FileDigest{}, err
+	}
+	return w.Digest(), nil
+}
+
+func DigestFile(path string) (FileDigest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileDigest{}, err
+	}
+	defer file.Close()
+	return DigestReader(file)
+}
+
+// ParseChecksum decodes a client supplied digest of size bytes. Content-MD5
+// is base64 by definition, but hex is accepted for both algorithms since
+// that is what most command line tools print.
+func ParseChecksum(value string, size int) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == hex.EncodedLen(size) {
+		if sum, err := hex.DecodeString(value); err == nil {
+			return sum, nil
+		}
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if sum, err := enc.DecodeString(value); err == nil && len(sum) == size {
+			return sum, nil
+		}
+	}
+	return nil, fmt.Errorf("expected a %d byte digest in hex or base64", size)
+}
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// maxDisplayNameBytes matches the file name limit of common filesystems.
+	maxDisplayNameBytes = 255
+	// maxStorageStemBytes leaves room for the prefix, the collision suffix
+	// and the extension within S3's 1024 byte key limit and zip tools that
+	// still assume short names.
+	maxStorageStemBytes = 100
+	// maxExtensionBytes bounds what is treated as an extension; anything
+	// longer is considered part of the name.
+	maxExtensionBytes = 16
+	fallbackFilename  = "file"
+)
+
+// unsafeFilenameChars are replaced in storage names: path separators,
+// characters Windows refuses in file names and the characters S3 advises
+// against using in keys.
+const unsafeFilenameChars = `<>:"/\|?*{}^%` + "`" + `[]~#`
+
+// lookalikeSeparators are characters that render like a slash or backslash
+// but are not folded to one by compatibility normalisation.
+var lookalikeSeparators = map[rune]bool{
+	'⁄': true, // FRACTION SLASH
+	'∕': true, // DIVISION SLASH
+	'∖': true, // SET MINUS
+	'⧵': true, // REVERSE SOLIDUS OPERATOR
+	'⧸': true, // BIG SOLIDUS
+	'⧹': true, // BIG REVERSE SOLIDUS
+	'〳': true, // VERTICAL KANA REPEAT MARK UPPER HALF
+}
+
+var reservedWindowsNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// DisplayFilename cleans a client supplied file name for showing back to
+// users. It keeps the name recognisable: only the final path component is
+// kept, the text is normalised to NFC, control and invisible formatting
+// characters (including bidi overrides that can disguise the extension) are
+// dropped and the result is capped at 255 bytes with the extension kept.
+func DisplayFilename(name string) string {
+	name = norm.NFC.String(baseName(name))
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError:
+			continue
+		case unicode.In(r, unicode.Zl, unicode.Zp) || unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	name = strings.Join(strings.Fields(b.String()), " ")
+	if name == "" || name == "." || name == ".." {
+		return fallbackFilename
+	}
+
+	stem, ext := splitExtension(name)
+	return truncateBytes(stem, maxDisplayNameBytes-len(ext)) + ext
+}
+
+// SanitizeFilename turns a client supplied file name into one that is safe
+// to use as the last segment of a storage key or a zip entry. On top of
+// DisplayFilename it
+//
+//   - replaces characters that are unsafe in paths and keys, and Unicode
+//     look-alikes of slashes and dots, with '_'
+//   - replaces whitespace with '_' and collapses repeated '_'
+//   - removes leading dots and trailing dots so the name is neither hidden
+//     nor a relative path element
+//   - prefixes names reserved on Windows, such as CON or LPT1
+//   - caps the name at 100 bytes, keeping an extension of up to 16 letters
+//     and digits
+//
+// The result is never empty; a name with nothing usable left becomes "file"
+// with its extension.
+func SanitizeFilename(name string) string {
+	name = DisplayFilename(name)
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < utf8.RuneSelf:
+			if r == ' ' || strings.ContainsRune(unsafeFilenameChars, r) {
+				b.WriteByte('_')
+			} else {
+				b.WriteRune(r)
+			}
+		case lookalikeSeparators[r] || isLookalike(r):
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	stem, ext := splitExtension(b.String())
+	if !isPlainExtension(ext) {
+		stem, ext = stem+ext, ""
+	}
+
+	stem = collapse(stem, '_')
+	stem = strings.TrimLeft(stem, "._-")
+	stem = strings.TrimRight(stem, ". _")
+	if stem == "" {
+		stem = fallbackFilename
+	}
+	if reservedWindowsNames[strings.ToUpper(stem)] {
+		stem = "_" + stem
+	}
+
+	stem = strings.TrimRight(truncateBytes(stem, maxStorageStemBytes), ". _")
+	return stem + ext
+}
+
+// UniqueFilename sanitizes name and adds a random suffix before the
+// extension so files uploaded under the same name do not overwrite each
+// other, e.g. "report.pdf" becomes "report-3f9a2c1b7d0e4a65.pdf".
+func UniqueFilename(name string) string {
+	stem, ext := splitExtension(SanitizeFilename(name))
+	if !isPlainExtension(ext) {
+		stem, ext = stem+ext, ""
+	}
+	return stem + "-" + randomHex(8) + ext
+}
+
+// SanitizeKeyPath applies SanitizeFilename to every segment of a slash
+// separated key so it can be used as a zip entry name without escaping the
+// archive root. Empty, "." and ".." segments are dropped.
+func SanitizeKeyPath(key string) string {
+	var segments []string
+	for _, segment := range strings.Split(key, "/") {
+		if s := strings.TrimSpace(segment); s == "" || s == "." || s == ".." {
+			continue
+		}
+		segments = append(segments, SanitizeFilename(segment))
+	}
+	if len(segments) == 0 {
+		return fallbackFilename
+	}
+	return strings.Join(segments, "/")
+}
+
+// baseName returns the last path component, treating both '/' and '\' as
+// separators since clients on any platform may send either.
+func baseName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// isLookalike reports whether compatibility normalisation maps r to text
+// containing a dot or a character that is unsafe in file names, as with
+// FULLWIDTH SOLIDUS or ONE DOT LEADER.
+func isLookalike(r rune) bool {
+	folded := norm.NFKC.String(string(r))
+	if folded == string(r) {
+		return false
+	}
+	return strings.ContainsAny(folded, "."+unsafeFilenameChars)
+}
+
+func splitExtension(name string) (string, string) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 || i == len(name)-1 || len(name)-i > maxExtensionBytes+1 {
+		return name, ""
+	}
+	return name[:i], name[i:]
+}
+
+func isPlainExtension(ext string) bool {
+	for _, r := range strings.TrimPrefix(ext, ".") {
+		if r >= utf8.RuneSelf || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func collapse(s string, c byte) string {
+	double := string([]byte{c, c})
+	for strings.Contains(s, double) {
+		s = strings.ReplaceAll(s, double, string(c))
+	}
+	return s
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a character.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDisplayFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Final Report.pdf", "Final Report.pdf"},
+		{"parent directories", "../../etc/passwd", "passwd"},
+		{"absolute path", "/abs/path/report.pdf", "report.pdf"},
+		{"windows path", `C:\Users\me\report.pdf`, "report.pdf"},
+		{"backslash parents", `..\..\x.pdf`, "x.pdf"},
+		{"NUL", "re\x00port.pdf", "report.pdf"},
+		{"control whitespace", "a\tb\nc.pdf", "a b c.pdf"},
+		{"bidi override", "evil\u202Efdp.exe", "evilfdp.exe"},
+		{"trailing bidi override", "a.pdf\u202E", "a.pdf"},
+		{"zero width space", "\u200Breport.pdf", "report.pdf"},
+		{"NFD to NFC", "re\u0301sume\u0301.pdf", "r\u00e9sum\u00e9.pdf"},
+		{"NFC kept", "r\u00e9sum\u00e9.pdf", "r\u00e9sum\u00e9.pdf"},
+		{"collapsed spaces", "  a    b .pdf ", "a b .pdf"},
+		{"invalid UTF-8", "a\xffb.pdf", "ab.pdf"},
+		{"reserved name kept", "CON.pdf", "CON.pdf"},
+		{"empty", "", "file"},
+		{"dot", ".", "file"},
+		{"dot dot", "..", "file"},
+		{"only a path", "a/b/", "file"},
+		{"only spaces", "   ", "file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DisplayFilename(tt.in); got != tt.want {
+				t.Errorf("DisplayFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"spaces", "Final Report v2.pdf", "Final_Report_v2.pdf"},
+		{"parent directories", "../../etc/passwd", "passwd"},
+		{"absolute path", "/etc/passwd", "passwd"},
+		{"windows path", `C:\Users\me\report.pdf`, "report.pdf"},
+		{"NUL", "re\x00port.pdf", "report.pdf"},
+		{"control whitespace", "a\tb\nc.pdf", "a_b_c.pdf"},
+		{"bidi override", "evil\u202Efdp.exe", "evilfdp.exe"},
+		{"NFD to NFC", "re\u0301sume.pdf", "r\u00e9sume.pdf"},
+		{"unsafe characters", "50% <done>?.pdf", "50_done.pdf"},
+		{"repeated separators", "a  __  b.pdf", "a_b.pdf"},
+		{"fullwidth slash", "ｒｅｐｏｒｔ／x.pdf", "ｒｅｐｏｒｔ_x.pdf"},
+		{"one dot leader", "a\u2024pdf", "a_pdf"},
+		{"fraction slash", "a\u2044b.pdf", "a_b.pdf"},
+
+		{"reserved name", "CON.pdf", "_CON.pdf"},
+		{"reserved name lower case", "lpt1", "_lpt1"},
+		{"reserved name mixed case", "Com9.txt", "_Com9.txt"},
+		{"not reserved", "CONSOLE.pdf", "CONSOLE.pdf"},
+
+		{"trailing dot", "report.pdf.", "report.pdf"},
+		{"trailing dots and spaces", "report. . .", "report"},
+		{"space before extension", " name .pdf", "name.pdf"},
+		{"hidden", ".hidden", "file.hidden"},
+		{"leading dots", "..report.pdf", "report.pdf"},
+
+		{"empty", "", "file"},
+		{"dot", ".", "file"},
+		{"dot dot", "..", "file"},
+		{"dots", "...", "file"},
+		{"dots and separators", "._.pdf", "file.pdf"},
+
+		// Only the last extension is the file's type; earlier ones stay
+		// part of the name
+		{"double extension", "invoice.pdf.exe", "invoice.pdf.exe"},
+		{"compressed tar", "archive.tar.gz", "archive.tar.gz"},
+		{"spaced double extension", "report.pdf .exe", "report.pdf.exe"},
+		{"long extension", "a.verylongextension123", "a.verylongextension123"},
+		{"extension with a space", "a.p df", "a.p_df"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilenameLengthCaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		clean    func(string) string
+		maxBytes int
+		suffix   string
+	}{
+		{"display two byte runes", strings.Repeat("é", 200) + ".pdf", DisplayFilename, 255, ".pdf"},
+		{"display four byte runes", strings.Repeat("😀", 100) + ".pdf", DisplayFilename, 255, ".pdf"},
+		{"display no extension", strings.Repeat("é", 200), DisplayFilename, 255, "é"},
+		{"storage two byte runes", strings.Repeat("é", 200) + ".pdf", SanitizeFilename, 104, ".pdf"},
+		{"storage rune across the cap", strings.Repeat("a", 99) + "é.pdf", SanitizeFilename, 104, "a.pdf"},
+		{"storage four byte runes", strings.Repeat("😀", 30) + ".docx", SanitizeFilename, 105, ".docx"},
+		{"storage cut before a separator", strings.Repeat("a", 99) + " b.pdf", SanitizeFilename, 104, "a.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.clean(tt.in)
+			if !utf8.ValidString(got) {
+				t.Errorf("%q is not valid UTF-8", got)
+			}
+			if len(got) > tt.maxBytes {
+				t.Errorf("%q is %d bytes, want at most %d", got, len(got), tt.maxBytes)
+			}
+			if !strings.HasSuffix(got, tt.suffix) {
+				t.Errorf("%q does not end in %q", got, tt.suffix)
+			}
+		})
+	}
+}
+
+var uniqueSuffix = regexp.MustCompile(`-[0-9a-f]{16}`)
+
+func TestUniqueFilename(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"report.pdf", "report-*.pdf"},
+		{"../x", "x-*"},
+		{"", "file-*"},
+		{"CON.pdf", "_CON-*.pdf"},
+		{"archive.tar.gz", "archive.tar-*.gz"},
+		{"a.p df", "a.p_df-*"},
+		{"evil\u202Efdp.exe", "evilfdp-*.exe"},
+	}
+	for _, tt := range tests {
+		got := UniqueFilename(tt.in)
+		if masked := uniqueSuffix.ReplaceAllString(got, "-*"); masked != tt.want {
+			t.Errorf("UniqueFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if a, b := UniqueFilename("report.pdf"), UniqueFilename("report.pdf"); a == b {
+		t.Errorf("UniqueFilename gave %q twice", a)
+	}
+}
+
+func TestSanitizeKeyPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"team-1/report.pdf", "team-1/report.pdf"},
+		{"a/../b/./c.pdf", "a/b/c.pdf"},
+		{"../../x", "x"},
+		{"/abs/key.pdf", "abs/key.pdf"},
+		{"a//b", "a/b"},
+		{"team 1/rep ort.pdf", "team_1/rep_ort.pdf"},
+		{`a\..\b/c.pdf`, "b/c.pdf"},
+		{"x/ .. /y", "x/y"},
+		{"CON/aux.txt", "_CON/_aux.txt"},
+		{"dir/\u202Egpj.exe", "dir/gpj.exe"},
+		{"", "file"},
+		{"/", "file"},
+		{"..", "file"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeKeyPath(tt.in); got != tt.want {
+			t.Errorf("SanitizeKeyPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
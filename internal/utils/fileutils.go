@@ -15,7 +15,11 @@ const (
 )
 
 func DetectFileType(fileHeader *multipart.FileHeader) FileType {
-	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	return DetectFileTypeByName(fileHeader.Filename)
+}
+
+func DetectFileTypeByName(filename string) FileType {
+	ext := strings.ToLower(filepath.Ext(filename))
 
 	switch ext {
 	case ".pdf":
@@ -0,0 +1,184 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by a Registry for unknown intakes.
+var ErrNotFound = errors.New("not found")
+
+// Intake is a cohort of teams working in the same academic year and session.
+// Deadlines maps a document type to the time its uploads close.
+type Intake struct {
+	Name         string               `json:"name"`
+	AcademicYear string               `json:"academic_year,omitempty"`
+	Session      string               `json:"session,omitempty"`
+	Teams        []string             `json:"teams"`
+	Deadlines    map[string]time.Time `json:"deadlines,omitempty"`
+}
+
+// Registry knows which intakes and teams exist.
+type Registry interface {
+	FindIntake(name string) (*Intake, error)
+	HasTeam(intake string, teamID string) (bool, error)
+}
+
+type staticRegistry struct {
+	intakes map[string]*Intake
+	teams   map[string]map[string]bool
+}
+
+// NewStaticRegistry returns a Registry over a fixed list of intakes. Names
+// and team IDs are compared case-insensitively.
+func NewStaticRegistry(intakes []Intake) Registry {
+	r := &staticRegistry{
+		intakes: make(map[string]*Intake, len(intakes)),
+		teams:   make(map[string]map[string]bool, len(intakes)),
+	}
+	for i := range intakes {
+		intake := &intakes[i]
+		key := strings.ToLower(intake.Name)
+		r.intakes[key] = intake
+		r.teams[key] = make(map[string]bool, len(intake.Teams))
+		for _, team := range intake.Teams {
+			r.teams[key][strings.ToLower(team)] = true
+		}
+	}
+	return r
+}
+
+func (r *staticRegistry) FindIntake(name string) (*Intake, error) {
+	intake, ok := r.intakes[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return intake, nil
+}
+
+func (r *staticRegistry) HasTeam(intake string, teamID string) (bool, error) {
+	return r.teams[strings.ToLower(intake)][strings.ToLower(teamID)], nil
+}
+
+type openRegistry struct{}
+
+// NewOpenRegistry returns a Registry that accepts every intake and team. It
+// is used when no registry is configured, leaving only the format checks.
+func NewOpenRegistry() Registry {
+	return openRegistry{}
+}
+
+func (openRegistry) FindIntake(name string) (*Intake, error) {
+	return &Intake{Name: name}, nil
+}
+
+func (openRegistry) HasTeam(intake string, teamID string) (bool, error) {
+	return true, nil
+}
+
+// LoadRegistry reads intakes from a JSON file of the form
+//
+//	{"intakes": [{"name": "fall-2025", "academic_year": "2025-2026",
+//	  "session": "fall", "teams": ["team-01", "team-02"],
+//	  "deadlines": {"final_report": "2026-01-15T23:59:59Z"}}]}
+//
+// An empty path yields the open registry.
+func LoadRegistry(path string) (Registry, error) {
+	if path == "" {
+		return NewOpenRegistry(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry file: %w", err)
+	}
+
+	var file struct {
+		Intakes []Intake `json:"intakes"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse registry file: %w", err)
+	}
+
+	return NewStaticRegistry(file.Intakes), nil
+}
+
+// KnownIntake requires the intake to exist in the registry.
+func KnownIntake(registry Registry) Rule {
+	return func(field, value string, values Values) error {
+		_, err := registry.FindIntake(value)
+		if errors.Is(err, ErrNotFound) {
+			return Reject(field, "unknown", fmt.Sprintf("intake %q does not exist", value))
+		}
+		return err
+	}
+}
+
+// KnownTeam requires the team to belong to the intake in intakeField. It
+// does nothing when the intake is missing, which its own rules report.
+func KnownTeam(registry Registry, intakeField string) Rule {
+	return func(field, value string, values Values) error {
+		intake := values[intakeField]
+		if intake == "" {
+			return nil
+		}
+		ok, err := registry.HasTeam(intake, value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return Reject(field, "unknown", fmt.Sprintf("team %q is not part of intake %q", value, intake))
+		}
+		return nil
+	}
+}
+
+// MatchesIntake requires the value to agree with the intake in intakeField
+// when the registry records it, using get to pick the attribute (academic
+// year or session).
+func MatchesIntake(registry Registry, intakeField string, get func(*Intake) string) Rule {
+	return func(field, value string, values Values) error {
+		name := values[intakeField]
+		if name == "" {
+			return nil
+		}
+		intake, err := registry.FindIntake(name)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if want := get(intake); want != "" && !strings.EqualFold(want, value) {
+			return Reject(field, "mismatch", fmt.Sprintf("intake %q belongs to %s %s", name, field, want))
+		}
+		return nil
+	}
+}
+
+// BeforeDeadline rejects a document type whose deadline in the intake in
+// intakeField has passed. Document types without a deadline are accepted.
+func BeforeDeadline(registry Registry, intakeField string, now func() time.Time) Rule {
+	return func(field, value string, values Values) error {
+		name := values[intakeField]
+		if name == "" {
+			return nil
+		}
+		intake, err := registry.FindIntake(name)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		deadline, ok := intake.Deadlines[value]
+		if ok && now().After(deadline) {
+			return Reject(field, "deadline_passed", fmt.Sprintf("uploads of %s for intake %q closed at %s", value, name, deadline.Format(time.RFC3339)))
+		}
+		return nil
+	}
+}
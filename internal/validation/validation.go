@@ -0,0 +1,184 @@
+package validation
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes why one field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors is returned by Schema.Validate when one or more fields are invalid.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Values holds the request fields being validated.
+type Values map[string]string
+
+// Rule checks a non-empty field value. It returns a *FieldError to reject
+// the value, or any other error when the check itself could not be made.
+type Rule func(field string, value string, values Values) error
+
+// Field lists the rules for one request field.
+type Field struct {
+	Name     string
+	Required bool
+	Rules    []Rule
+}
+
+// Schema declares the fields a request accepts, in the order errors are
+// reported.
+type Schema []Field
+
+// Values reads the schema's fields from the request's query string and form,
+// trimming surrounding whitespace.
+func (s Schema) Values(r *http.Request) Values {
+	values := make(Values, len(s))
+	for _, f := range s {
+		values[f.Name] = strings.TrimSpace(r.FormValue(f.Name))
+	}
+	return values
+}
+
+// Validate checks every field and returns Errors listing all invalid fields.
+// Any other error means a rule could not be evaluated.
+func (s Schema) Validate(values Values) error {
+	var errs Errors
+	for _, f := range s {
+		value := values[f.Name]
+		if value == "" {
+			if f.Required {
+				errs = append(errs, Reject(f.Name, "required", f.Name+" is required"))
+			}
+			continue
+		}
+
+		for _, rule := range f.Rules {
+			err := rule(f.Name, value, values)
+			if err == nil {
+				continue
+			}
+			fieldErr, ok := err.(*FieldError)
+			if !ok {
+				return err
+			}
+			errs = append(errs, fieldErr)
+			// Later rules usually assume the earlier ones passed
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func Reject(field string, code string, message string) *FieldError {
+	return &FieldError{Field: field, Code: code, Message: message}
+}
+
+// Pattern requires the value to match re; description completes the
+// sentence "<field> must be ...".
+func Pattern(re *regexp.Regexp, description string) Rule {
+	return func(field, value string, values Values) error {
+		if !re.MatchString(value) {
+			return Reject(field, "format", field+" must be "+description)
+		}
+		return nil
+	}
+}
+
+func MaxLength(n int) Rule {
+	return func(field, value string, values Values) error {
+		if len(value) > n {
+			return Reject(field, "too_long", fmt.Sprintf("%s must be at most %d characters", field, n))
+		}
+		return nil
+	}
+}
+
+func MinLength(n int) Rule {
+	return func(field, value string, values Values) error {
+		if len(value) < n {
+			return Reject(field, "too_short", fmt.Sprintf("%s must be at least %d characters", field, n))
+		}
+		return nil
+	}
+}
+
+// OneOf requires the value to be one of allowed, ignoring case.
+func OneOf(allowed ...string) Rule {
+	return func(field, value string, values Values) error {
+		for _, a := range allowed {
+			if strings.EqualFold(value, a) {
+				return nil
+			}
+		}
+		return Reject(field, "invalid_choice", fmt.Sprintf("%s must be one of %s", field, strings.Join(allowed, ", ")))
+	}
+}
+
+func PositiveInt() Rule {
+	return func(field, value string, values Values) error {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || n == 0 {
+			return Reject(field, "format", field+" must be a positive integer")
+		}
+		return nil
+	}
+}
+
+// Percentage requires a number from 0 to 100.
+func Percentage() Rule {
+	return func(field, value string, values Values) error {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n < 0 || n > 100 {
+			return Reject(field, "format", field+" must be a number from 0 to 100")
+		}
+		return nil
+	}
+}
+
+var academicYearPattern = regexp.MustCompile(`^(\d{4})-(\d{4})$`)
+
+// AcademicYear requires a span of two consecutive years such as 2025-2026.
+func AcademicYear() Rule {
+	return func(field, value string, values Values) error {
+		m := academicYearPattern.FindStringSubmatch(value)
+		if m == nil {
+			return Reject(field, "format", field+" must look like 2025-2026")
+		}
+		start, _ := strconv.Atoi(m[1])
+		end, _ := strconv.Atoi(m[2])
+		if end != start+1 {
+			return Reject(field, "format", field+" must span two consecutive years")
+		}
+		return nil
+	}
+}
+
+// Common field formats. Identifiers end up in storage keys, so they are
+// limited to characters that are safe in a path segment.
+var (
+	Identifier = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	DocType    = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+)